@@ -7,7 +7,10 @@
 // all errors elide the standard "Err" prefix.
 package kerr
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Error is a Kafka error.
 type Error struct {
@@ -61,10 +64,12 @@ func TypedErrorForCode(code int16) *Error {
 	return err.(*Error)
 }
 
-// IsRetriable returns whether a Kafka error is considered retriable.
+// IsRetriable returns whether a Kafka error is considered retriable. The
+// error is unwrapped with errors.As, so a wrapped Kafka error, such as one
+// inside a kgo.ProduceError, is checked as well.
 func IsRetriable(err error) bool {
-	kerr, ok := err.(*Error)
-	return ok && kerr.Retriable
+	var kerr *Error
+	return errors.As(err, &kerr) && kerr.Retriable
 }
 
 var (
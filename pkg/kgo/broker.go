@@ -69,7 +69,8 @@ type BrokerMetadata struct {
 	// NodeID is the broker node ID.
 	//
 	// Seed brokers will have very negative IDs; kgo does not try to map
-	// seed brokers to loaded brokers.
+	// seed brokers to loaded brokers. IsSeedBroker can be used to check
+	// whether metadata is for a seed broker.
 	NodeID int32
 
 	// Port is the port of the broker.
@@ -87,6 +88,28 @@ type BrokerMetadata struct {
 	_internal struct{} // allow us to add fields later
 }
 
+// IsSeedBroker returns whether this metadata is for a seed broker, that is, a
+// broker from the SeedBrokers option rather than one discovered through a
+// metadata request.
+func (this BrokerMetadata) IsSeedBroker() bool {
+	return this.NodeID < 0
+}
+
+// IsController returns whether this broker is the controller, given the
+// controller ID from a metadata response.
+//
+// Seed brokers are never the controller, even if they are the same broker as
+// the controller: kgo does not map seed brokers to loaded brokers.
+func (this BrokerMetadata) IsController(controllerID int32) bool {
+	return !this.IsSeedBroker() && this.NodeID == controllerID
+}
+
+// String returns the broker formatted as broker-{NodeID}({Host}:{Port}), for
+// use in log messages.
+func (this BrokerMetadata) String() string {
+	return fmt.Sprintf("broker-%d(%s)", this.NodeID, net.JoinHostPort(this.Host, strconv.Itoa(int(this.Port))))
+}
+
 func (this BrokerMetadata) equals(other kmsg.MetadataResponseBroker) bool {
 	return this.NodeID == other.NodeID &&
 		this.Port == other.Port &&
@@ -123,6 +146,18 @@ type broker struct {
 	reqs chan promisedReq
 	// dead is an atomic so a backed up reqs cannot block broker stoppage.
 	dead int32
+
+	// connErrMu guards connErr, which is the error from our most recent
+	// attempt to open a connection, or nil if that attempt succeeded.
+	//
+	// It also guards lastErr and lastErrAt, the most recent connection,
+	// write, or read error (which are not cleared on success), and
+	// connFailures, the number of consecutive failed connection attempts.
+	connErrMu    sync.Mutex
+	connErr      error
+	lastErr      error
+	lastErrAt    time.Time
+	connFailures int
 }
 
 const unknownControllerID = -1
@@ -231,6 +266,12 @@ func (b *broker) handleReqs() {
 	}()
 
 	for pr := range b.reqs {
+		b.cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookBrokerSendQueued); ok {
+				h.OnBrokerSendQueued(b.meta, len(b.reqs), pr.enqueue)
+			}
+		})
+
 		req := pr.req
 		var cxn *brokerCxn
 		{
@@ -372,7 +413,7 @@ func (b *broker) handleReqs() {
 }
 
 func (cxn *brokerCxn) hookWriteE2E(key int16, bytesWritten int, writeWait, timeToWrite time.Duration, writeErr error) {
-	cxn.cl.cfg.hooks.each(func(h Hook) {
+	cxn.cl.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerE2E); ok {
 			h.OnBrokerE2E(cxn.b.meta, key, BrokerE2E{
 				BytesWritten: bytesWritten,
@@ -384,6 +425,21 @@ func (cxn *brokerCxn) hookWriteE2E(key int16, bytesWritten int, writeWait, timeT
 	})
 }
 
+// hookDeadE2E calls HookBrokerE2E hooks for a request that was written but
+// whose response will never be read because the connection died.
+func (cxn *brokerCxn) hookDeadE2E(pr promisedResp) {
+	cxn.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookBrokerE2E); ok {
+			h.OnBrokerE2E(cxn.b.meta, pr.resp.Key(), BrokerE2E{
+				BytesWritten: pr.bytesWritten,
+				WriteWait:    pr.writeWait,
+				TimeToWrite:  pr.timeToWrite,
+				ReadErr:      errChosenBrokerDead,
+			})
+		}
+	})
+}
+
 // bufPool is used to reuse issued-request buffers across writes to brokers.
 type bufPool struct{ p *sync.Pool }
 
@@ -414,6 +470,7 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 
 	conn, err := b.connect(ctx)
 	if err != nil {
+		b.setConnErr(err)
 		return nil, err
 	}
 
@@ -428,9 +485,11 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 	if err = cxn.init(isProduceCxn); err != nil {
 		b.cl.cfg.logger.Log(LogLevelDebug, "connection initialization failed", "addr", b.addr, "broker", b.meta.NodeID, "err", err)
 		cxn.closeConn()
+		b.setConnErr(err)
 		return nil, err
 	}
 	b.cl.cfg.logger.Log(LogLevelDebug, "connection initialized successfully", "addr", b.addr, "broker", b.meta.NodeID)
+	b.setConnErr(nil)
 
 	b.reapMu.Lock()
 	defer b.reapMu.Unlock()
@@ -438,6 +497,31 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 	return cxn, nil
 }
 
+func (b *broker) setConnErr(err error) {
+	b.connErrMu.Lock()
+	defer b.connErrMu.Unlock()
+	b.connErr = err
+	if err == nil {
+		b.connFailures = 0
+		return
+	}
+	b.connFailures++
+	b.lastErr, b.lastErrAt = err, time.Now()
+}
+
+// noteErr saves a write or read error as the broker's last error.
+func (b *broker) noteErr(err error) {
+	b.connErrMu.Lock()
+	defer b.connErrMu.Unlock()
+	b.lastErr, b.lastErrAt = err, time.Now()
+}
+
+func (b *broker) loadConnErr() error {
+	b.connErrMu.Lock()
+	defer b.connErrMu.Unlock()
+	return b.connErr
+}
+
 func (cl *Client) reapConnectionsLoop() {
 	idleTimeout := cl.cfg.connIdleTimeout
 	if idleTimeout < 0 { // impossible due to cfg.validate, but just in case
@@ -517,7 +601,11 @@ func (b *broker) connect(ctx context.Context) (net.Conn, error) {
 	start := time.Now()
 	conn, err := b.cl.cfg.dialFn(ctx, "tcp", b.addr)
 	since := time.Since(start)
-	b.cl.cfg.hooks.each(func(h Hook) {
+	atomic.AddInt64(&b.cl.stats.connectAttempts, 1)
+	if err != nil {
+		atomic.AddInt64(&b.cl.stats.connectErrors, 1)
+	}
+	b.cl.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerConnect); ok {
 			h.OnBrokerConnect(b.meta, since, conn, err)
 		}
@@ -669,7 +757,7 @@ start:
 	return nil
 }
 
-func (cxn *brokerCxn) sasl() error {
+func (cxn *brokerCxn) sasl() (err error) {
 	if len(cxn.cl.cfg.sasls) == 0 {
 		return nil
 	}
@@ -677,6 +765,16 @@ func (cxn *brokerCxn) sasl() error {
 	retried := false
 	authenticate := false
 
+	start := time.Now()
+	defer func() {
+		since := time.Since(start)
+		cxn.cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookBrokerSASL); ok {
+				h.OnBrokerSASL(cxn.b.meta, mechanism.Name(), since, err)
+			}
+		})
+	}()
+
 	req := new(kmsg.SASLHandshakeRequest)
 start:
 	if mechanism.Name() != "GSSAPI" && cxn.versions[req.Key()] >= 0 {
@@ -865,16 +963,22 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 
 	cxn.cl.bufPool.put(buf)
 
-	cxn.cl.cfg.hooks.each(func(h Hook) {
+	cxn.cl.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerWrite); ok {
 			h.OnBrokerWrite(cxn.b.meta, req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
 		}
+		if h, ok := h.(HookBrokerWriteContext); ok {
+			h.OnBrokerWriteContext(hookCtx(ctx), cxn.b.meta, req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
+		}
 	})
 	if logger := cxn.cl.cfg.logger; logger.Level() >= LogLevelDebug {
 		logger.Log(LogLevelDebug, fmt.Sprintf("wrote %s v%d", kmsg.NameForKey(req.Key()), req.GetVersion()), "broker", cxn.b.meta.NodeID, "bytes_written", bytesWritten, "write_wait", writeWait, "time_to_write", timeToWrite, "err", writeErr)
 	}
 
 	if writeErr != nil {
+		if ctx.Err() == nil {
+			cxn.b.noteErr(writeErr)
+		}
 		return
 	}
 	corrID = cxn.corrID
@@ -882,6 +986,15 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 	return
 }
 
+// hookCtx returns ctx, or context.Background() if ctx is nil, for the context
+// aware broker hooks.
+func hookCtx(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
 func (cxn *brokerCxn) writeConn(ctx context.Context, buf []byte, timeout time.Duration, enqueuedForWritingAt time.Time) (bytesWritten int, writeErr error, writeWait, timeToWrite time.Duration, readEnqueue time.Time) {
 	atomic.SwapUint32(&cxn.writing, 1)
 	defer func() {
@@ -1037,11 +1150,14 @@ func (cxn *brokerCxn) readResponse(
 ) ([]byte, error) {
 	bytesRead, buf, readErr, readWait, timeToRead := cxn.readConn(ctx, timeout, readEnqueue)
 
-	cxn.cl.cfg.hooks.each(func(h Hook) {
-		switch h := h.(type) {
-		case HookBrokerRead:
+	cxn.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookBrokerReadContext); ok {
+			h.OnBrokerReadContext(hookCtx(ctx), cxn.b.meta, key, bytesRead, readWait, timeToRead, readErr)
+		}
+		if h, ok := h.(HookBrokerRead); ok {
 			h.OnBrokerRead(cxn.b.meta, key, bytesRead, readWait, timeToRead, readErr)
-		case HookBrokerE2E:
+		}
+		if h, ok := h.(HookBrokerE2E); ok {
 			h.OnBrokerE2E(cxn.b.meta, key, BrokerE2E{
 				BytesWritten: bytesWritten,
 				BytesRead:    bytesRead,
@@ -1058,6 +1174,9 @@ func (cxn *brokerCxn) readResponse(
 	}
 
 	if readErr != nil {
+		if ctx.Err() == nil {
+			cxn.b.noteErr(readErr)
+		}
 		return nil, readErr
 	}
 	if len(buf) < 4 {
@@ -1081,7 +1200,7 @@ func (cxn *brokerCxn) readResponse(
 // in either die, which is called when handleResps returns, or if init fails,
 // which means we did not succeed enough to start handleResps.
 func (cxn *brokerCxn) closeConn() {
-	cxn.cl.cfg.hooks.each(func(h Hook) {
+	cxn.cl.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerDisconnect); ok {
 			h.OnBrokerDisconnect(cxn.b.meta, cxn.conn)
 		}
@@ -1105,7 +1224,7 @@ func (cxn *brokerCxn) die() {
 	go func() {
 		for pr := range cxn.resps {
 			pr.promise(nil, errChosenBrokerDead)
-			cxn.hookWriteE2E(pr.resp.Key(), pr.bytesWritten, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
+			cxn.hookDeadE2E(pr)
 		}
 	}()
 
@@ -1130,7 +1249,7 @@ func (cxn *brokerCxn) waitResp(pr promisedResp) {
 
 	if dead {
 		pr.promise(nil, errChosenBrokerDead)
-		cxn.hookWriteE2E(pr.resp.Key(), pr.bytesWritten, pr.writeWait, pr.timeToWrite, errChosenBrokerDead)
+		cxn.hookDeadE2E(pr)
 	}
 }
 
@@ -1227,10 +1346,13 @@ func (cxn *brokerCxn) discard() {
 			return
 		}
 
-		cxn.cl.cfg.hooks.each(func(h Hook) {
+		cxn.cl.hooks.each(func(h Hook) {
 			if h, ok := h.(HookBrokerRead); ok {
 				h.OnBrokerRead(cxn.b.meta, 0, nread, 0, timeToRead, err)
 			}
+			if h, ok := h.(HookBrokerReadContext); ok {
+				h.OnBrokerReadContext(context.Background(), cxn.b.meta, 0, nread, 0, timeToRead, err)
+			}
 		})
 		if err != nil {
 			return
@@ -1271,10 +1393,13 @@ func (cxn *brokerCxn) handleResps() {
 							atomic.StoreInt64(&cxn.throttleUntil, throttleUntil)
 						}
 					}
-					cxn.cl.cfg.hooks.each(func(h Hook) {
+					cxn.cl.hooks.each(func(h Hook) {
 						if h, ok := h.(HookBrokerThrottle); ok {
 							h.OnBrokerThrottle(cxn.b.meta, time.Duration(millis)*time.Millisecond, throttlesAfterResp)
 						}
+						if h, ok := h.(HookBrokerThrottleKey); ok {
+							h.OnBrokerThrottleKey(cxn.b.meta, pr.resp.Key(), time.Duration(millis)*time.Millisecond, throttlesAfterResp)
+						}
 					})
 				}
 			}
@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
@@ -38,7 +39,15 @@ var crc32c = crc32.MakeTable(crc32.Castagnoli) // record crc's use Castagnoli ta
 
 // Client issues requests and handles responses to a Kafka cluster.
 type Client struct {
-	cfg cfg
+	stats recordStats // first for 64 bit alignment of atomics
+
+	topicStatsMu sync.Mutex   // locked to prevent concurrent additions; reads are always atomic
+	topicStats   atomic.Value // map[string]*topicProduceStats
+
+	cfg  cfg
+	opts []Opt // the options the client was created with, for CloneWithOverrides
+
+	hooks clientHooks // initialized from cfg.hooks; can be modified with AddHook and RemoveHook
 
 	ctx       context.Context
 	ctxCancel func()
@@ -69,6 +78,9 @@ type Client struct {
 	controllerIDMu sync.Mutex
 	controllerID   int32
 
+	kafkaVersionMu sync.Mutex
+	kafkaVersion   *KafkaVersion // cached after the first successful KafkaVersion
+
 	// The following two ensure that we only have one fetchBrokerMetadata
 	// at once. This avoids unnecessary broker metadata requests and
 	// metadata trampling.
@@ -84,6 +96,9 @@ type Client struct {
 	compressor   *compressor
 	decompressor *decompressor
 
+	topicProduceCfgsMu sync.Mutex
+	topicProduceCfgs   atomic.Value // map[string]*topicProduceCfg, see SetTopicProduceOptions
+
 	coordinatorsMu sync.Mutex
 	coordinators   map[coordinatorKey]*coordinatorLoad
 
@@ -151,6 +166,8 @@ func NewClient(opts ...Opt) (*Client, error) {
 
 	cl := &Client{
 		cfg:       cfg,
+		opts:      append([]Opt(nil), opts...),
+		hooks:     clientHooks{hs: cfg.hooks},
 		ctx:       ctx,
 		ctxCancel: cancel,
 		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
@@ -401,6 +418,32 @@ func (cl *Client) updateBrokers(brokers []kmsg.MetadataResponseBroker) {
 	cl.brokers = newBrokers
 }
 
+// CloneWithOverrides is a shortcut for calling NewClient with the options this
+// client was created with, except TransactionalID (see below), followed by
+// opts. Later options override earlier ones, so the clone can use a different
+// consumer group, client ID, acks, and so on.
+//
+// This is a plain rebuild: nothing is shared with this client. The clone opens
+// its own broker connections and loads its own metadata, and closing either
+// client does not affect the other. Connections cannot be shared because they
+// are negotiated with the owning client's configuration (SASL, TLS, client ID,
+// max versions) and are tied to its lifecycle. Hooks are shared only in that
+// the same hook values are passed to both clients. Changes made to this client
+// after creation, such as hooks added with AddHook, are not cloned.
+//
+// A TransactionalID is not inherited, because two clients using the same
+// transactional ID fence each other. To create a transactional clone, pass a
+// new TransactionalID in opts.
+func (cl *Client) CloneWithOverrides(opts ...Opt) (*Client, error) {
+	all := make([]Opt, 0, len(cl.opts)+len(opts)+1)
+	all = append(all, cl.opts...)
+	if cl.cfg.txnID != nil {
+		all = append(all, producerOpt{func(cfg *cfg) { cfg.txnID = nil }})
+	}
+	all = append(all, opts...)
+	return NewClient(all...)
+}
+
 // Close leaves any group and closes all connections and goroutines.
 //
 // If you are group consuming and have overridden the default OnRevoked, you
@@ -430,6 +473,19 @@ func (cl *Client) Close() {
 	}
 
 	cl.failBufferedRecords(ErrClientClosed)
+
+	cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookClientClosed); ok {
+			h.OnClientClosed(cl)
+		}
+	})
+}
+
+// Context returns the client's internal context, which is canceled when the
+// client is closed. This can be used to stop goroutines that are tied to the
+// lifetime of the client.
+func (cl *Client) Context() context.Context {
+	return cl.ctx
 }
 
 // Request issues a request to Kafka, waiting for and returning the response.
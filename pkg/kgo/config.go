@@ -111,12 +111,18 @@ type cfg struct {
 	linger              time.Duration
 	recordTimeout       time.Duration
 	manualFlushing      bool
+	enforceProduceCtx   bool
 
 	partitioner Partitioner
 
 	stopOnDataLoss bool
 	onDataLoss     func(string, int32)
 
+	onProducerFenced func(int64, int16, error)
+	onRecordDropped  func(*Record, error)
+
+	producePropagator ContextPropagator
+
 	//////////////////////
 	// CONSUMER SECTION //
 	//////////////////////
@@ -132,9 +138,14 @@ type cfg struct {
 
 	allowedConcurrentFetches int
 
+	pollTimeout time.Duration // if positive, the max time PollFetches / PollRecords block
+
+	consumePropagator ContextPropagator
+
 	topics     map[string]*regexp.Regexp   // topics to consume; if regex is true, values are compiled regular expressions
 	partitions map[string]map[int32]Offset // partitions to directly consume from
 	regex      bool
+	filter     func(string) bool // if non-nil, topics must also pass this to be consumed
 
 	////////////////////////////
 	// CONSUMER GROUP SECTION //
@@ -161,7 +172,10 @@ type cfg struct {
 
 	autocommitDisable  bool // true if autocommit was disabled or we are transactional
 	autocommitInterval time.Duration
+	commitOnErrDisable bool // true if partitions with fetch errors should not be committed
 	commitCallback     func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error)
+	onOffsetCommit     func(string, map[string]map[int32]int64, error)
+	onCommitError      func(string, map[string]map[int32]int64, error)
 }
 
 // cooperative is a helper that returns whether all group balancers in the
@@ -414,6 +428,7 @@ func defaultCfg() cfg {
 		produceTimeout:      30 * time.Second,
 		produceRetries:      math.MaxInt64,             // effectively unbounded
 		partitioner:         StickyKeyPartitioner(nil), // default to how Kafka partitions
+		enforceProduceCtx:   true,
 
 		//////////////
 		// consumer //
@@ -674,6 +689,9 @@ func SASL(sasls ...sasl.Mechanism) Opt {
 // more information, as well as any interface that contains "Hook" in the name
 // to know the available hooks. A single hook can implement zero or all hook
 // interfaces, and only the hooks that it implements will be called.
+//
+// Hooks can also be added or removed after the client is created with
+// AddHook and RemoveHook.
 func WithHooks(hooks ...Hook) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.hooks = append(cfg.hooks, hooks...) }}
 }
@@ -771,6 +789,12 @@ func BatchMaxBytes(v int32) ProducerOpt {
 // MaxBufferedRecords sets the max amount of records the client will buffer,
 // blocking produces until records are finished if this limit is reached.
 // This overrides the unbounded default.
+//
+// A record is buffered from when it is passed to Produce until its promise is
+// called, so this bounds the records in flight, and thus memory usage, when
+// producing faster than Kafka can acknowledge. Blocked produces respect the
+// produce context; see Produce for details. The current count, which includes
+// records blocked waiting for space, is available with ProduceInflightRecords.
 func MaxBufferedRecords(n int) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxBufferedRecords = int64(n) }}
 }
@@ -827,6 +851,43 @@ func OnDataLoss(fn func(string, int32)) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.onDataLoss = fn }}
 }
 
+// OnProducerFenced sets a function to call if the client's producer ID is
+// fenced, which typically happens when a newer client with the same
+// transactional ID initializes its producer ID.
+//
+// The function is called once, in a new goroutine, with the fenced producer
+// ID and epoch and the error Kafka replied with: kerr.ProducerFenced, or
+// kerr.InvalidProducerEpoch if the broker is too old to allow recovering from
+// that error. After being fenced, the client cannot produce; all buffered and
+// future records are failed with ErrProducerFenced. Applications can use this
+// to begin a graceful shutdown.
+func OnProducerFenced(fn func(producerID int64, epoch int16, err error)) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.onProducerFenced = fn }}
+}
+
+// OnRecordDropped sets a function to call whenever a record fails to be
+// produced, in addition to the record's own promise. This allows one central
+// place to observe every record that will never be written, for example to
+// count drops or to divert records to a dead letter store.
+//
+// The function is called with the record and the error the record's promise
+// is called with, just before the promise is called. Records can be dropped
+// for many reasons: retries or the record timeout being exhausted, a
+// non-retriable error from Kafka, the produce context being canceled, or the
+// client being closed. The function is called serially with record promises
+// and must not block.
+func OnRecordDropped(fn func(r *Record, err error)) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.onRecordDropped = fn }}
+}
+
+// WithProduceContextPropagator sets a propagator to inject values from the
+// produce context into every produced record, typically trace context into
+// record headers. Inject is called with the context passed to Produce (or
+// ProduceBatch) before the record is buffered.
+func WithProduceContextPropagator(p ContextPropagator) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.producePropagator = p }}
+}
+
 // Linger sets how long individual topic partitions will linger
 // waiting for more records before triggering a request to be built.
 //
@@ -874,6 +935,20 @@ func RecordTimeout(timeout time.Duration) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.recordTimeout = timeout }}
 }
 
+// ProduceContextDeadlineEnforced sets whether the context passed to Produce is
+// checked for each record right before the record is first sent, defaulting
+// to true.
+//
+// When enforced, any record whose context is done when the client is about to
+// send it for the first time is not sent; its promise is called with the
+// context's error. Records that have already been sent once are always
+// retried, since they may have been written. When not enforced, the context
+// is only used while waiting for buffer space in Produce, and records are
+// always sent regardless of their context.
+func ProduceContextDeadlineEnforced(enforce bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.enforceProduceCtx = enforce }}
+}
+
 // TransactionalID sets a transactional ID for the client, ensuring that
 // records are produced transactionally under this ID (exactly once semantics).
 //
@@ -898,6 +973,9 @@ func RecordTimeout(timeout time.Duration) ProducerOpt {
 //
 // Lastly, the default read level is READ_UNCOMMITTED. Be sure to use the
 // ReadIsolationLevel option if you want to only read committed.
+//
+// Clients created with CloneWithOverrides do not inherit this option, since
+// two clients with the same transactional ID fence each other.
 func TransactionalID(id string) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.txnID = &id }}
 }
@@ -929,6 +1007,19 @@ func FetchMaxWait(wait time.Duration) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.maxWait = int32(wait.Milliseconds()) }}
 }
 
+// FetchPollTimeout sets the maximum amount of time that PollFetches and
+// PollRecords block waiting for fetches, overriding the default of blocking
+// until fetches are available or the poll context is canceled.
+//
+// If the timeout is reached before any fetches are available, polling returns
+// empty fetches, for which Fetches.IsEmpty returns true. The timeout only
+// bounds how long polling waits; fetching continues in the background and any
+// fetches that arrive are returned in a later poll. A context passed to
+// polling with an earlier deadline still applies.
+func FetchPollTimeout(timeout time.Duration) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.pollTimeout = timeout }}
+}
+
 // FetchMaxBytes sets the maximum amount of bytes a broker will try to send
 // during a fetch, overriding the default 50MiB. Note that brokers may not obey
 // this limit if it has records larger than this limit. Also note that this
@@ -1014,6 +1105,16 @@ func Rack(rack string) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.rack = rack }}
 }
 
+// WithConsumeContextPropagator sets a propagator to extract values from every
+// consumed record, typically trace context from record headers. Extract is
+// called with a background context for each record as it is buffered after a
+// fetch, and the returned context is stored in the record's Context field.
+//
+// This applies to records returned from PollFetches and PollRecords.
+func WithConsumeContextPropagator(p ContextPropagator) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.consumePropagator = p }}
+}
+
 // IsolationLevel controls whether uncommitted or only committed records are
 // returned from fetch requests.
 type IsolationLevel struct {
@@ -1077,6 +1178,24 @@ func ConsumeRegex() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.regex = true }}
 }
 
+// ConsumeTopicFilter sets a function that is called for every topic that
+// would otherwise be consumed; topics for which the function returns false are
+// not consumed.
+//
+// This composes with ConsumeRegex: a topic must both match a regular
+// expression and pass the filter. Unlike regular expressions, the filter is
+// not cached: any topic that has not yet been consumed is re-evaluated on
+// every metadata refresh. This allows for dynamic logic, such as excluding
+// internal topics by naming convention or only including topics that pass an
+// external ACL check. Once a topic is being consumed, the filter is no longer
+// consulted for that topic.
+//
+// The function must be safe for concurrent use and should be fast, since it
+// is called within the metadata update path.
+func ConsumeTopicFilter(fn func(topic string) bool) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.filter = fn }}
+}
+
 //////////////////////////////////
 // CONSUMER GROUP CONFIGURATION //
 //////////////////////////////////
@@ -1191,6 +1310,9 @@ func RequireStableFetchOffsets() GroupOpt {
 //
 // The OnAssigned function is passed the group's context, which is only
 // canceled if the group is left or the client is closed.
+//
+// For direct consumers, this is called by AssignAdditionalPartitions with the
+// client's context.
 func OnAssigned(onAssigned func(context.Context, *Client, map[string][]int32)) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.onAssigned, cfg.setAssigned = onAssigned, true }}
 }
@@ -1220,6 +1342,9 @@ func OnAssigned(onAssigned func(context.Context, *Client, map[string][]int32)) G
 //
 // If you are committing offsets manually (have disabled autocommitting), it is
 // highly recommended to do a proper blocking commit in OnRevoked.
+//
+// For direct consumers, this is called by UnassignPartitions with the client's
+// context. There is no default OnRevoked for direct consumers.
 func OnRevoked(onRevoked func(context.Context, *Client, map[string][]int32)) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.onRevoked, cfg.setRevoked = onRevoked, true }}
 }
@@ -1235,6 +1360,34 @@ func OnLost(onLost func(context.Context, *Client, map[string][]int32)) GroupOpt
 	return groupOpt{func(cfg *cfg) { cfg.onLost, cfg.setLost = onLost, true }}
 }
 
+// OnOffsetCommit sets a function to be called after every offset commit
+// finishes, whether the commit was an autocommit or a manual commit. This is
+// not called for transactional offset commits.
+//
+// The function is called in a new goroutine with the group, the offsets that
+// were attempted to be committed, and the commit error, if any. The error is
+// either the request error or the first partition error in the response.
+// This option is useful for observing commits without changing how commits
+// are issued, which is what AutoCommitCallback is for.
+func OnOffsetCommit(fn func(group string, offsets map[string]map[int32]int64, err error)) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.onOffsetCommit = fn }}
+}
+
+// OnCommitError sets a function to be called whenever an offset commit fails,
+// whether the commit was an autocommit or a manual commit. This is not called
+// for transactional offset commits.
+//
+// The function is called in a new goroutine with the group, the offsets that
+// failed to be committed, and the commit error, which is either the request
+// error or the first partition error in the response. If the commit failed
+// because this member's group session expired, the error matches
+// ErrGroupExpired with errors.Is: the offsets will not be committed, and their
+// records will be redelivered after the group rebalances. Other errors are
+// generally transient and a later commit may succeed.
+func OnCommitError(fn func(group string, offsets map[string]map[int32]int64, err error)) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.onCommitError = fn }}
+}
+
 // DisableAutoCommit disable auto committing.
 //
 // If you disable autocommitting, you may want to use a custom OnRevoked,
@@ -1266,6 +1419,27 @@ func AutoCommitInterval(interval time.Duration) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.autocommitInterval = interval }}
 }
 
+// CommitOffsetOnError sets whether to commit offsets for partitions that had
+// an error in the most recent poll, overriding the default true.
+//
+// A partition can have both records and an error in a single poll, and
+// offsets for a partition may have advanced before a later poll returns an
+// error for it. By default, all uncommitted offsets are committed regardless.
+// If this is false, any partition that had an error in the most recent
+// PollFetches or PollRecords is skipped when committing uncommitted offsets
+// (including autocommitting and the default OnRevoked), and is not returned
+// from UncommittedOffsets. The partition is committed again once a later poll
+// does not have an error for it. Errors the client injects into a poll, such
+// as from failing to list offsets or load epochs, count as errors for their
+// partitions. Polls that return nothing (e.g., a poll whose context is
+// canceled) do not change which partitions are considered errored.
+//
+// PartitionsWithErrors returns the partitions that had an error in the most
+// recent poll.
+func CommitOffsetOnError(commit bool) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.commitOnErrDisable = !commit }}
+}
+
 // InstanceID sets the group consumer's instance ID, switching the group member
 // from "dynamic" to "static".
 //
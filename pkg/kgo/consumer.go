@@ -85,6 +85,11 @@ type consumer struct {
 	// mu is grabbed when
 	//  - polling fetches, for quickly draining sources / updating group uncommitted
 	//  - calling assignPartitions (group / direct updates)
+	//
+	// d and g are set once in init and never changed, so they can be read
+	// without the lock. Exactly one is non-nil: clients that do not
+	// consume in a group are direct consumers, even if they are not yet
+	// consuming anything.
 	mu sync.Mutex
 	d  *directConsumer // if non-nil, we are consuming partitions directly
 	g  *groupConsumer  // if non-nil, we are consuming as a group member
@@ -117,6 +122,9 @@ type consumer struct {
 	sourcesReadyCond        *sync.Cond
 	sourcesReadyForDraining []*source
 	fakeReadyForDraining    []Fetch
+
+	stoppedMu sync.Mutex   // locked to prevent concurrent updates; reads are always atomic
+	stopped   atomic.Value // map[string]map[int32]struct{}, partitions StopFetch was called on
 }
 
 type usedCursors map[*cursor]struct{}
@@ -132,16 +140,18 @@ func (c *consumer) init(cl *Client) {
 	c.cl = cl
 	c.sourcesReadyCond = sync.NewCond(&c.sourcesReadyMu)
 
-	if len(cl.cfg.topics) == 0 && len(cl.cfg.partitions) == 0 {
-		return // not consuming
+	consuming := len(cl.cfg.topics) > 0 || len(cl.cfg.partitions) > 0
+	if consuming {
+		defer cl.triggerUpdateMetadata(true) // we definitely want to trigger a metadata update
 	}
 
-	defer cl.triggerUpdateMetadata(true) // we definitely want to trigger a metadata update
-
-	if len(cl.cfg.group) == 0 {
-		c.initDirect()
-	} else {
+	// A client that is not consuming in a group is a direct consumer,
+	// even if it is not consuming anything yet, so that
+	// AssignAdditionalPartitions never has to set c.d.
+	if consuming && len(cl.cfg.group) > 0 {
 		c.initGroup()
+	} else {
+		c.initDirect()
 	}
 }
 
@@ -217,7 +227,8 @@ func (c *consumer) addFakeReadyForDraining(topic string, partition int32, err er
 }
 
 // PollFetches waits for fetches to be available, returning as soon as any
-// broker returns a fetch. If the ctx quits, this function quits.
+// broker returns a fetch. If the ctx quits, or if the FetchPollTimeout option
+// is used and the timeout is reached, this function quits.
 //
 // It is important to check all partition errors in the returned fetches. If
 // any partition has a fatal error and actually had no records, fake fetch will
@@ -243,6 +254,11 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	if maxPollRecords == 0 {
 		maxPollRecords = -1
 	}
+	if timeout := cl.cfg.pollTimeout; timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	c := &cl.consumer
 
 	var fetches Fetches
@@ -280,14 +296,12 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 			}
 		}
 
-		realFetches := fetches
-
 		fetches = append(fetches, c.fakeReadyForDraining...)
 		c.fakeReadyForDraining = nil
 
 		c.sourcesReadyMu.Unlock()
 
-		if len(realFetches) == 0 {
+		if len(fetches) == 0 {
 			return
 		}
 
@@ -300,8 +314,12 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 		// session to start. If we returned stale fetches that did not
 		// have their uncommitted offset tracked, then we would allow
 		// duplicates.
+		//
+		// Injected fetches have no records, but their errors (such as
+		// from failing to list offsets or load epochs) mark their
+		// partitions as errored.
 		if c.g != nil {
-			c.g.updateUncommitted(realFetches)
+			c.g.updateUncommitted(fetches)
 		}
 	}
 
@@ -346,6 +364,87 @@ func (cl *Client) PollRecords(ctx context.Context, maxPollRecords int) Fetches {
 	return fetches
 }
 
+// StopFetch stops fetching the given topic partition until RestartFetch is
+// called for it. Unlike unassigning a partition, this does not drop the
+// partition's consume position, does not drop records that are already
+// buffered, and does not cause a group rebalance.
+//
+// This is useful to drain and stop a partition: after StopFetch, continue
+// polling to process what was already fetched, and no new records will be
+// fetched for the partition. Note that a fetch request that was in flight
+// when StopFetch was called can still buffer records for the partition.
+//
+// A stopped partition remains stopped across group rebalances and
+// reassignments until RestartFetch is called. Stopping a partition that is
+// not being consumed only means it is not fetched if it is later assigned.
+func (cl *Client) StopFetch(topic string, partition int32) {
+	c := &cl.consumer
+	c.stoppedMu.Lock()
+	defer c.stoppedMu.Unlock()
+
+	stopped := c.loadStopped()
+	if _, exists := stopped[topic][partition]; exists {
+		return
+	}
+	next := c.cloneStopped(stopped)
+	if next[topic] == nil {
+		next[topic] = make(map[int32]struct{})
+	}
+	next[topic][partition] = struct{}{}
+	c.stopped.Store(next)
+}
+
+// RestartFetch restarts fetching a topic partition that was stopped with
+// StopFetch, resuming from where the partition was stopped. This is a no-op
+// if the partition is not stopped.
+func (cl *Client) RestartFetch(topic string, partition int32) {
+	c := &cl.consumer
+	c.stoppedMu.Lock()
+	stopped := c.loadStopped()
+	if _, exists := stopped[topic][partition]; !exists {
+		c.stoppedMu.Unlock()
+		return
+	}
+	next := c.cloneStopped(stopped)
+	delete(next[topic], partition)
+	if len(next[topic]) == 0 {
+		delete(next, topic)
+	}
+	c.stopped.Store(next)
+	c.stoppedMu.Unlock()
+
+	// Any source could own the partition's cursor, and its fetch loop may
+	// have exited with nothing to fetch; we poke every source.
+	cl.sinksAndSourcesMu.Lock()
+	defer cl.sinksAndSourcesMu.Unlock()
+	for _, sns := range cl.sinksAndSources {
+		sns.source.maybeConsume()
+	}
+}
+
+func (c *consumer) loadStopped() map[string]map[int32]struct{} {
+	stopped, _ := c.stopped.Load().(map[string]map[int32]struct{})
+	return stopped
+}
+
+func (*consumer) cloneStopped(stopped map[string]map[int32]struct{}) map[string]map[int32]struct{} {
+	next := make(map[string]map[int32]struct{}, len(stopped)+1)
+	for topic, partitions := range stopped {
+		nextPartitions := make(map[int32]struct{}, len(partitions)+1)
+		for partition := range partitions {
+			nextPartitions[partition] = struct{}{}
+		}
+		next[topic] = nextPartitions
+	}
+	return next
+}
+
+// fetchStopped returns whether StopFetch was called for a topic partition.
+func (c *consumer) fetchStopped(topic string, partition int32) bool {
+	_, stopped := c.loadStopped()[topic][partition]
+	return stopped
+}
+
 // assignHow controls how assignPartitions operates.
 type assignHow int8
 
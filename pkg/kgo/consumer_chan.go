@@ -0,0 +1,90 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+// ConsumeToChannel polls fetches and sends every record to ch, returning
+// once ctx is canceled, the client is closed, or a fetch returns a
+// non-retriable error. Sends block until ch is received from or ctx is
+// canceled, meaning a slow receiver naturally slows polling. This does not
+// close ch.
+//
+// Retriable Kafka errors and data loss errors are informational and are
+// skipped: the client continues consuming the affected partitions internally.
+// Any other fetch error is returned, with records that were polled alongside
+// it having already been sent.
+//
+// If ctx is canceled, this returns the context's error. If the client is
+// closed, this returns ErrClientClosed.
+func (cl *Client) ConsumeToChannel(ctx context.Context, ch chan<- *Record) error {
+	for {
+		fetches := cl.PollFetches(ctx)
+		if fetches.IsClientClosed() {
+			return ErrClientClosed
+		}
+
+		var fatal error
+		for _, f := range fetches {
+			for _, t := range f.Topics {
+				for _, p := range t.Partitions {
+					if p.Err != nil && fatal == nil && !isSkippableFetchErr(p.Err) {
+						fatal = p.Err
+					}
+					for _, r := range p.Records {
+						select {
+						case ch <- r:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+				}
+			}
+		}
+		if fatal != nil {
+			return fatal
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// isSkippableFetchErr returns whether a fetch error can be skipped when
+// consuming to a channel.
+func isSkippableFetchErr(err error) bool {
+	var ke *kerr.Error
+	var dl *ErrDataLoss
+	return errors.As(err, &ke) && ke.Retriable ||
+		errors.As(err, &dl) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// ProduceFromChannel produces every record received from ch, calling fn for
+// each record once it is produced (or fails), as in Produce. fn may be nil.
+// This returns nil once ch is closed, the context's error once ctx is
+// canceled, or ErrClientClosed once the client is closed.
+//
+// Records are produced asynchronously, so when this returns, records may
+// still be buffered. Use Flush to wait for them to finish. Because ctx is
+// passed to Produce, canceling ctx while records are buffered and waiting for
+// space to buffer may fail them with the context's error.
+func (cl *Client) ProduceFromChannel(ctx context.Context, ch <-chan *Record, fn func(*Record, error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-cl.ctx.Done():
+			return ErrClientClosed
+		case r, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			cl.Produce(ctx, r, fn)
+		}
+	}
+}
@@ -1,21 +1,49 @@
 package kgo
 
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
 type directConsumer struct {
 	cfg    *cfg
 	tps    *topicsPartitions             // data for topics that the user assigned
 	reSeen map[string]bool               // topics we evaluated against regex, and whether we want them or not
-	using  map[string]map[int32]struct{} // topics we are currently using (this only grows)
+	using  map[string]map[int32]struct{} // topics we are currently using (this only grows, unless unassigning)
+
+	// partitions begins as a copy of cfg.partitions and is modified in
+	// AssignAdditionalPartitions and UnassignPartitions. unassigned
+	// contains partitions that were explicitly unassigned and must not be
+	// assigned again on metadata updates. Both are guarded by the
+	// consumer mu.
+	partitions map[string]map[int32]Offset
+	unassigned map[string]map[int32]struct{}
 }
 
 func (c *consumer) initDirect() {
 	d := &directConsumer{
-		cfg:    &c.cl.cfg,
-		tps:    newTopicsPartitions(),
-		reSeen: make(map[string]bool),
-		using:  make(map[string]map[int32]struct{}),
+		cfg:        &c.cl.cfg,
+		tps:        newTopicsPartitions(),
+		reSeen:     make(map[string]bool),
+		using:      make(map[string]map[int32]struct{}),
+		partitions: make(map[string]map[int32]Offset),
+		unassigned: make(map[string]map[int32]struct{}),
 	}
 	c.d = d
 
+	for topic, partitions := range d.cfg.partitions {
+		topicPartitions := make(map[int32]Offset, len(partitions))
+		for partition, offset := range partitions {
+			topicPartitions[partition] = offset
+		}
+		d.partitions[topic] = topicPartitions
+	}
+
 	if d.cfg.regex {
 		return
 	}
@@ -54,6 +82,9 @@ func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
 		} else {
 			_, useTopic = d.cfg.topics[topic]
 		}
+		if useTopic && d.cfg.filter != nil && len(d.using[topic]) == 0 {
+			useTopic = d.cfg.filter(topic) // only filter topics we are not yet consuming
+		}
 
 		// If the above detected that we want to keep this topic, we
 		// set all partitions as usable.
@@ -74,7 +105,7 @@ func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
 
 		// Lastly, if this topic has some specific partitions pinned,
 		// we set those.
-		for partition, offset := range d.cfg.partitions[topic] {
+		for partition, offset := range d.partitions[topic] {
 			toUseTopic, exists := toUse[topic]
 			if !exists {
 				toUseTopic = make(map[int32]Offset, 10)
@@ -84,17 +115,23 @@ func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
 		}
 	}
 
-	// With everything we want to consume, remove what we are already.
-	for topic, partitions := range d.using {
-		toUseTopic, exists := toUse[topic]
-		if !exists {
-			continue // metadata update did not return this topic (regex or failing load)
-		}
-		for partition := range partitions {
-			delete(toUseTopic, partition)
-		}
-		if len(toUseTopic) == 0 {
-			delete(toUse, topic)
+	// With everything we want to consume, remove what we are already, as
+	// well as anything that was explicitly unassigned.
+	for _, skip := range []map[string]map[int32]struct{}{
+		d.using,
+		d.unassigned,
+	} {
+		for topic, partitions := range skip {
+			toUseTopic, exists := toUse[topic]
+			if !exists {
+				continue // metadata update did not return this topic (regex or failing load)
+			}
+			for partition := range partitions {
+				delete(toUseTopic, partition)
+			}
+			if len(toUseTopic) == 0 {
+				delete(toUse, topic)
+			}
 		}
 	}
 
@@ -117,3 +154,345 @@ func (d *directConsumer) findNewAssignments() map[string]map[int32]Offset {
 
 	return toUse
 }
+
+// AssignAdditionalPartitions begins consuming the given partitions in
+// addition to any partitions that are already being consumed, starting at the
+// offset configured with ConsumeResetOffset. Partitions that are already
+// assigned are left alone.
+//
+// This is only for directly consuming partitions, and does nothing if the
+// client is consuming as a group member. If the client was not configured to
+// consume anything, this begins directly consuming.
+//
+// If OnAssigned is set, it is called with the newly assigned partitions
+// before any of them begin fetching, and this blocks until it returns. The
+// function is passed the client's context. Direct consumers do not commit
+// offsets; fetches for the new partitions begin once offsets are loaded.
+func (cl *Client) AssignAdditionalPartitions(partitions map[string][]int32) {
+	c := &cl.consumer
+	d := c.d
+	if d == nil {
+		cl.cfg.logger.Log(LogLevelWarn, "ignoring AssignAdditionalPartitions while consuming as a group member")
+		return
+	}
+
+	// We call OnAssigned before storing anything in d.partitions, since
+	// once stored, a concurrent metadata update can begin fetching.
+	c.mu.Lock()
+	added := make(map[string][]int32)
+	for topic, topicPartitions := range partitions {
+		for _, partition := range topicPartitions {
+			_, using := d.using[topic][partition]
+			_, pinned := d.partitions[topic][partition]
+			if !using && !pinned {
+				added[topic] = append(added[topic], partition)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if len(added) > 0 && cl.cfg.onAssigned != nil {
+		cl.cfg.onAssigned(cl.ctx, cl, added)
+	}
+
+	c.mu.Lock()
+	topics := make([]string, 0, len(partitions))
+	for topic, topicPartitions := range partitions {
+		topics = append(topics, topic)
+		for _, partition := range topicPartitions {
+			if unassigned := d.unassigned[topic]; unassigned != nil {
+				delete(unassigned, partition)
+				if len(unassigned) == 0 {
+					delete(d.unassigned, topic)
+				}
+			}
+			if _, using := d.using[topic][partition]; using {
+				continue
+			}
+			dt := d.partitions[topic]
+			if dt == nil {
+				dt = make(map[int32]Offset)
+				d.partitions[topic] = dt
+			}
+			if _, pinned := dt[partition]; !pinned {
+				dt[partition] = d.cfg.resetOffset
+			}
+		}
+	}
+
+	// Anything in topics we already know of can be assigned immediately;
+	// anything else is assigned once the metadata update below loads it.
+	if new := d.findNewAssignments(); len(new) > 0 {
+		c.assignPartitions(new, assignWithoutInvalidating, d.tps)
+	}
+	c.mu.Unlock()
+
+	cl.blockingMetadataFn(func() { d.tps.storeTopics(topics) })
+	cl.triggerUpdateMetadataNow()
+}
+
+// UnassignPartitions stops consuming the given partitions, dropping anything
+// buffered for them. Unassigned partitions are not consumed again unless
+// they are reassigned with AssignAdditionalPartitions, even if they belong to
+// a topic consumed with ConsumeTopics.
+//
+// This is only for directly consuming partitions, and does nothing if the
+// client is consuming as a group member.
+//
+// If OnRevoked is set, it is called with the partitions that were assigned
+// and are now revoked, after they stop fetching, and this blocks until it
+// returns. The function is passed the client's context. Direct consumers do
+// not commit offsets, so any offsets to save must be saved in OnRevoked.
+func (cl *Client) UnassignPartitions(partitions map[string][]int32) {
+	c := &cl.consumer
+	d := c.d
+	if d == nil {
+		cl.cfg.logger.Log(LogLevelWarn, "ignoring UnassignPartitions while consuming as a group member")
+		return
+	}
+
+	c.mu.Lock()
+	revoked := make(map[string][]int32)
+	invalidate := make(map[string]map[int32]Offset, len(partitions))
+	for topic, topicPartitions := range partitions {
+		for _, partition := range topicPartitions {
+			var wasAssigned bool
+			if dt := d.partitions[topic]; dt != nil {
+				_, pinned := dt[partition]
+				wasAssigned = wasAssigned || pinned
+				delete(dt, partition)
+				if len(dt) == 0 {
+					delete(d.partitions, topic)
+				}
+			}
+			if ut := d.using[topic]; ut != nil {
+				_, using := ut[partition]
+				wasAssigned = wasAssigned || using
+				delete(ut, partition)
+				if len(ut) == 0 {
+					delete(d.using, topic)
+				}
+			}
+			if wasAssigned {
+				revoked[topic] = append(revoked[topic], partition)
+			}
+
+			unassigned := d.unassigned[topic]
+			if unassigned == nil {
+				unassigned = make(map[int32]struct{})
+				d.unassigned[topic] = unassigned
+			}
+			unassigned[partition] = struct{}{}
+
+			it := invalidate[topic]
+			if it == nil {
+				it = make(map[int32]Offset)
+				invalidate[topic] = it
+			}
+			it[partition] = Offset{} // dummy offset, see assignInvalidateMatching
+		}
+	}
+
+	if len(invalidate) > 0 {
+		c.assignPartitions(invalidate, assignInvalidateMatching, d.tps)
+	}
+	c.mu.Unlock()
+
+	if len(revoked) > 0 && cl.cfg.onRevoked != nil {
+		cl.cfg.onRevoked(cl.ctx, cl, revoked)
+	}
+}
+
+// FetchOneShot issues a single fetch request for the given topic and
+// partition starting at offset, returning whatever records the partition
+// leader replies with. This does not use or modify any consumer state: the
+// client does not need to be consuming, no offsets are committed, and no
+// fetch session is used. This is useful for one-off reads, such as for
+// auditing or retrieving a specific record.
+//
+// The maxBytes argument limits how much data the broker returns; if it is
+// non-positive, the client's FetchMaxPartitionBytes is used. The fetch does
+// not wait for new data: if nothing exists at the offset, the returned
+// partition has no records.
+//
+// An error is returned if the partition leader cannot be found or if the
+// request fails. Errors for the partition itself, such as
+// OFFSET_OUT_OF_RANGE, are returned in the partition of the returned Fetches.
+func (cl *Client) FetchOneShot(ctx context.Context, topic string, partition int32, offset, maxBytes int64) (Fetches, error) {
+	br, leaderEpoch, err := cl.oneShotLeader(ctx, topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	fp, _, err := cl.oneShotFetch(ctx, br, leaderEpoch, topic, partition, offset, maxBytes, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Fetches{{
+		Topics: []FetchTopic{{
+			Topic:      topic,
+			Partitions: []FetchPartition{fp},
+		}},
+	}}, nil
+}
+
+// ConsumePartitionWithTimeout reads records from the given topic and
+// partition, starting at offset, for up to timeout, returning every record
+// read. Like FetchOneShot, this does not use or modify any consumer state, so
+// it can be used on a client that is not consuming, or on one that is
+// consuming other partitions (or even the same partition).
+//
+// Fetches are issued back to back until the timeout elapses, each waiting up
+// to the client's FetchMaxWait for new records. Reaching the timeout is not an
+// error: the records read so far are returned, which may be none. If ctx is
+// canceled first, the records read so far are returned along with the
+// context's error. A partition error, such as OFFSET_OUT_OF_RANGE, stops
+// reading and is returned with the records read before it.
+func (cl *Client) ConsumePartitionWithTimeout(ctx context.Context, topic string, partition int32, offset int64, timeout time.Duration) ([]*Record, error) {
+	readCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// If the read context is done but the user's context is not, we
+	// reached our timeout, which is not an error.
+	wrapErr := func(err error) error {
+		if readCtx.Err() != nil && ctx.Err() == nil {
+			return nil
+		}
+		return err
+	}
+
+	br, leaderEpoch, err := cl.oneShotLeader(readCtx, topic, partition)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+
+	var records []*Record
+	for {
+		maxWait := cl.cfg.maxWait
+		if deadline, ok := readCtx.Deadline(); ok {
+			if until := time.Until(deadline); until < time.Duration(maxWait)*time.Millisecond {
+				maxWait = int32(until / time.Millisecond)
+			}
+		}
+		if maxWait <= 0 {
+			return records, nil
+		}
+
+		fp, next, err := cl.oneShotFetch(readCtx, br, leaderEpoch, topic, partition, offset, 0, maxWait)
+		records = append(records, fp.Records...)
+		if err != nil {
+			return records, wrapErr(err)
+		}
+		if fp.Err != nil {
+			return records, fp.Err
+		}
+		offset = next
+	}
+}
+
+// oneShotLeader returns the leader broker and leader epoch for a partition
+// for FetchOneShot and ConsumePartitionWithTimeout.
+func (cl *Client) oneShotLeader(ctx context.Context, topic string, partition int32) (*broker, int32, error) {
+	_, meta, err := cl.fetchMetadataForTopics(ctx, false, []string{topic})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	leader, leaderEpoch := int32(-1), int32(-1)
+	for _, t := range meta.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return nil, 0, err
+		}
+		for _, p := range t.Partitions {
+			if p.Partition == partition {
+				if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+					return nil, 0, err
+				}
+				leader, leaderEpoch = p.Leader, p.LeaderEpoch
+			}
+		}
+	}
+	if leader < 0 {
+		return nil, 0, kerr.UnknownTopicOrPartition
+	}
+
+	br, err := cl.brokerOrErr(ctx, leader, errUnknownBroker)
+	if err != nil {
+		return nil, 0, err
+	}
+	return br, leaderEpoch, nil
+}
+
+// oneShotFetch issues a single sessionless fetch for a partition, returning
+// the processed partition and the offset to fetch next.
+func (cl *Client) oneShotFetch(ctx context.Context, br *broker, leaderEpoch int32, topic string, partition int32, offset, maxBytes int64, maxWait int32) (FetchPartition, int64, error) {
+	if maxBytes <= 0 {
+		maxBytes = int64(cl.cfg.maxPartBytes)
+	}
+	if maxBytes > math.MaxInt32 {
+		maxBytes = math.MaxInt32
+	}
+
+	req := kmsg.NewPtrFetchRequest()
+	req.ReplicaID = -1
+	req.MaxWaitMillis = maxWait
+	req.MinBytes = 0
+	if maxWait > 0 {
+		req.MinBytes = 1
+	}
+	req.MaxBytes = int32(maxBytes)
+	req.IsolationLevel = cl.cfg.isolationLevel
+	req.SessionEpoch = -1 // no session
+	reqTopic := kmsg.NewFetchRequestTopic()
+	reqTopic.Topic = topic
+	reqPartition := kmsg.NewFetchRequestTopicPartition()
+	reqPartition.Partition = partition
+	reqPartition.CurrentLeaderEpoch = leaderEpoch
+	reqPartition.FetchOffset = offset
+	reqPartition.LogStartOffset = -1
+	reqPartition.PartitionMaxBytes = int32(maxBytes)
+	reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+	req.Topics = append(req.Topics, reqTopic)
+
+	kresp, err := br.waitResp(ctx, req)
+	if err != nil {
+		return FetchPartition{}, 0, err
+	}
+	resp := kresp.(*kmsg.FetchResponse)
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return FetchPartition{}, 0, err
+	}
+
+	// We process the response with a throwaway cursor so that we reuse
+	// all record batch validation and decoding without touching any real
+	// consumer state.
+	o := &cursorOffsetNext{
+		cursorOffset: cursorOffset{
+			offset:            offset,
+			lastConsumedEpoch: -1,
+		},
+		from: &cursor{
+			topic:       topic,
+			partition:   partition,
+			keepControl: cl.cfg.keepControl,
+		},
+	}
+
+	for i := range resp.Topics {
+		rt := &resp.Topics[i]
+		if rt.Topic != topic {
+			continue
+		}
+		for j := range rt.Partitions {
+			rp := &rt.Partitions[j]
+			if rp.Partition != partition {
+				continue
+			}
+			fp := o.processRespPartition(br, resp.Version, rp, cl.decompressor, cl.hooks.load())
+			return fp, o.offset, nil
+		}
+	}
+	return FetchPartition{}, 0, errors.New("broker did not reply to the requested partition")
+}
@@ -0,0 +1,194 @@
+package kgo_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmock"
+)
+
+// assignments records OnAssigned and OnRevoked calls.
+type assignments struct {
+	mu       sync.Mutex
+	assigned []map[string][]int32
+	revoked  []map[string][]int32
+}
+
+func (a *assignments) opts() []kgo.Opt {
+	return []kgo.Opt{
+		kgo.OnAssigned(func(_ context.Context, _ *kgo.Client, m map[string][]int32) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.assigned = append(a.assigned, m)
+		}),
+		kgo.OnRevoked(func(_ context.Context, _ *kgo.Client, m map[string][]int32) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.revoked = append(a.revoked, m)
+		}),
+	}
+}
+
+// pollValues polls until n records are consumed, returning values by
+// partition.
+func pollValues(ctx context.Context, t *testing.T, cl *kgo.Client, n int) map[int32][]string {
+	t.Helper()
+	got := make(map[int32][]string)
+	for seen := 0; seen < n; {
+		fs := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("timed out after consuming %d of %d records: %v", seen, n, got)
+		}
+		if errs := fs.Errors(); len(errs) > 0 {
+			t.Fatalf("unable to consume: %v", errs)
+		}
+		fs.EachRecord(func(r *kgo.Record) {
+			got[r.Partition] = append(got[r.Partition], string(r.Value))
+			seen++
+		})
+	}
+	return got
+}
+
+func TestAssignAdditionalPartitionsUnassigned(t *testing.T) {
+	t.Parallel()
+
+	b := kmock.NewBroker(t, kmock.SeedTopics(2, "foo"))
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("p0")})
+	b.ExpectFetch("foo", 1, &kgo.Record{Value: []byte("p1")})
+
+	var a assignments
+	cl, err := kgo.NewClient(append(a.opts(),
+		kgo.SeedBrokers(b.Addr()),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.MetadataMaxAge(10*time.Millisecond),
+	)...)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Let the metadata loop run a few times before we begin consuming,
+	// so that the race detector sees its reads of the consumer.
+	time.Sleep(50 * time.Millisecond)
+	cl.AssignAdditionalPartitions(map[string][]int32{"foo": {1}})
+
+	got := pollValues(ctx, t, cl, 1)
+	if exp := map[int32][]string{1: {"p1"}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if exp := []map[string][]int32{{"foo": {1}}}; !reflect.DeepEqual(a.assigned, exp) {
+		t.Errorf("got assigned %v != exp %v", a.assigned, exp)
+	}
+}
+
+func TestAssignAdditionalPartitionsConsuming(t *testing.T) {
+	t.Parallel()
+
+	b := kmock.NewBroker(t, kmock.SeedTopics(3, "foo"))
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("p0")})
+	b.ExpectFetch("foo", 1, &kgo.Record{Value: []byte("p1")})
+	b.ExpectFetch("foo", 2, &kgo.Record{Value: []byte("p2")})
+
+	var a assignments
+	cl, err := kgo.NewClient(append(a.opts(),
+		kgo.SeedBrokers(b.Addr()),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.MetadataMaxAge(10*time.Millisecond),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			"foo": {0: kgo.NewOffset().AtStart()},
+		}),
+	)...)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if got, exp := pollValues(ctx, t, cl, 1), map[int32][]string{0: {"p0"}}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v != exp %v", got, exp)
+	}
+
+	// Partition 0 is already consumed and is not reported as assigned.
+	cl.AssignAdditionalPartitions(map[string][]int32{"foo": {0, 2}})
+	if got, exp := pollValues(ctx, t, cl, 1), map[int32][]string{2: {"p2"}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+
+	// Partition 1 was never assigned and is not reported as revoked.
+	cl.UnassignPartitions(map[string][]int32{"foo": {0, 1}})
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("p0-after")})
+	b.ExpectFetch("foo", 2, &kgo.Record{Value: []byte("p2-after")})
+	if got, exp := pollValues(ctx, t, cl, 1), map[int32][]string{2: {"p2-after"}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if exp := []map[string][]int32{{"foo": {2}}}; !reflect.DeepEqual(a.assigned, exp) {
+		t.Errorf("got assigned %v != exp %v", a.assigned, exp)
+	}
+	if exp := []map[string][]int32{{"foo": {0}}}; !reflect.DeepEqual(a.revoked, exp) {
+		t.Errorf("got revoked %v != exp %v", a.revoked, exp)
+	}
+}
+
+func TestConsumeTopicFilterOnlyUntilConsumed(t *testing.T) {
+	t.Parallel()
+
+	b := kmock.NewBroker(t, kmock.SeedTopics(1, "foo", "bar"))
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("v")})
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(b.Addr()),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.MetadataMaxAge(10*time.Millisecond),
+		kgo.ConsumeTopics("foo", "bar"),
+		kgo.ConsumeTopicFilter(func(topic string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[topic]++
+			return topic == "foo"
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if got, exp := pollValues(ctx, t, cl, 1), map[int32][]string{0: {"v"}}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v != exp %v", got, exp)
+	}
+
+	mu.Lock()
+	fooCalls, barCalls := calls["foo"], calls["bar"]
+	mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond) // a few metadata refreshes
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["foo"] != fooCalls {
+		t.Errorf("filter called for consumed topic foo %d more times", calls["foo"]-fooCalls)
+	}
+	if calls["bar"] <= barCalls {
+		t.Error("filter not called again for unconsumed topic bar")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kerr"
@@ -58,6 +59,10 @@ type groupConsumer struct {
 	//  - read on metadata updates in findNewAssignments
 	leader atomicBool
 
+	// state is the group state as we last knew it, using Kafka's group
+	// state names. This is only for HealthCheck, and is a string.
+	state atomic.Value
+
 	// Set to true when ending a transaction committing transaction
 	// offsets, and then set to false immediately after before calling
 	// EndTransaction.
@@ -86,6 +91,15 @@ type groupConsumer struct {
 	// - read when getting uncommitted or committed
 	uncommitted uncommitted
 
+	// assigned is a copy of nowAssigned for OwnGroupMemberInfo, set after
+	// syncing and cleared when our assignment is revoked or lost.
+	assigned map[string][]int32
+
+	// errored contains the partitions that had an error in the most
+	// recent poll, and is replaced every poll in updateUncommitted. If
+	// commitOnErrDisable, these partitions are not committed.
+	errored map[string][]int32
+
 	// memberID and generation are written to in the join and sync loop,
 	// and mostly read within that loop. The reason these two are under the
 	// mutex is because they are read during commits, which can happen at
@@ -109,6 +123,10 @@ type groupConsumer struct {
 	blockAuto bool
 
 	dying bool // set when closing, read in findNewAssignments
+
+	// leaveDone is created on the first leave and closed once leaving
+	// (including the LeaveGroup request) is complete.
+	leaveDone chan struct{}
 }
 
 // LeaveGroup leaves a group if in one. Calling the client's Close function
@@ -123,10 +141,155 @@ type groupConsumer struct {
 // re-use the same instance ID. To leave a group using an instance ID, you must
 // manually issue a kmsg.LeaveGroupRequest or use an external tool (kafka
 // scripts or kcl).
+//
+// A client cannot switch to consuming in a different group after leaving. To
+// consume in a different group, create a new client, such as with
+// CloneWithOverrides(ConsumerGroup(newGroup)).
 func (cl *Client) LeaveGroup() {
 	cl.consumer.unset()
 }
 
+// GroupDescription is a description of a group, as returned from
+// DescribeOwnGroup.
+type GroupDescription struct {
+	// Group is the group ID.
+	Group string
+	// State is the state of the group, as returned from Kafka (e.g.,
+	// Stable, PreparingRebalance).
+	State string
+	// ProtocolType is the protocol type of the group; for consumer groups,
+	// this is "consumer".
+	ProtocolType string
+	// Protocol is the balance protocol chosen for the group.
+	Protocol string
+	// Generation is the generation this client last joined the group in.
+	// Kafka does not return the generation when describing groups, so
+	// this is from the client's own state.
+	Generation int32
+	// Members contains the members of the group.
+	Members []GroupMemberDescription
+}
+
+// GroupMemberDescription describes a member of a group.
+type GroupMemberDescription struct {
+	// MemberID is the member ID Kafka assigned to the member.
+	MemberID string
+	// InstanceID is the instance ID of the member, if the member is
+	// using static membership.
+	InstanceID *string
+	// ClientID is the client ID of the member.
+	ClientID string
+	// ClientHost is the host of the member, as seen by Kafka.
+	ClientHost string
+	// Assigned is the member's assignment. This is nil if none of this
+	// client's balancers is the group's chosen protocol, or if the
+	// assignment cannot be parsed.
+	Assigned map[string][]int32
+}
+
+// MemberInfo is this client's own view of its group membership, as returned
+// from OwnGroupMemberInfo.
+type MemberInfo struct {
+	// Group is the group ID this client is configured to consume.
+	Group string
+	// MemberID is the member ID Kafka assigned to this client, or empty if
+	// the client has not yet joined.
+	MemberID string
+	// InstanceID is the configured instance ID, if any.
+	InstanceID *string
+	// ClientID is the configured client ID.
+	ClientID string
+	// Generation is the generation this client last joined the group in.
+	Generation int32
+	// Assigned is this client's current assignment, which is nil if the
+	// client is not currently assigned anything.
+	Assigned map[string][]int32
+}
+
+// DescribeOwnGroup issues a DescribeGroups request for the group this client
+// is consuming as a member of, returning ErrNotInGroup if the client is not
+// consuming as a group member.
+//
+// Member assignments are parsed with the client's balancer that matches the
+// group's chosen protocol.
+func (cl *Client) DescribeOwnGroup(ctx context.Context) (*GroupDescription, error) {
+	g := cl.consumer.g
+	if g == nil {
+		return nil, ErrNotInGroup
+	}
+
+	described, err := cl.describeGroup(ctx, g.cfg.group)
+	if err != nil {
+		return nil, err
+	}
+
+	var balancer GroupBalancer
+	for _, b := range g.cfg.balancers {
+		if b.ProtocolName() == described.Protocol {
+			balancer = b
+			break
+		}
+	}
+
+	g.mu.Lock()
+	generation := g.generation
+	g.mu.Unlock()
+
+	desc := &GroupDescription{
+		Group:        described.Group,
+		State:        described.State,
+		ProtocolType: described.ProtocolType,
+		Protocol:     described.Protocol,
+		Generation:   generation,
+	}
+	for _, member := range described.Members {
+		m := GroupMemberDescription{
+			MemberID:   member.MemberID,
+			InstanceID: member.InstanceID,
+			ClientID:   member.ClientID,
+			ClientHost: member.ClientHost,
+		}
+		if balancer != nil && len(member.MemberAssignment) > 0 {
+			if assigned, err := balancer.ParseSyncAssignment(member.MemberAssignment); err == nil {
+				m.Assigned = assigned
+			}
+		}
+		desc.Members = append(desc.Members, m)
+	}
+	return desc, nil
+}
+
+// OwnGroupMemberInfo returns this client's locally cached group membership
+// info without issuing any request. If the client is not consuming as a
+// group member, this returns the zero MemberInfo.
+func (cl *Client) OwnGroupMemberInfo() MemberInfo {
+	g := cl.consumer.g
+	if g == nil {
+		return MemberInfo{}
+	}
+
+	info := MemberInfo{
+		Group:      g.cfg.group,
+		InstanceID: g.cfg.instanceID,
+	}
+	if g.cfg.id != nil {
+		info.ClientID = *g.cfg.id
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info.MemberID = g.memberID
+	info.Generation = g.generation
+	if g.assigned != nil {
+		info.Assigned = make(map[string][]int32, len(g.assigned))
+		for topic, partitions := range g.assigned {
+			info.Assigned[topic] = append([]int32(nil), partitions...)
+		}
+	}
+	return info
+}
+
 func (c *consumer) initGroup() {
 	ctx, cancel := context.WithCancel(c.cl.ctx)
 	g := &groupConsumer{
@@ -193,8 +356,11 @@ func (g *groupConsumer) manage() {
 	g.cl.cfg.logger.Log(LogLevelInfo, "beginning to manage the group lifecycle")
 
 	var consecutiveErrors int
+	reason := "join"
 	for {
+		start := time.Now()
 		err := g.joinAndSync()
+		g.hookRebalance(reason, time.Since(start), err)
 		if err == nil {
 			if err = g.setupAssignedAndHeartbeat(); err != nil {
 				if err == kerr.RebalanceInProgress {
@@ -204,11 +370,13 @@ func (g *groupConsumer) manage() {
 		}
 		if err == nil {
 			consecutiveErrors = 0
+			reason = "rebalance"
 			continue
 		}
+		reason = "error"
 
 		hook := func() {
-			g.cl.cfg.hooks.each(func(h Hook) {
+			g.cl.hooks.each(func(h Hook) {
 				if h, ok := h.(HookGroupManageError); ok {
 					h.OnGroupManageError(err)
 				}
@@ -250,6 +418,7 @@ func (g *groupConsumer) manage() {
 			g.mu.Lock()     // before allowing poll to touch uncommitted, lock the group
 			g.c.mu.Unlock() // now part of poll can continue
 			g.uncommitted = nil
+			g.assigned = nil
 			g.mu.Unlock()
 
 			g.nowAssigned = nil
@@ -259,6 +428,7 @@ func (g *groupConsumer) manage() {
 		}
 
 		if err == context.Canceled { // context was canceled, quit now
+			g.state.Store("Dead")
 			return
 		}
 
@@ -277,12 +447,22 @@ func (g *groupConsumer) manage() {
 		select {
 		case <-g.ctx.Done():
 			after.Stop()
+			g.state.Store("Dead")
 			return
 		case <-after.C:
 		}
 	}
 }
 
+// loadState returns the group state as we last knew it, or "Empty" if we have
+// not yet begun joining.
+func (g *groupConsumer) loadState() string {
+	if state, ok := g.state.Load().(string); ok {
+		return state
+	}
+	return "Empty"
+}
+
 func (g *groupConsumer) leave() (wait func()) {
 	// If g.using is nonzero before this check, then a manage goroutine has
 	// started. If not, it will never start because we set dying.
@@ -290,9 +470,18 @@ func (g *groupConsumer) leave() (wait func()) {
 	wasDead := g.dying
 	g.dying = true
 	wasManaging := len(g.using) > 0
+	if !wasDead {
+		g.leaveDone = make(chan struct{})
+	}
+	done := g.leaveDone
 	g.mu.Unlock()
 
-	done := make(chan struct{})
+	if wasDead {
+		// If we already called leave(), then we just wait for the
+		// prior leave to finish and we avoid re-issuing a LeaveGroup
+		// request.
+		return func() { <-done }
+	}
 
 	go func() {
 		defer close(done)
@@ -305,13 +494,6 @@ func (g *groupConsumer) leave() (wait func()) {
 			<-g.manageDone
 		}
 
-		if wasDead {
-			// If we already called leave(), then we just wait for
-			// the prior leave to finish and we avoid re-issuing a
-			// LeaveGroup request.
-			return
-		}
-
 		if g.cfg.instanceID == nil {
 			g.cl.cfg.logger.Log(LogLevelInfo,
 				"leaving group",
@@ -434,6 +616,7 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32, leavi
 		// to do that outside the context of a live group session.
 		g.mu.Lock()
 		g.uncommitted = nil
+		g.assigned = nil
 		g.mu.Unlock()
 		return
 	}
@@ -582,6 +765,15 @@ func (s *assignRevokeSession) revoke(g *groupConsumer, leaving bool) <-chan stru
 	return s.revokeDone
 }
 
+// hookRebalance calls all HookGroupRebalance hooks after a join and sync.
+func (g *groupConsumer) hookRebalance(reason string, rebalanceDur time.Duration, err error) {
+	g.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookGroupRebalance); ok {
+			h.OnGroupRebalance(g.cfg.group, reason, rebalanceDur, err)
+		}
+	})
+}
+
 // This chunk of code "pre" revokes lost partitions for the cooperative
 // consumer and then begins heartbeating while fetching offsets. This returns
 // when heartbeating errors (or if fetch offsets errors).
@@ -595,6 +787,8 @@ func (g *groupConsumer) setupAssignedAndHeartbeat() error {
 	hbErrCh := make(chan error, 1)
 	fetchErrCh := make(chan error, 1)
 
+	g.state.Store("Stable")
+
 	s := newAssignRevokeSession()
 	added, lost := g.diffAssigned()
 	g.cl.cfg.logger.Log(LogLevelInfo, "new group session begun", "added", added, "lost", lost)
@@ -829,6 +1023,7 @@ func (g *groupConsumer) rejoin() {
 func (g *groupConsumer) joinAndSync() error {
 	g.cl.cfg.logger.Log(LogLevelInfo, "joining group")
 	g.leader.set(false)
+	g.state.Store("PreparingRebalance")
 
 start:
 	select {
@@ -890,6 +1085,7 @@ start:
 		synced   = make(chan struct{})
 	)
 
+	g.state.Store("CompletingRebalance")
 	g.cl.cfg.logger.Log(LogLevelInfo, "syncing", "protocol_type", g.cfg.protocol, "protocol", protocol)
 	go func() {
 		defer close(synced)
@@ -1004,6 +1200,13 @@ func (g *groupConsumer) handleSyncResp(protocol string, resp *kmsg.SyncGroupResp
 	}
 	g.nowAssigned = assigned
 	g.cl.cfg.logger.Log(LogLevelInfo, "synced successfully", "assigned", g.nowAssigned)
+
+	g.mu.Lock()
+	g.assigned = make(map[string][]int32, len(assigned))
+	for topic, partitions := range assigned {
+		g.assigned[topic] = append([]int32(nil), partitions...)
+	}
+	g.mu.Unlock()
 	return nil
 }
 
@@ -1135,8 +1338,9 @@ start:
 				Offset: offset.at,
 			}
 			topicUncommitted[partition] = uncommit{
-				head:      committed,
-				committed: committed,
+				head:         committed,
+				committed:    committed,
+				hasCommitted: true,
 			}
 		}
 	}
@@ -1198,6 +1402,9 @@ func (g *groupConsumer) findNewAssignments() {
 		} else {
 			_, useTopic = g.cfg.topics[topic]
 		}
+		if useTopic && g.cfg.filter != nil {
+			useTopic = g.cfg.filter(topic)
+		}
 
 		// We only track using the topic if there are partitions for
 		// it; if there are none, then the topic was set by _us_ as "we
@@ -1245,6 +1452,10 @@ func (g *groupConsumer) findNewAssignments() {
 type uncommit struct {
 	head      EpochOffset
 	committed EpochOffset
+
+	// hasCommitted is whether committed was set from a commit or from
+	// fetching offsets, since 0 at epoch 0 is a valid committed offset.
+	hasCommitted bool
 }
 
 // EpochOffset combines a record offset with the leader epoch the broker
@@ -1264,6 +1475,8 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	g.errored = nil
+
 	for _, fetch := range fetches {
 		for _, topic := range fetch.Topics {
 
@@ -1273,6 +1486,12 @@ func (g *groupConsumer) updateUncommitted(fetches Fetches) {
 
 			var topicOffsets map[int32]uncommit
 			for _, partition := range topic.Partitions {
+				if partition.Err != nil {
+					if g.errored == nil {
+						g.errored = make(map[string][]int32)
+					}
+					g.errored[topic.Topic] = append(g.errored[topic.Topic], partition.Partition)
+				}
 				if len(partition.Records) == 0 {
 					continue
 				}
@@ -1395,6 +1614,7 @@ func (g *groupConsumer) updateCommitted(
 				reqPart.LeaderEpoch,
 				reqPart.Offset,
 			}
+			uncommit.hasCommitted = true
 			topic[respPart.Partition] = uncommit
 		}
 
@@ -1517,6 +1737,7 @@ func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
 			current, exists := topicUncommitted[partition]
 			if exists && current.head == epochOffset {
 				current.committed = epochOffset
+				current.hasCommitted = true
 				topicUncommitted[partition] = current
 				continue
 			}
@@ -1528,8 +1749,9 @@ func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
 				epoch: epochOffset.Epoch,
 			}
 			topicUncommitted[partition] = uncommit{
-				head:      epochOffset,
-				committed: epochOffset,
+				head:         epochOffset,
+				committed:    epochOffset,
+				hasCommitted: true,
 			}
 		}
 		if len(topicAssigns) > 0 {
@@ -1581,6 +1803,61 @@ func (cl *Client) CommittedOffsets() map[string]map[int32]EpochOffset {
 	return g.getUncommittedLocked(false)
 }
 
+// GroupOffset returns the committed offset for the given topic and partition
+// from the client's in memory cache. The cache is updated from successful
+// offset commits and from fetching offsets when joining a group.
+//
+// This returns false if the client is not consuming as a group, if the
+// partition is not assigned to this member, or if nothing has been committed
+// for the partition yet.
+func (cl *Client) GroupOffset(topic string, partition int32) (committed int64, ok bool) {
+	g := cl.consumer.g
+	if g == nil {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	uncommit, exists := g.uncommitted[topic][partition]
+	if !exists || !uncommit.hasCommitted {
+		return 0, false
+	}
+	return uncommit.committed.Offset, true
+}
+
+// AllGroupOffsets returns a snapshot of all cached committed offsets, as
+// described in GroupOffset. Partitions that have not yet been committed are
+// not included.
+//
+// If there are no committed offsets, this returns nil.
+func (cl *Client) AllGroupOffsets() map[string]map[int32]int64 {
+	g := cl.consumer.g
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var offsets map[string]map[int32]int64
+	for topic, partitions := range g.uncommitted {
+		for partition, uncommit := range partitions {
+			if !uncommit.hasCommitted {
+				continue
+			}
+			if offsets == nil {
+				offsets = make(map[string]map[int32]int64, len(g.uncommitted))
+			}
+			topicOffsets := offsets[topic]
+			if topicOffsets == nil {
+				topicOffsets = make(map[int32]int64, len(partitions))
+				offsets[topic] = topicOffsets
+			}
+			topicOffsets[partition] = uncommit.committed.Offset
+		}
+	}
+	return offsets
+}
+
 func (g *groupConsumer) getUncommitted() map[string]map[int32]EpochOffset {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -1599,6 +1876,9 @@ func (g *groupConsumer) getUncommittedLocked(head bool) map[string]map[int32]Epo
 			if head && uncommit.head == uncommit.committed {
 				continue
 			}
+			if head && g.cfg.commitOnErrDisable && g.isErroredLocked(topic, partition) {
+				continue
+			}
 			if topicUncommitted == nil {
 				if uncommitted == nil {
 					uncommitted = make(map[string]map[int32]EpochOffset, len(g.uncommitted))
@@ -1619,10 +1899,50 @@ func (g *groupConsumer) getUncommittedLocked(head bool) map[string]map[int32]Epo
 	return uncommitted
 }
 
+func (g *groupConsumer) isErroredLocked(topic string, partition int32) bool {
+	for _, p := range g.errored[topic] {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// PartitionsWithErrors returns the partitions that had an error in the most
+// recent PollFetches or PollRecords. If CommitOffsetOnError(false) is used,
+// these partitions are not being committed.
+//
+// If the client is not consuming as a group member, or if no partitions had
+// errors, this returns nil.
+func (cl *Client) PartitionsWithErrors() map[string][]int32 {
+	g := cl.consumer.g
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.errored == nil {
+		return nil
+	}
+	errored := make(map[string][]int32, len(g.errored))
+	for topic, partitions := range g.errored {
+		errored[topic] = append([]int32(nil), partitions...)
+	}
+	return errored
+}
+
 // CommitRecords issues a synchronous offset commit for the offsets contained
 // within rs. Retriable errors are retried up to the configured retry limit,
 // and any unretriable error is returned.
 //
+// For each partition, this commits one past the offset of the latest record
+// in rs for that partition. As with all commits, the committed offset is the
+// offset to resume consuming from, meaning the given records are considered
+// processed and are not consumed again after a restart or rebalance. This
+// makes CommitRecords a safe checkpoint for at least once processing: only
+// pass records that have been fully processed.
+//
 // This function is useful as a simple way to commit offsets if you have
 // disabled autocommitting. As an alternative if you always want to commit
 // everything, see CommitUncommittedOffsets.
@@ -1667,6 +1987,15 @@ func (cl *Client) CommitRecords(ctx context.Context, rs ...*Record) error {
 		}
 	}
 
+	// We commit to the offset to consume next, which is just past each
+	// partition's latest record (see the uncommit type).
+	for _, toffsets := range offsets {
+		for partition, at := range toffsets {
+			at.Offset++
+			toffsets[partition] = at
+		}
+	}
+
 	var rerr error // return error
 
 	// Our client retries an OffsetCommitRequest as necessary if the first
@@ -1758,7 +2087,7 @@ func (cl *Client) CommitOffsetsSync(
 
 	g := cl.consumer.g
 	if g == nil {
-		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), errNotGroup)
+		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), ErrNotInGroup)
 		return
 	}
 	if len(uncommitted) == 0 {
@@ -1854,7 +2183,7 @@ func (cl *Client) CommitOffsets(
 
 	g := cl.consumer.g
 	if g == nil {
-		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), errNotGroup)
+		onDone(cl, new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), ErrNotInGroup)
 		return
 	}
 	if len(uncommitted) == 0 {
@@ -1973,7 +2302,15 @@ func (g *groupConsumer) commit(
 			}
 		}
 
+		start := time.Now()
 		resp, err := req.RequestWith(commitCtx, g.cl)
+		commitDur := time.Since(start)
+		g.cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookGroupOffsetCommit); ok {
+				h.OnGroupOffsetCommit(g.cfg.group, req, resp, commitDur, err)
+			}
+		})
+		g.notifyOffsetCommit(uncommitted, resp, err)
 		if err != nil {
 			onDone(g.cl, req, nil, err)
 			return
@@ -1982,3 +2319,47 @@ func (g *groupConsumer) commit(
 		onDone(g.cl, req, resp, nil)
 	}()
 }
+
+// notifyOffsetCommit calls the user's OnOffsetCommit function and, if the
+// commit failed, OnCommitError function, if any, in new goroutines.
+func (g *groupConsumer) notifyOffsetCommit(
+	uncommitted map[string]map[int32]EpochOffset,
+	resp *kmsg.OffsetCommitResponse,
+	err error,
+) {
+	onCommit, onErr := g.cfg.onOffsetCommit, g.cfg.onCommitError
+	if onCommit == nil && onErr == nil {
+		return
+	}
+	if err == nil {
+	outer:
+		for _, t := range resp.Topics {
+			for _, p := range t.Partitions {
+				if err = kerr.ErrorForCode(p.ErrorCode); err != nil {
+					break outer
+				}
+			}
+		}
+	}
+	offsets := func() map[string]map[int32]int64 {
+		offsets := make(map[string]map[int32]int64, len(uncommitted))
+		for topic, partitions := range uncommitted {
+			topicOffsets := make(map[int32]int64, len(partitions))
+			for partition, eo := range partitions {
+				topicOffsets[partition] = eo.Offset
+			}
+			offsets[topic] = topicOffsets
+		}
+		return offsets
+	}
+	if onCommit != nil {
+		go onCommit(g.cfg.group, offsets(), err)
+	}
+	if onErr != nil && err != nil {
+		switch err {
+		case kerr.UnknownMemberID, kerr.IllegalGeneration, kerr.FencedInstanceID:
+			err = &errGroupExpired{err}
+		}
+		go onErr(g.cfg.group, offsets(), err)
+	}
+}
@@ -0,0 +1,50 @@
+package kgo
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestGroupOffsetCommittedAtZero(t *testing.T) {
+	t.Parallel()
+
+	cl := new(Client)
+	cl.consumer.g = &groupConsumer{
+		uncommitted: uncommitted{
+			"foo": {
+				0: {committed: EpochOffset{0, 0}, hasCommitted: true}, // committed at the start
+				1: {head: EpochOffset{0, 5}},                          // consumed, never committed
+			},
+		},
+	}
+
+	if offset, ok := cl.GroupOffset("foo", 0); !ok || offset != 0 {
+		t.Errorf("got (%d, %v) for partition 0, exp (0, true)", offset, ok)
+	}
+	if offset, ok := cl.GroupOffset("foo", 1); ok {
+		t.Errorf("got (%d, %v) for partition 1, exp (0, false)", offset, ok)
+	}
+	if got, exp := cl.AllGroupOffsets(), map[string]map[int32]int64{"foo": {0: 0}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+}
+
+func TestInjectedErrorsMarkErrored(t *testing.T) {
+	t.Parallel()
+
+	cl := &Client{cfg: defaultCfg()}
+	cl.consumer.sourcesReadyCond = sync.NewCond(&cl.consumer.sourcesReadyMu)
+	cl.consumer.g = &groupConsumer{cl: cl, cfg: &cl.cfg}
+
+	// A failed ListOffsets or epoch load is injected as a fake fetch.
+	cl.consumer.addFakeReadyForDraining("foo", 1, kerr.UnknownServerError)
+	cl.PollFetches(context.Background())
+
+	if got, exp := cl.PartitionsWithErrors(), map[string][]int32{"foo": {1}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got errored %v != exp %v", got, exp)
+	}
+}
@@ -0,0 +1,89 @@
+package kgo
+
+import (
+	"context"
+	"fmt"
+)
+
+// IterableConsumer wraps a consuming client to provide iterators over polled
+// records. The iterators have the signatures of Go 1.23's iter.Seq and
+// iter.Seq2, so with Go 1.23+ they can be used in range loops:
+//
+//	it := kgo.NewIterableConsumer(cl)
+//	for r := range it.All(ctx) {
+//	        // process r
+//	}
+//
+// This type does not import the iter package, so it can be used (by calling
+// the iterator functions directly) with older Go versions as well.
+type IterableConsumer struct {
+	cl *Client
+}
+
+// NewIterableConsumer returns an IterableConsumer for the given client. The
+// client must already be configured to consume.
+func NewIterableConsumer(cl *Client) *IterableConsumer {
+	return &IterableConsumer{cl: cl}
+}
+
+// All returns an iterator that polls fetches and yields every record. Fetch
+// errors are skipped; use WithErrors to observe them. Iteration stops once
+// ctx is canceled or the client is closed.
+//
+// If the loop body breaks (yield returns false), iteration stops without
+// closing the client. Any remaining records from the most recent poll are not
+// yielded, but they were still polled: if the client is autocommitting, their
+// offsets can be committed.
+func (c *IterableConsumer) All(ctx context.Context) func(yield func(*Record) bool) {
+	return func(yield func(*Record) bool) {
+		c.WithErrors(ctx)(func(r *Record, err error) bool {
+			if err != nil {
+				return true
+			}
+			return yield(r)
+		})
+	}
+}
+
+// WithErrors returns an iterator that polls fetches and yields every record
+// with a nil error, and every fetch error with a nil record. Errors include
+// the topic and partition they occurred on and wrap the original error.
+//
+// If the client is closed, ErrClientClosed is yielded and iteration stops.
+// If ctx is canceled, the context's error is yielded and iteration stops.
+// The same caveat about breaking early applies as in All.
+func (c *IterableConsumer) WithErrors(ctx context.Context) func(yield func(*Record, error) bool) {
+	return func(yield func(*Record, error) bool) {
+		for {
+			// We check ctx before polling rather than after: if records
+			// are always buffered, polling returns them even after ctx
+			// is canceled, and we would never stop.
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			fetches := c.cl.PollFetches(ctx)
+			if fetches.IsClientClosed() {
+				yield(nil, ErrClientClosed)
+				return
+			}
+
+			for _, f := range fetches {
+				for _, t := range f.Topics {
+					for _, p := range t.Partitions {
+						if p.Err != nil {
+							if !yield(nil, fmt.Errorf("topic %s partition %d: %w", t.Topic, p.Partition, p.Err)) {
+								return
+							}
+						}
+						for _, r := range p.Records {
+							if !yield(r, nil) {
+								return
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
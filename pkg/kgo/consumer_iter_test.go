@@ -0,0 +1,76 @@
+package kgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmock"
+)
+
+func TestIterableConsumerCancelWhileFetching(t *testing.T) {
+	t.Parallel()
+
+	b := kmock.NewBroker(t)
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("v")})
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(b.Addr()),
+		kgo.ConsumeTopics("foo"),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// Keep records arriving for the whole test, so that there is always
+	// something buffered to poll.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("v")})
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		var n int
+		var last error
+		kgo.NewIterableConsumer(cl).WithErrors(ctx)(func(_ *kgo.Record, err error) bool {
+			if err != nil {
+				last = err
+				return true
+			}
+			if n++; n == 5 {
+				cancel()
+			}
+			if n >= 5 {
+				// Processing slowly after canceling ensures more
+				// records are buffered by the time we poll again.
+				time.Sleep(5 * time.Millisecond)
+			}
+			return true
+		})
+		done <- last
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got last err %v, exp context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("iteration did not stop after ctx was canceled")
+	}
+}
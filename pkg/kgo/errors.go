@@ -70,10 +70,6 @@ var (
 	// that the broker cannot handle the request to-be-issued request.
 	errBrokerTooOld = errors.New("broker is too old; the broker has already indicated it will not know how to handle the request")
 
-	// Returned when trying to call group functions when the client is not
-	// assigned a group.
-	errNotGroup = errors.New("invalid group function call when not assigned a group")
-
 	// Returned when trying to begin a transaction with a client that does
 	// not have a transactional ID.
 	errNotTransactional = errors.New("invalid attempt to begin a transaction with a non-transactional client")
@@ -95,9 +91,22 @@ var (
 	// EXTERNAL //
 	//////////////
 
+	// ErrProduceBufferFull is matched with errors.Is for any record that
+	// fails because the client already has MaxBufferedRecords buffered.
+	// This is application level back-pressure, as opposed to a failure to
+	// write to Kafka. Records fail for this reason in two cases:
+	//
+	//   - with ManualFlushing, producing fails immediately with
+	//     ErrMaxBuffered, which wraps this error
+	//   - otherwise, Produce waits for buffer space; if the produce
+	//     context is done first, the record fails with an error that
+	//     matches both this and the context error
+	ErrProduceBufferFull = errors.New("the maximum amount of records are buffered")
+
 	// ErrMaxBuffered is returned when producing with manual flushing
-	// enabled and the maximum amount of records are buffered.
-	ErrMaxBuffered = errors.New("manual flushing is enabled and the maximum amount of records are buffered, cannot buffer more")
+	// enabled and the maximum amount of records are buffered. This wraps
+	// ErrProduceBufferFull.
+	ErrMaxBuffered = fmt.Errorf("%w: manual flushing is enabled, cannot buffer more", ErrProduceBufferFull)
 
 	// ErrAborting is returned for all buffered records while
 	// AbortBufferedRecords is being called.
@@ -113,6 +122,23 @@ var (
 	//
 	// For any request, the request is failed with this error.
 	ErrClientClosed = errors.New("client closed")
+
+	// ErrProducerFenced is returned for all records produced after the
+	// client's producer ID has been fenced. See OnProducerFenced.
+	ErrProducerFenced = errors.New("producer was fenced; a newer producer with the same transactional ID is active")
+
+	// ErrNotInGroup is returned when trying to call group functions when
+	// the client is not assigned a group.
+	ErrNotInGroup = errors.New("invalid group function call when not assigned a group")
+
+	// ErrGroupExpired is matched by commit errors passed to OnCommitError
+	// when the commit failed because this member is no longer part of the
+	// group generation it committed with (Kafka replied with
+	// UNKNOWN_MEMBER_ID, ILLEGAL_GENERATION, or FENCED_INSTANCE_ID). The
+	// offsets will not be committed by a retry; records since the last
+	// successful commit will be redelivered to whichever member is next
+	// assigned the partitions.
+	ErrGroupExpired = errors.New("group session expired, offsets could not be committed")
 )
 
 // ErrDataLoss is returned for Kafka >=2.1.0 when data loss is detected and the
@@ -136,6 +162,92 @@ func (e *ErrDataLoss) Error() string {
 		e.Topic, e.Partition, e.ConsumedTo, e.ResetTo)
 }
 
+// ProduceError is the error a record's promise is called with when Kafka
+// replied to a produce request with an error for the record's partition, and
+// the client could not (or will no longer) retry the error. This can be
+// extracted with errors.As:
+//
+//	var pe ProduceError
+//	if errors.As(err, &pe) {
+//	        // use pe.BrokerID, pe.KafkaCode, ...
+//	}
+//
+// ProduceError unwraps to the underlying *kerr.Error, so errors.Is(err,
+// kerr.NotLeaderForPartition) and kerr.IsRetriable still work. Because the
+// Kafka error is wrapped, comparing a record's error directly, as in
+// err == kerr.NotLeaderForPartition, does not work; use errors.Is instead.
+// Records that fail for reasons other than a Kafka reply, such as exhausted
+// retries after connection errors or a canceled context, are not failed with
+// a ProduceError.
+//
+// Note that when one batch fails, every other record buffered for the
+// partition is failed with the same error to keep sequence numbers correct.
+// Each record's ProduceError contains that record.
+type ProduceError struct {
+	// Record is the record that failed.
+	Record *Record
+	// BrokerID is the ID of the broker that rejected the produce request.
+	BrokerID int32
+	// APIKey is the key of the request that failed, which is always the
+	// produce request key (0).
+	APIKey int16
+	// KafkaCode is the Kafka error code for the partition.
+	KafkaCode int16
+	// Message is the error's message.
+	Message string
+	// Err is the underlying error, which is usually a *kerr.Error.
+	Err error
+}
+
+func (e ProduceError) Error() string {
+	if e.Record == nil {
+		return fmt.Sprintf("produce to broker %d failed: %s", e.BrokerID, e.Message)
+	}
+	return fmt.Sprintf("produce to broker %d for topic %s partition %d failed: %s",
+		e.BrokerID, e.Record.Topic, e.Record.Partition, e.Message)
+}
+
+// Unwrap returns the underlying error.
+func (e ProduceError) Unwrap() error { return e.Err }
+
+// ErrUnknownHook is returned from AddHook if the hook does not implement any
+// hook interface in this package.
+type ErrUnknownHook struct {
+	// Hook is the hook that was attempted to be added.
+	Hook Hook
+}
+
+func (e *ErrUnknownHook) Error() string {
+	return fmt.Sprintf("hook of type %T does not implement any known hook interface", e.Hook)
+}
+
+// errBufferFull is used when waiting for buffer space is canceled by the
+// produce context. This matches ErrProduceBufferFull and unwraps to the
+// context error.
+type errBufferFull struct {
+	ctxErr error
+}
+
+func (e *errBufferFull) Error() string {
+	return fmt.Sprintf("%s: %s", ErrProduceBufferFull, e.ctxErr)
+}
+
+func (e *errBufferFull) Unwrap() error      { return e.ctxErr }
+func (*errBufferFull) Is(target error) bool { return target == ErrProduceBufferFull }
+
+// errGroupExpired wraps a commit error that indicates the group session
+// expired. This matches ErrGroupExpired and unwraps to the Kafka error.
+type errGroupExpired struct {
+	err error
+}
+
+func (e *errGroupExpired) Error() string {
+	return fmt.Sprintf("%s: %s", ErrGroupExpired, e.err)
+}
+
+func (e *errGroupExpired) Unwrap() error      { return e.err }
+func (*errGroupExpired) Is(target error) bool { return target == ErrGroupExpired }
+
 type errUnknownController struct {
 	id int32
 }
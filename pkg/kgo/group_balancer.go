@@ -2,6 +2,7 @@ package kgo
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -357,6 +358,140 @@ func (g *groupConsumer) balanceGroup(proto string, members []kmsg.JoinGroupRespo
 	return into.IntoSyncAssignment(), nil
 }
 
+// SimulateGroupBalance describes the given group, adds additionalMembers
+// simulated members to the group's current members, and runs the given
+// balancer over all members. This returns the topics and partitions that
+// would be assigned to the simulated members, merged into one map.
+//
+// This function is useful for predicting how adding consumers to a group will
+// affect the group's assignment before actually deploying them. Simulated
+// members are interested in the same topics that the current members are
+// interested in, and, as new members, they own no partitions. The current
+// members use the metadata they joined the group with, meaning cooperative
+// balancers will see what each member currently owns.
+//
+// The group, if it has members, must use the "consumer" protocol type, and the
+// balancer must be able to parse the metadata that the group's members joined
+// with. This does not join the group, nor does this affect the group in any
+// way.
+func (cl *Client) SimulateGroupBalance(ctx context.Context, group string, additionalMembers int, balancer GroupBalancer) (map[string][]int32, error) {
+	if additionalMembers < 0 {
+		return nil, fmt.Errorf("invalid negative number of additional members %d", additionalMembers)
+	}
+
+	described, err := cl.describeGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if described.State == "Dead" {
+		return nil, fmt.Errorf("group %s does not exist", group)
+	}
+
+	return simulateGroupBalance(described, additionalMembers, balancer, func(topics []string) (map[string]int32, error) {
+		_, meta, err := cl.fetchMetadataForTopics(ctx, false, topics)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch metadata for group topics: %v", err)
+		}
+		topicPartitionCount := make(map[string]int32, len(meta.Topics))
+		for i := range meta.Topics {
+			t := &meta.Topics[i]
+			if t.ErrorCode != 0 {
+				continue
+			}
+			topicPartitionCount[t.Topic] = int32(len(t.Partitions))
+		}
+		return topicPartitionCount, nil
+	})
+}
+
+// simulateGroupBalance is the logic of SimulateGroupBalance after the group
+// is described, with partitionCounts returning the number of partitions in
+// each of the given topics.
+func simulateGroupBalance(
+	described *kmsg.DescribeGroupsResponseGroup,
+	additionalMembers int,
+	balancer GroupBalancer,
+	partitionCounts func([]string) (map[string]int32, error),
+) (map[string][]int32, error) {
+	// We can only decode member metadata if the members joined with
+	// the standard consumer protocol.
+	if len(described.Members) > 0 && described.ProtocolType != "consumer" {
+		return nil, fmt.Errorf("group %s uses protocol type %q, not %q", described.Group, described.ProtocolType, "consumer")
+	}
+
+	members := make([]kmsg.JoinGroupResponseMember, 0, len(described.Members)+additionalMembers)
+	for _, member := range described.Members {
+		members = append(members, kmsg.JoinGroupResponseMember{
+			MemberID:         member.MemberID,
+			InstanceID:       member.InstanceID,
+			ProtocolMetadata: member.ProtocolMetadata,
+		})
+	}
+
+	// We need the interests of the current members so that our simulated
+	// members can join with the same interests.
+	var interests []string
+	if len(members) > 0 {
+		sortJoinMembers(members)
+		_, topics, err := balancer.MemberBalancer(members)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create group member balancer for current members: %v", err)
+		}
+		for topic := range topics {
+			interests = append(interests, topic)
+		}
+		sort.Strings(interests)
+	}
+
+	simulated := make(map[string]bool, additionalMembers)
+	for i := 0; i < additionalMembers; i++ {
+		id := fmt.Sprintf("kgo-simulated-member-%d", i)
+		simulated[id] = true
+		members = append(members, kmsg.JoinGroupResponseMember{
+			MemberID:         id,
+			ProtocolMetadata: balancer.JoinGroupMetadata(interests, nil, -1),
+		})
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	sortJoinMembers(members)
+
+	memberBalancer, topics, err := balancer.MemberBalancer(members)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create group member balancer: %v", err)
+	}
+
+	var topicPartitionCount map[string]int32
+	if len(topics) > 0 {
+		metaTopics := make([]string, 0, len(topics))
+		for topic := range topics {
+			metaTopics = append(metaTopics, topic)
+		}
+		if topicPartitionCount, err = partitionCounts(metaTopics); err != nil {
+			return nil, err
+		}
+	}
+
+	assigned := make(map[string][]int32)
+	for _, assignment := range memberBalancer.Balance(topicPartitionCount).IntoSyncAssignment() {
+		if !simulated[assignment.MemberID] {
+			continue
+		}
+		memberAssigned, err := balancer.ParseSyncAssignment(assignment.MemberAssignment)
+		if err != nil {
+			return nil, err
+		}
+		for topic, partitions := range memberAssigned {
+			assigned[topic] = append(assigned[topic], partitions...)
+		}
+	}
+	for _, partitions := range assigned {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	}
+	return assigned, nil
+}
+
 // helper func; range and roundrobin use v0
 func memberMetadataV0(interests []string) []byte {
 	return (&kmsg.GroupMemberMetadata{
@@ -99,3 +99,45 @@ func Test_stickyAdjustCooperative(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestSimulateGroupBalance(t *testing.T) {
+	t.Parallel()
+
+	balancer := RoundRobinBalancer()
+	described := &kmsg.DescribeGroupsResponseGroup{
+		Group:        "g",
+		State:        "Stable",
+		ProtocolType: "consumer",
+		Protocol:     balancer.ProtocolName(),
+		Members: []kmsg.DescribeGroupsResponseGroupMember{
+			{MemberID: "b", ProtocolMetadata: balancer.JoinGroupMetadata([]string{"t"}, nil, 1)},
+			{MemberID: "a", ProtocolMetadata: balancer.JoinGroupMetadata([]string{"t"}, nil, 1)},
+		},
+	}
+	partitionCounts := func(topics []string) (map[string]int32, error) {
+		if len(topics) != 1 || topics[0] != "t" {
+			t.Errorf("got metadata topics %v != exp [t]", topics)
+		}
+		return map[string]int32{"t": 6}, nil
+	}
+
+	// Members sort as a, b, then our simulated member, so round robin
+	// gives the simulated member every third partition starting at 2.
+	got, err := simulateGroupBalance(described, 1, balancer, partitionCounts)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if exp := map[string][]int32{"t": {2, 5}}; !cmp.Equal(got, exp) {
+		t.Errorf("got simulated assignment %v != exp %v", got, exp)
+	}
+
+	got, err = simulateGroupBalance(described, 0, balancer, partitionCounts)
+	if err != nil || len(got) != 0 {
+		t.Errorf("got (%v, %v) with no simulated members, expected nothing", got, err)
+	}
+
+	described.ProtocolType = "connect"
+	if _, err := simulateGroupBalance(described, 1, balancer, partitionCounts); err == nil {
+		t.Error("expected err simulating a non-consumer group")
+	}
+}
@@ -0,0 +1,310 @@
+package kgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// TopicLag is the lag of a group for a single partition, as returned from
+// GroupTopicLag.
+type TopicLag struct {
+	// Partition is the partition this lag is for.
+	Partition int32
+	// CommittedOffset is the group's committed offset for the partition,
+	// or -1 if the group has not committed.
+	CommittedOffset int64
+	// EndOffset is the partition's end offset. If the client is
+	// configured to read committed, this is the last stable offset.
+	EndOffset int64
+	// Lag is EndOffset minus CommittedOffset, or -1 if the group has not
+	// committed.
+	Lag int64
+	// MemberID is the ID of the group member currently assigned the
+	// partition, or empty if no member is assigned it (or if the group's
+	// assignments are not in the standard consumer format).
+	MemberID string
+	// ClientHost is the host of the member currently assigned the
+	// partition, as seen by Kafka.
+	ClientHost string
+}
+
+// GroupTopicLag returns the lag of every partition in topic for the given
+// group, sorted by partition. This issues a metadata request for the topic,
+// and then DescribeGroups, OffsetFetch, and ListOffsets requests
+// concurrently, joining their results.
+//
+// Member assignments can only be attributed to partitions if the group uses
+// the standard consumer protocol; for other protocols, MemberID and
+// ClientHost are empty. Any request or partition error fails the entire
+// call.
+func (cl *Client) GroupTopicLag(ctx context.Context, group, topic string) ([]TopicLag, error) {
+	_, meta, err := cl.fetchMetadataForTopics(ctx, false, []string{topic})
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int32
+	for _, t := range meta.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return nil, err
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.Partition)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, kerr.UnknownTopicOrPartition
+	}
+
+	var (
+		wg sync.WaitGroup
+
+		described    *kmsg.DescribeGroupsResponseGroup
+		describedErr error
+		committed    *kmsg.OffsetFetchResponse
+		committedErr error
+		ends         *kmsg.ListOffsetsResponse
+		endsErr      error
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		described, describedErr = cl.describeGroup(ctx, group)
+	}()
+	go func() {
+		defer wg.Done()
+		req := kmsg.NewPtrOffsetFetchRequest()
+		req.Group = group
+		req.Topics = []kmsg.OffsetFetchRequestTopic{{
+			Topic:      topic,
+			Partitions: partitions,
+		}}
+		committed, committedErr = req.RequestWith(ctx, cl)
+	}()
+	go func() {
+		defer wg.Done()
+		req := kmsg.NewPtrListOffsetsRequest()
+		req.ReplicaID = -1
+		req.IsolationLevel = cl.cfg.isolationLevel
+		reqTopic := kmsg.NewListOffsetsRequestTopic()
+		reqTopic.Topic = topic
+		for _, partition := range partitions {
+			reqPartition := kmsg.NewListOffsetsRequestTopicPartition()
+			reqPartition.Partition = partition
+			reqPartition.CurrentLeaderEpoch = -1
+			reqPartition.Timestamp = -1 // latest
+			reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+		}
+		req.Topics = append(req.Topics, reqTopic)
+		ends, endsErr = req.RequestWith(ctx, cl)
+	}()
+	wg.Wait()
+
+	for _, err := range []error{describedErr, committedErr, endsErr} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lags := make(map[int32]*TopicLag, len(partitions))
+	for _, partition := range partitions {
+		lags[partition] = &TopicLag{
+			Partition:       partition,
+			CommittedOffset: -1,
+			Lag:             -1,
+		}
+	}
+
+	for _, t := range ends.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				return nil, fmt.Errorf("unable to list end offset for partition %d: %w", p.Partition, err)
+			}
+			if lag := lags[p.Partition]; lag != nil {
+				lag.EndOffset = p.Offset
+			}
+		}
+	}
+
+	if err := kerr.ErrorForCode(committed.ErrorCode); err != nil {
+		return nil, fmt.Errorf("unable to fetch committed offsets: %w", err)
+	}
+	for _, t := range committed.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		for _, p := range t.Partitions {
+			if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+				return nil, fmt.Errorf("unable to fetch committed offset for partition %d: %w", p.Partition, err)
+			}
+			if lag := lags[p.Partition]; lag != nil && p.Offset >= 0 {
+				lag.CommittedOffset = p.Offset
+				lag.Lag = lag.EndOffset - p.Offset
+				if lag.Lag < 0 {
+					lag.Lag = 0
+				}
+			}
+		}
+	}
+
+	for i := range described.Members {
+		member := &described.Members[i]
+		for _, partition := range consumerAssignment(described, member)[topic] {
+			if lag := lags[partition]; lag != nil {
+				lag.MemberID = member.MemberID
+				lag.ClientHost = member.ClientHost
+			}
+		}
+	}
+
+	sorted := make([]TopicLag, 0, len(lags))
+	for _, lag := range lags {
+		sorted = append(sorted, *lag)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Partition < sorted[j].Partition })
+	return sorted, nil
+}
+
+// GroupPartitionOffset is a group's committed offset and a partition's end
+// offset, as returned from FetchTopicOffsetsForGroup.
+type GroupPartitionOffset struct {
+	// CommittedOffset is the group's committed offset for the partition,
+	// or -1 if the group has not committed.
+	CommittedOffset int64
+	// EndOffset is the partition's end offset (or last stable offset, if
+	// reading committed).
+	EndOffset int64
+	// Lag is EndOffset minus CommittedOffset, or -1 if the group has not
+	// committed.
+	Lag int64
+	// MemberID is the ID of the group member currently assigned the
+	// partition, if any.
+	MemberID string
+}
+
+// FetchTopicOffsetsForGroup returns the committed offset, end offset, and lag
+// for every partition of topic for the given group, keyed by partition.
+//
+// This is a map-based form of GroupTopicLag; see its documentation for more
+// details.
+func (cl *Client) FetchTopicOffsetsForGroup(ctx context.Context, topic, group string) (map[int32]GroupPartitionOffset, error) {
+	lags, err := cl.GroupTopicLag(ctx, group, topic)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int32]GroupPartitionOffset, len(lags))
+	for _, lag := range lags {
+		offsets[lag.Partition] = GroupPartitionOffset{
+			CommittedOffset: lag.CommittedOffset,
+			EndOffset:       lag.EndOffset,
+			Lag:             lag.Lag,
+			MemberID:        lag.MemberID,
+		}
+	}
+	return offsets, nil
+}
+
+// GroupMember is a member of a group, as returned from ListGroupMembers.
+type GroupMember struct {
+	// MemberID is the member ID Kafka assigned to the member.
+	MemberID string
+	// ClientID is the client ID of the member.
+	ClientID string
+	// Host is the host of the member, as seen by Kafka.
+	Host string
+	// AssignedPartitions is the member's assignment, which is nil if the
+	// group does not use the standard consumer protocol or if the
+	// assignment cannot be parsed.
+	AssignedPartitions map[string][]int32
+}
+
+// ListGroupMembers issues a DescribeGroups request for the given group and
+// returns its members, sorted by member ID.
+//
+// Unlike DescribeOwnGroup, this works for any group, but assignments can only
+// be parsed if the group uses the standard consumer protocol.
+func (cl *Client) ListGroupMembers(ctx context.Context, group string) ([]GroupMember, error) {
+	described, err := cl.describeGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]GroupMember, 0, len(described.Members))
+	for i := range described.Members {
+		member := &described.Members[i]
+		members = append(members, GroupMember{
+			MemberID:           member.MemberID,
+			ClientID:           member.ClientID,
+			Host:               member.ClientHost,
+			AssignedPartitions: consumerAssignment(described, member),
+		})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].MemberID < members[j].MemberID })
+	return members, nil
+}
+
+// ListPartitionsForGroup issues a DescribeGroups request for the given group
+// and returns the combined assignment of all members, with partitions sorted.
+// If the group is empty, or if it does not use the standard consumer protocol,
+// this returns an empty map.
+func (cl *Client) ListPartitionsForGroup(ctx context.Context, group string) (map[string][]int32, error) {
+	described, err := cl.describeGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string][]int32)
+	for i := range described.Members {
+		for topic, partitions := range consumerAssignment(described, &described.Members[i]) {
+			all[topic] = append(all[topic], partitions...)
+		}
+	}
+	for _, partitions := range all {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	}
+	return all, nil
+}
+
+// describeGroup issues a DescribeGroups request for a single group, returning
+// the group or any request or group error.
+func (cl *Client) describeGroup(ctx context.Context, group string) (*kmsg.DescribeGroupsResponseGroup, error) {
+	req := kmsg.NewPtrDescribeGroupsRequest()
+	req.Groups = []string{group}
+	resp, err := req.RequestWith(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Groups) != 1 {
+		return nil, fmt.Errorf("Kafka replied to our DescribeGroupsRequest with %d groups, expected 1", len(resp.Groups))
+	}
+	described := &resp.Groups[0]
+	if err := kerr.ErrorForCode(described.ErrorCode); err != nil {
+		return nil, fmt.Errorf("unable to describe group: %w", err)
+	}
+	return described, nil
+}
+
+// consumerAssignment parses a member's assignment, returning nil if the group
+// does not use the standard consumer protocol or if parsing fails.
+func consumerAssignment(described *kmsg.DescribeGroupsResponseGroup, member *kmsg.DescribeGroupsResponseGroupMember) map[string][]int32 {
+	if described.ProtocolType != "consumer" {
+		return nil
+	}
+	var assignment kmsg.GroupMemberAssignment
+	if err := assignment.ReadFrom(member.MemberAssignment); err != nil {
+		return nil
+	}
+	assigned := make(map[string][]int32, len(assignment.Topics))
+	for _, t := range assignment.Topics {
+		assigned[t.Topic] = append(assigned[t.Topic], t.Partitions...)
+	}
+	return assigned
+}
@@ -0,0 +1,219 @@
+package kgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// HealthStatus is a snapshot of the client's health, as returned from
+// HealthCheck.
+type HealthStatus struct {
+	// IsHealthy is true if every broker is reachable and, if the client
+	// is consuming as a group member, the group is stable.
+	IsHealthy bool
+
+	// BrokersReachable is the number of brokers whose most recent
+	// connection attempt succeeded, or that have not yet needed a
+	// connection. Seed brokers are only counted until the client has
+	// discovered brokers through metadata.
+	BrokersReachable int
+	// BrokersUnreachable is the number of brokers whose most recent
+	// connection attempt failed.
+	BrokersUnreachable int
+
+	// ConsumerGroupState is the state of the group, as this client last
+	// knew it, using Kafka's group state names: PreparingRebalance while
+	// joining, CompletingRebalance while syncing, Stable once assigned,
+	// and Dead once the group has been left. This is "Empty" if the group
+	// has not begun joining, and empty if the client is not consuming as
+	// a group member.
+	ConsumerGroupState string
+
+	// LastMetadataRefresh is when the client last finished a metadata
+	// refresh, successful or not.
+	LastMetadataRefresh time.Time
+
+	// ProduceQueueDepth is the number of records currently buffered in
+	// the client waiting to be produced.
+	ProduceQueueDepth int
+
+	// Errors contains the most recent connection error for every
+	// unreachable broker.
+	Errors []error
+}
+
+// HealthCheck returns a snapshot of the client's health, suitable for
+// readiness or liveness probes.
+//
+// This function does not block and does not issue any requests: all
+// information is from state the client has cached while running. Brokers
+// are connected to lazily, so a broker the client has not needed to talk to
+// yet is considered reachable. The context is currently unused, but is
+// accepted so that checks can be extended in the future without breaking
+// the API.
+func (cl *Client) HealthCheck(ctx context.Context) HealthStatus {
+	var status HealthStatus
+
+	for _, b := range cl.healthBrokers() {
+		if err := b.loadConnErr(); err != nil {
+			status.BrokersUnreachable++
+			status.Errors = append(status.Errors, fmt.Errorf("broker %d at %s: %w", b.meta.NodeID, b.addr, err))
+			continue
+		}
+		status.BrokersReachable++
+	}
+
+	cl.consumer.mu.Lock()
+	g := cl.consumer.g
+	cl.consumer.mu.Unlock()
+	if g != nil {
+		status.ConsumerGroupState = g.loadState()
+	}
+
+	cl.metawait.mu.Lock()
+	status.LastMetadataRefresh = cl.metawait.lastUpdate
+	cl.metawait.mu.Unlock()
+
+	status.ProduceQueueDepth = int(atomic.LoadInt64(&cl.producer.bufferedRecords))
+
+	status.IsHealthy = status.BrokersUnreachable == 0 &&
+		(g == nil || status.ConsumerGroupState == "Stable")
+
+	return status
+}
+
+// healthBrokers returns the brokers that count towards the client's health,
+// sorted by node ID. Seed brokers are only needed until metadata is loaded,
+// so once any broker has been discovered, we skip seeds: otherwise, a seed
+// that failed a single dial would keep the client unhealthy forever.
+func (cl *Client) healthBrokers() []*broker {
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+
+	var discovered bool
+	for id := range cl.brokers {
+		if id >= 0 {
+			discovered = true
+			break
+		}
+	}
+
+	brokers := make([]*broker, 0, len(cl.brokers))
+	for id, b := range cl.brokers {
+		if discovered && id < -1 { // seed broker
+			continue
+		}
+		brokers = append(brokers, b)
+	}
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].meta.NodeID < brokers[j].meta.NodeID })
+	return brokers
+}
+
+// LastBrokerError returns the most recent connection, write, or read error
+// for the broker with the given node ID, and when it occurred. Unlike the
+// errors in HealthCheck, this error is not cleared once the broker becomes
+// reachable again. This returns a nil error and zero time if the broker has
+// not had an error, or if the client does not know of the broker. Seed
+// brokers have node IDs starting at math.MinInt32.
+//
+// Errors caused by a request's context being canceled are not saved.
+func (cl *Client) LastBrokerError(nodeID int32) (err error, when time.Time) {
+	cl.brokersMu.RLock()
+	b := cl.brokers[nodeID]
+	cl.brokersMu.RUnlock()
+	if b == nil {
+		return nil, time.Time{}
+	}
+
+	b.connErrMu.Lock()
+	defer b.connErrMu.Unlock()
+	return b.lastErr, b.lastErrAt
+}
+
+// UnhealthyBrokers returns the sorted node IDs of brokers whose most recent
+// connection attempts have consecutively failed, which are the brokers
+// counted as unreachable in HealthCheck. A broker becomes healthy again once
+// a connection to it succeeds. As in HealthCheck, seed brokers are skipped
+// once the client has discovered brokers through metadata.
+func (cl *Client) UnhealthyBrokers() []int32 {
+	var unhealthy []int32
+	for _, b := range cl.healthBrokers() {
+		b.connErrMu.Lock()
+		failing := b.connFailures > 0
+		b.connErrMu.Unlock()
+		if failing {
+			unhealthy = append(unhealthy, b.meta.NodeID)
+		}
+	}
+	return unhealthy
+}
+
+// IsHealthy returns whether all brokers are reachable and, if consuming as a
+// group member, whether the group is stable. This is a shortcut for
+// HealthCheck(ctx).IsHealthy and, like HealthCheck, does not block.
+func (cl *Client) IsHealthy() bool {
+	return cl.HealthCheck(cl.ctx).IsHealthy
+}
+
+// BrokerIdleConnections is the number of open connections to a broker, and
+// how many of those are idle, as returned from IdleBrokerConnections.
+type BrokerIdleConnections struct {
+	// Meta is the broker's metadata.
+	Meta BrokerMetadata
+	// Open is the number of open connections to the broker. The client
+	// uses up to three connections per broker: one for produce requests,
+	// one for fetch requests, and one for everything else.
+	Open int
+	// Idle is the number of open connections that have not written a
+	// request within the idle duration.
+	Idle int
+}
+
+// IdleBrokerConnections returns, sorted by node ID, the number of open and
+// idle connections for every broker the client has an open connection to. A
+// connection is idle if it is not currently writing and has not written a
+// request within the given duration.
+//
+// Idle connections are closed once they have neither written nor read for
+// ConnIdleTimeout, so this can show connections that are open but unused,
+// and whether ConnIdleTimeout could be lowered to reduce open file
+// descriptors.
+func (cl *Client) IdleBrokerConnections(idle time.Duration) []BrokerIdleConnections {
+	cl.brokersMu.RLock()
+	brokers := make([]*broker, 0, len(cl.brokers))
+	for _, b := range cl.brokers {
+		brokers = append(brokers, b)
+	}
+	cl.brokersMu.RUnlock()
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].meta.NodeID < brokers[j].meta.NodeID })
+
+	var conns []BrokerIdleConnections
+	for _, b := range brokers {
+		bc := BrokerIdleConnections{Meta: b.meta}
+
+		b.reapMu.Lock()
+		for _, cxn := range []*brokerCxn{
+			b.cxnNormal,
+			b.cxnProduce,
+			b.cxnFetch,
+		} {
+			if cxn == nil || atomic.LoadInt32(&cxn.dead) == 1 {
+				continue
+			}
+			bc.Open++
+			lastWrite := time.Unix(0, atomic.LoadInt64(&cxn.lastWrite))
+			if time.Since(lastWrite) >= idle && atomic.LoadUint32(&cxn.writing) == 0 {
+				bc.Idle++
+			}
+		}
+		b.reapMu.Unlock()
+
+		if bc.Open > 0 {
+			conns = append(conns, bc)
+		}
+	}
+	return conns
+}
@@ -0,0 +1,27 @@
+package kgo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUnhealthyBrokersSkipsSeeds(t *testing.T) {
+	t.Parallel()
+
+	newBroker := func(id int32, failures int) *broker {
+		return &broker{meta: BrokerMetadata{NodeID: id}, connFailures: failures}
+	}
+	seed := newBroker(math.MinInt32, 1)
+
+	cl := &Client{brokers: map[int32]*broker{seed.meta.NodeID: seed}}
+	if got, exp := cl.UnhealthyBrokers(), []int32{math.MinInt32}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("before discovery: got %v != exp %v", got, exp)
+	}
+
+	cl.brokers[1] = newBroker(1, 0)
+	cl.brokers[2] = newBroker(2, 2)
+	if got, exp := cl.UnhealthyBrokers(), []int32{2}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("after discovery: got %v != exp %v", got, exp)
+	}
+}
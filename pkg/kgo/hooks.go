@@ -1,8 +1,13 @@
 package kgo
 
 import (
+	"context"
 	"net"
+	"reflect"
+	"sync"
 	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 // Hook is a hook to be called when something happens in kgo.
@@ -27,6 +32,110 @@ func (hs hooks) each(fn func(Hook)) {
 	}
 }
 
+// clientHooks guards the hooks a client calls, allowing hooks to be added or
+// removed while the client is running. Modifications always replace the
+// slice rather than modify it, meaning we only need the lock to load the
+// slice, not to iterate over it and call hooks.
+type clientHooks struct {
+	mu sync.RWMutex
+	hs hooks
+}
+
+func (ch *clientHooks) load() hooks {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.hs
+}
+
+func (ch *clientHooks) each(fn func(Hook)) { ch.load().each(fn) }
+
+// AddHook adds a hook to the client after the client has been created, which
+// is useful for layering in metrics or tracing into an already running
+// client. The hook is called after any hooks the client already has.
+//
+// Hooks added here have the same requirements as hooks added with WithHooks.
+// Since a hook that implements no hook interface will never be called, this
+// returns *ErrUnknownHook for such a hook, which can help catch hooks with
+// mistyped method signatures.
+//
+// A hook may be called for events that began before the hook was added, and
+// hooks that are called in pairs (such as HookBrokerConnect and
+// HookBrokerDisconnect) may only see one of the pair.
+func (cl *Client) AddHook(hook Hook) error {
+	if !isKnownHook(hook) {
+		return &ErrUnknownHook{hook}
+	}
+
+	cl.hooks.mu.Lock()
+	defer cl.hooks.mu.Unlock()
+
+	hs := make(hooks, 0, len(cl.hooks.hs)+1)
+	hs = append(hs, cl.hooks.hs...)
+	cl.hooks.hs = append(hs, hook)
+	return nil
+}
+
+// RemoveHook removes the first instance of the given hook from the client,
+// returning whether the hook was found. Hooks are compared with ==, so it is
+// expected that the hook is the same pointer that was added. Hooks that are
+// not comparable (i.e., slices, maps, or funcs) are never found.
+//
+// A hook may still be called briefly after it is removed, if the client began
+// calling hooks before the removal.
+func (cl *Client) RemoveHook(hook Hook) bool {
+	if hook == nil || !reflect.TypeOf(hook).Comparable() {
+		return false
+	}
+
+	cl.hooks.mu.Lock()
+	defer cl.hooks.mu.Unlock()
+
+	for i, h := range cl.hooks.hs {
+		if reflect.TypeOf(h) != reflect.TypeOf(hook) || h != hook {
+			continue
+		}
+		hs := make(hooks, 0, len(cl.hooks.hs)-1)
+		hs = append(hs, cl.hooks.hs[:i]...)
+		cl.hooks.hs = append(hs, cl.hooks.hs[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// isKnownHook returns whether a hook implements any hook interface.
+func isKnownHook(hook Hook) bool {
+	switch hook.(type) {
+	case HookBrokerConnect,
+		HookBrokerDisconnect,
+		HookBrokerWrite,
+		HookBrokerRead,
+		HookBrokerWriteContext,
+		HookBrokerReadContext,
+		HookBrokerE2E,
+		HookBrokerThrottle,
+		HookBrokerThrottleKey,
+		HookBrokerSendQueued,
+		HookBrokerSASL,
+		HookGroupManageError,
+		HookGroupOffsetCommit,
+		HookGroupRebalance,
+		HookMetadataRefresh,
+		HookProduceBatchWritten,
+		HookProduceRecordBuffered,
+		HookProduceRecordUnbuffered,
+		HookProducePartitionError,
+		HookTransactionEnd,
+		HookFetchBatchRead,
+		HookFetchPartitionError,
+		HookFetchSession,
+		HookFetchRecordBuffered,
+		HookFetchRecordUnbuffered,
+		HookClientClosed:
+		return true
+	}
+	return false
+}
+
 // HookBrokerConnect is called after a connection to a broker is opened.
 type HookBrokerConnect interface {
 	// OnBrokerConnect is passed the broker metadata, how long it took to
@@ -70,6 +179,29 @@ type HookBrokerRead interface {
 	OnBrokerRead(meta BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error)
 }
 
+// HookBrokerWriteContext is the same as HookBrokerWrite, but is also passed the
+// context of the request that was written. This can be used to correlate
+// writes with the caller's trace, such as for exemplars. If a hook implements
+// both HookBrokerWrite and HookBrokerWriteContext, both are called.
+type HookBrokerWriteContext interface {
+	// OnBrokerWriteContext is passed the context of the request, and then
+	// the same arguments as OnBrokerWrite. Produce and fetch requests are
+	// built by the client from many records, so their context is the
+	// client's own context rather than any one caller's.
+	OnBrokerWriteContext(ctx context.Context, meta BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error)
+}
+
+// HookBrokerReadContext is the same as HookBrokerRead, but is also passed the
+// context of the request whose response was read. If a hook implements both
+// HookBrokerRead and HookBrokerReadContext, both are called.
+type HookBrokerReadContext interface {
+	// OnBrokerReadContext is passed the context of the request, and then
+	// the same arguments as OnBrokerRead. For requests the client issues
+	// internally without a context, and for responses that are read only
+	// to be discarded, the context is context.Background().
+	OnBrokerReadContext(ctx context.Context, meta BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error)
+}
+
 // BrokerE2E tracks complete information for a write of a request followed by a
 // read of that requests's response.
 //
@@ -106,7 +238,9 @@ type BrokerE2E struct {
 	// WriteErr is any error encountered during writing. If a write error is
 	// encountered, no read will be attempted.
 	WriteErr error
-	// ReadErr is any error encountered during reading.
+	// ReadErr is any error encountered during reading. If the connection
+	// died after the request was written but before its response could
+	// be read, this is an error and no read info is specified.
 	ReadErr error
 }
 
@@ -143,6 +277,17 @@ type HookBrokerE2E interface {
 	OnBrokerE2E(meta BrokerMetadata, key int16, e2e BrokerE2E)
 }
 
+// HookBrokerSendQueued is called when a request is taken from a broker's
+// send queue, just before the client begins serializing and writing it.
+type HookBrokerSendQueued interface {
+	// OnBrokerSendQueued is passed the broker metadata, the number of
+	// requests still waiting in the queue behind this request, and when
+	// this request was queued. The time since queuedAt is how long the
+	// request waited before the broker could begin handling it, which
+	// is a signal of broker backpressure.
+	OnBrokerSendQueued(meta BrokerMetadata, queueDepth int, queuedAt time.Time)
+}
+
 // HookBrokerThrottle is called after a response to a request is read
 // from a broker, and the response identifies throttling in effect.
 type HookBrokerThrottle interface {
@@ -159,6 +304,65 @@ type HookBrokerThrottle interface {
 	OnBrokerThrottle(meta BrokerMetadata, throttleInterval time.Duration, throttledAfterResponse bool)
 }
 
+// HookBrokerThrottleKey is HookBrokerThrottle with the key of the request
+// whose response identified the throttling, which shows which quota (e.g.,
+// produce or fetch) the client is running into.
+type HookBrokerThrottleKey interface {
+	// OnBrokerThrottleKey is passed the same arguments as
+	// OnBrokerThrottle, along with the request key.
+	OnBrokerThrottleKey(meta BrokerMetadata, key int16, throttleInterval time.Duration, throttledAfterResponse bool)
+}
+
+// HookBrokerSASL is called after a SASL authentication attempt to a broker,
+// both when initializing a connection and when reauthenticating.
+type HookBrokerSASL interface {
+	// OnBrokerSASL is passed the broker metadata, the name of the SASL
+	// mechanism used, how long the handshake and authentication took, and
+	// any error.
+	OnBrokerSASL(meta BrokerMetadata, mechanism string, authDur time.Duration, err error)
+}
+
+// HookGroupOffsetCommit is called after every offset commit a group consumer
+// issues, whether the commit was from autocommitting or from a user calling
+// one of the commit functions. This does not include transactional commits.
+type HookGroupOffsetCommit interface {
+	// OnGroupOffsetCommit is passed the group, the commit request, the
+	// response, how long the commit took, and any request error. If the
+	// request failed, the response is nil. Individual partitions may
+	// still have failed in a successful response; their error codes are
+	// in the response.
+	//
+	// The request and response must not be modified.
+	OnGroupOffsetCommit(group string, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, commitDur time.Duration, err error)
+}
+
+// HookGroupRebalance is called after the client joins and syncs with its
+// consumer group, which is what the client does on every rebalance.
+type HookGroupRebalance interface {
+	// OnGroupRebalance is passed the group, why the client joined, how
+	// long joining and syncing took, and any error.
+	//
+	// The reason is "join" for the client's first join, "rebalance" if
+	// the group began rebalancing (including if the client's own
+	// subscription changed), and "error" if the client is rejoining
+	// after an error, such as its session timing out.
+	OnGroupRebalance(group, reason string, rebalanceDur time.Duration, err error)
+}
+
+// HookMetadataRefresh is called after every metadata refresh the client
+// performs to track cluster and topic state. This does not include metadata
+// requests issued directly by users.
+type HookMetadataRefresh interface {
+	// OnMetadataRefresh is passed what triggered the refresh, how long
+	// the refresh took, and any error.
+	//
+	// The trigger is "startup" for the client's first refresh,
+	// "periodic" for refreshes due to MetadataMaxAge, and "triggered"
+	// for refreshes the client requested internally, such as after a
+	// partition's leader changes or a new topic is produced to.
+	OnMetadataRefresh(trigger string, refreshDur time.Duration, err error)
+}
+
 // HookGroupManageError is called after every error that causes the client,
 // operating as a group member, to break out of the group managing loop and
 // backoff temporarily.
@@ -205,6 +409,12 @@ type ProduceBatchMetrics struct {
 	// 0 is no compression, 1 is gzip, 2 is snappy, 3 is lz4, and 4 is
 	// zstd.
 	CompressionType uint8
+
+	// NumRetries is the number of times this batch was tried before the
+	// successful produce. This includes failed produce requests as well
+	// as internal retries due to partition load errors (such as the
+	// partition not having a known leader).
+	NumRetries int
 }
 
 // HookProduceBatchWritten is called whenever a batch is known to be
@@ -215,6 +425,60 @@ type HookProduceBatchWritten interface {
 	OnProduceBatchWritten(meta BrokerMetadata, topic string, partition int32, metrics ProduceBatchMetrics)
 }
 
+// HookProducePartitionError is called when a produce response has an error
+// for a partition. The batch may be retried depending on the error.
+type HookProducePartitionError interface {
+	// OnProducePartitionError is passed the broker that replied, the
+	// topic and partition, and the partition's error.
+	//
+	// This is called while handling the produce response and should not
+	// block.
+	OnProducePartitionError(meta BrokerMetadata, topic string, partition int32, err error)
+}
+
+// HookProduceRecordBuffered is called when a record is buffered internally in
+// the client from a call to Produce (or ProduceBatch).
+//
+// This can be used alongside HookProduceRecordUnbuffered to track the number
+// of records (or bytes) waiting to be produced. Records that are blocked in
+// Produce waiting for buffer space (see MaxBufferedRecords) are considered
+// buffered, so the tracked count matches ProduceInflightRecords.
+type HookProduceRecordBuffered interface {
+	// OnProduceRecordBuffered is passed a record that is now buffered.
+	//
+	// This is called from Produce and should not block.
+	OnProduceRecordBuffered(*Record)
+}
+
+// HookProduceRecordUnbuffered is called just before a record's promise is
+// called, either because the record was successfully produced or because it
+// failed to be produced.
+//
+// Every record passed to HookProduceRecordBuffered is eventually passed to
+// this hook.
+type HookProduceRecordUnbuffered interface {
+	// OnProduceRecordUnbuffered is passed a record that is no longer
+	// buffered and the error the record's promise is called with, if
+	// any.
+	//
+	// This is called serially with other promises and should not block.
+	OnProduceRecordUnbuffered(*Record, error)
+}
+
+// HookTransactionEnd is called when EndTransaction ends a transaction that
+// was begun with BeginTransaction, including when ending through a
+// GroupTransactSession.
+type HookTransactionEnd interface {
+	// OnTransactionEnd is passed whether the transaction was tried to be
+	// committed (false if aborted), how long it has been since the
+	// transaction began, and any error ending the transaction. An error
+	// with a commit means the transaction was not known to be committed.
+	//
+	// If no records were produced in the transaction, nothing is sent to
+	// Kafka, but this is still called.
+	OnTransactionEnd(commit bool, txnDur time.Duration, err error)
+}
+
 // FetchBatchMetrics tracks information about fetches of batches.
 type FetchBatchMetrics struct {
 	// NumRecords is the number of records that were fetched in this batch.
@@ -254,6 +518,11 @@ type FetchBatchMetrics struct {
 	// 0 is no compression, 1 is gzip, 2 is snappy, 3 is lz4, and 4 is
 	// zstd.
 	CompressionType uint8
+
+	// LeaderEpoch is the partition leader epoch the batch was written
+	// with, or -1 for message sets, which do not have a leader epoch.
+	// This is the same as the LeaderEpoch of every record in the batch.
+	LeaderEpoch int32
 }
 
 // HookFetchBatchRead is called whenever a batch if read within the client.
@@ -267,3 +536,70 @@ type HookFetchBatchRead interface {
 	// OnFetchBatchRead is called per batch read from a topic partition.
 	OnFetchBatchRead(meta BrokerMetadata, topic string, partition int32, metrics FetchBatchMetrics)
 }
+
+// HookFetchPartitionError is called when a fetch response has an error for a
+// partition, or when the partition's records could not be processed. Errors
+// that the client recovers from internally, such as NotLeaderForPartition,
+// are included.
+type HookFetchPartitionError interface {
+	// OnFetchPartitionError is passed the broker that replied, the topic
+	// and partition, and the partition's error.
+	//
+	// This is called while handling the fetch response and should not
+	// block.
+	OnFetchPartitionError(meta BrokerMetadata, topic string, partition int32, err error)
+}
+
+// HookFetchSession is called when the client's incremental fetch session
+// (KIP-227) with a broker changes: when a fetch response advances the session
+// epoch, and when the session is reset or killed. Sessions are reset if the
+// broker evicted the session, if a fetch failed, or if partitions in the
+// session moved or stopped being consumed; the next fetch after a reset must
+// resend every partition. Sessions are killed if the broker does not support
+// them or has no room for another session.
+type HookFetchSession interface {
+	// OnFetchSession is passed the broker, the session ID, the session
+	// epoch the next fetch will use, and whether the session was reset.
+	// The epoch is 0 after a reset and -1 if the session was killed.
+	//
+	// This is called while handling the fetch response and should not
+	// block.
+	OnFetchSession(meta BrokerMetadata, sessionID, epoch int32, reset bool)
+}
+
+// HookFetchRecordBuffered is called when a record is internally buffered
+// after fetching, ready to be drained through PollFetches or PollRecords.
+//
+// This can be used alongside HookFetchRecordUnbuffered to track the number of
+// fetched records waiting to be polled, as well as how long records wait.
+type HookFetchRecordBuffered interface {
+	// OnFetchRecordBuffered is passed a record that is now buffered.
+	//
+	// This is called serially with other fetch hooks for the same
+	// source, and should not block.
+	OnFetchRecordBuffered(*Record)
+}
+
+// HookFetchRecordUnbuffered is called when a fetched record is unbuffered,
+// either because it is returned from PollFetches or PollRecords, or because
+// it is discarded. Records are discarded if their partition is no longer
+// consumed, such as after a group rebalance, or if the client is closing.
+//
+// Every record passed to HookFetchRecordBuffered is eventually passed to this
+// hook, unless the client is closed while the record is buffered. Any state
+// kept for such records can be released in HookClientClosed.
+type HookFetchRecordUnbuffered interface {
+	// OnFetchRecordUnbuffered is passed a record that is no longer
+	// buffered.
+	//
+	// This is called while polling, and should not block.
+	OnFetchRecordUnbuffered(*Record)
+}
+
+// HookClientClosed is called in Close after the client has been closed. This
+// can be used to release any state a hook keeps for the lifetime of a client.
+type HookClientClosed interface {
+	// OnClientClosed is passed the client that has been closed, after all
+	// client-internal close cleanup has happened.
+	OnClientClosed(*Client)
+}
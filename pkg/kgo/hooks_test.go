@@ -0,0 +1,136 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+type testManageErrHook struct{ n int }
+
+func (h *testManageErrHook) OnGroupManageError(error) { h.n++ }
+
+func TestAddRemoveHook(t *testing.T) {
+	t.Parallel()
+
+	cl := new(Client)
+
+	var unknown *ErrUnknownHook
+	if err := cl.AddHook(struct{}{}); !errors.As(err, &unknown) {
+		t.Fatalf("got err %v when adding unknown hook, expected *ErrUnknownHook", err)
+	}
+
+	h1, h2 := new(testManageErrHook), new(testManageErrHook)
+	for _, h := range []Hook{h1, h2, h1} {
+		if err := cl.AddHook(h); err != nil {
+			t.Fatalf("unexpected err adding hook: %v", err)
+		}
+	}
+
+	call := func() {
+		cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookGroupManageError); ok {
+				h.OnGroupManageError(nil)
+			}
+		})
+	}
+
+	call()
+	if h1.n != 2 || h2.n != 1 {
+		t.Errorf("got calls (%d, %d) != exp (2, 1)", h1.n, h2.n)
+	}
+
+	if !cl.RemoveHook(h1) {
+		t.Error("unable to remove added hook")
+	}
+	if cl.RemoveHook(new(testManageErrHook)) {
+		t.Error("unexpectedly removed hook that was never added")
+	}
+	if cl.RemoveHook(func() {}) {
+		t.Error("unexpectedly removed uncomparable hook")
+	}
+
+	call()
+	if h1.n != 3 || h2.n != 2 {
+		t.Errorf("got calls (%d, %d) != exp (3, 2)", h1.n, h2.n)
+	}
+}
+
+type testReadE2EHook struct {
+	reads int
+	e2es  []BrokerE2E
+}
+
+func (h *testReadE2EHook) OnBrokerRead(BrokerMetadata, int16, int, time.Duration, time.Duration, error) {
+	h.reads++
+}
+
+func (h *testReadE2EHook) OnBrokerE2E(_ BrokerMetadata, _ int16, e2e BrokerE2E) {
+	h.e2es = append(h.e2es, e2e)
+}
+
+func TestReadHooksBothReadAndE2E(t *testing.T) {
+	t.Parallel()
+
+	h := new(testReadE2EHook)
+	cl, err := NewClient(WithHooks(h))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	cxn := &brokerCxn{
+		conn: client,
+		cl:   cl,
+		b:    &broker{cl: cl, meta: BrokerMetadata{NodeID: 1}},
+	}
+
+	go server.Write([]byte{0, 0, 0, 4, 0, 0, 0, 7}) // size 4, correlation ID 7
+
+	if _, err := cxn.readResponse(context.Background(), 3, 0, 7, false, 0, 10, 0, 0, time.Now()); err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if h.reads != 1 || len(h.e2es) != 1 {
+		t.Fatalf("got (%d reads, %d e2es) != exp (1, 1)", h.reads, len(h.e2es))
+	}
+	if e2e := h.e2es[0]; e2e.BytesWritten != 10 || e2e.BytesRead != 8 || e2e.Err() != nil {
+		t.Errorf("got unexpected read e2e %+v", e2e)
+	}
+
+	cxn.hookDeadE2E(promisedResp{resp: new(kmsg.MetadataResponse), bytesWritten: 10})
+	if len(h.e2es) != 2 {
+		t.Fatalf("got %d e2es after dead connection != exp 2", len(h.e2es))
+	}
+	if e2e := h.e2es[1]; e2e.WriteErr != nil || !errors.Is(e2e.ReadErr, errChosenBrokerDead) {
+		t.Errorf("got dead e2e (write err %v, read err %v), expected only a read err", e2e.WriteErr, e2e.ReadErr)
+	}
+}
+
+type testClosedHook struct{ closed *Client }
+
+func (h *testClosedHook) OnClientClosed(cl *Client) { h.closed = cl }
+
+func TestClientClosedHook(t *testing.T) {
+	t.Parallel()
+
+	h := new(testClosedHook)
+	cl, err := NewClient(WithHooks(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.closed != nil {
+		t.Fatal("client closed hook called before Close")
+	}
+	cl.Close()
+	if h.closed != cl {
+		t.Errorf("got closed client %p != exp %p", h.closed, cl)
+	}
+}
@@ -0,0 +1,150 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+// KafkaVersion is a Kafka major and minor version, as inferred from a broker's
+// ApiVersions response. KafkaVersion values are comparable with ==.
+type KafkaVersion struct {
+	major int
+	minor int
+}
+
+// Major returns the major version, e.g. 2 for Kafka 2.8 or 0 for Kafka 0.11.
+func (v KafkaVersion) Major() int { return v.major }
+
+// Minor returns the minor version, e.g. 8 for Kafka 2.8 or 11 for Kafka 0.11.
+func (v KafkaVersion) Minor() int { return v.minor }
+
+// AtLeast returns whether this version is at least major.minor.
+func (v KafkaVersion) AtLeast(major, minor int) bool {
+	return v.major > major || v.major == major && v.minor >= minor
+}
+
+// String returns the version in the format v#.#, e.g. v2.8 or v0.11.
+func (v KafkaVersion) String() string {
+	return fmt.Sprintf("v%d.%d", v.major, v.minor)
+}
+
+// KafkaVersion returns the Kafka version of the cluster, as inferred from the
+// controller broker's ApiVersions response. The result is cached after the
+// first successful call.
+//
+// Patch versions cannot be inferred and are not included. The broker's max
+// request versions are compared against each known Kafka release, and this
+// returns the newest release whose every request the broker supports at
+// least at that release's version. If the broker supports versions in between
+// two releases, this returns the lower of the two. This can detect versions up
+// to Kafka 3.0; anything newer is reported as v3.0. If the broker does not
+// support the requests of even Kafka 0.8.0 (as can be the case with custom
+// brokers or Kafka-compatible systems), this returns an error.
+func (cl *Client) KafkaVersion(ctx context.Context) (KafkaVersion, error) {
+	cl.kafkaVersionMu.Lock()
+	cached := cl.kafkaVersion
+	cl.kafkaVersionMu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	// We do not hold the lock while issuing the request: concurrent
+	// callers may each issue a request, but they all infer the same
+	// version.
+	controller, err := cl.controller(ctx)
+	if err != nil {
+		return KafkaVersion{}, err
+	}
+	req := kmsg.NewPtrApiVersionsRequest()
+	req.ClientSoftwareName = cl.cfg.softwareName
+	req.ClientSoftwareVersion = cl.cfg.softwareVersion
+	kresp, err := cl.Broker(int(controller.meta.NodeID)).RetriableRequest(ctx, req)
+	if err != nil {
+		return KafkaVersion{}, err
+	}
+	resp := kresp.(*kmsg.ApiVersionsResponse)
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return KafkaVersion{}, err
+	}
+
+	v, err := kafkaVersionOf(kversion.FromApiVersionsResponse(resp))
+	if err != nil {
+		return KafkaVersion{}, err
+	}
+
+	cl.kafkaVersionMu.Lock()
+	defer cl.kafkaVersionMu.Unlock()
+	if cl.kafkaVersion == nil {
+		cl.kafkaVersion = &v
+	}
+	return *cl.kafkaVersion, nil
+}
+
+// kafkaReleases are the releases kafkaVersionOf can detect, oldest first.
+// Patch releases that add requests (0.8.1, 0.8.2, 0.10.1, 0.10.2) share
+// their major.minor with the release before them and are not needed.
+var kafkaReleases = []struct {
+	v        KafkaVersion
+	versions func() *kversion.Versions
+}{
+	{KafkaVersion{0, 8}, kversion.V0_8_0},
+	{KafkaVersion{0, 9}, kversion.V0_9_0},
+	{KafkaVersion{0, 10}, kversion.V0_10_0},
+	{KafkaVersion{0, 11}, kversion.V0_11_0},
+	{KafkaVersion{1, 0}, kversion.V1_0_0},
+	{KafkaVersion{1, 1}, kversion.V1_1_0},
+	{KafkaVersion{2, 0}, kversion.V2_0_0},
+	{KafkaVersion{2, 1}, kversion.V2_1_0},
+	{KafkaVersion{2, 2}, kversion.V2_2_0},
+	{KafkaVersion{2, 3}, kversion.V2_3_0},
+	{KafkaVersion{2, 4}, kversion.V2_4_0},
+	{KafkaVersion{2, 5}, kversion.V2_5_0},
+	{KafkaVersion{2, 6}, kversion.V2_6_0},
+	{KafkaVersion{2, 7}, kversion.V2_7_0},
+	{KafkaVersion{2, 8}, kversion.V2_8_0},
+}
+
+// kafkaVersionOf returns the newest release that vs fully supports.
+func kafkaVersionOf(vs *kversion.Versions) (KafkaVersion, error) {
+	var (
+		v     KafkaVersion
+		found bool
+	)
+	for _, release := range kafkaReleases {
+		if !supportsRelease(vs, release.versions()) {
+			break
+		}
+		v, found = release.v, true
+	}
+	if !found {
+		return KafkaVersion{}, errors.New("unable to determine Kafka version from ApiVersions: the broker does not support the requests of Kafka v0.8")
+	}
+
+	// kversion only knows versions through 2.8. DescribeTransactions was
+	// introduced in 3.0.
+	if v == (KafkaVersion{2, 8}) && vs.HasKey(65) {
+		v = KafkaVersion{3, 0}
+	}
+	return v, nil
+}
+
+// supportsRelease returns whether vs supports every request in release at
+// least at the release's max version. Like kversion's VersionGuess, this
+// skips the inter-broker keys 4 through 7, which brokers need not advertise.
+func supportsRelease(vs, release *kversion.Versions) bool {
+	supports := true
+	release.EachMaxKeyVersion(func(k, want int16) {
+		if k >= 4 && k <= 7 {
+			return
+		}
+		if have, ok := vs.LookupMaxKeyVersion(k); !ok || have < want {
+			supports = false
+		}
+	})
+	return supports
+}
@@ -0,0 +1,45 @@
+package kgo
+
+import (
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kversion"
+)
+
+func TestKafkaVersionOf(t *testing.T) {
+	t.Parallel()
+
+	with := func(vs *kversion.Versions, k, v int16) *kversion.Versions {
+		vs.SetMaxKeyVersion(k, v)
+		return vs
+	}
+
+	for _, test := range []struct {
+		name   string
+		vs     *kversion.Versions
+		exp    KafkaVersion
+		expErr bool
+	}{
+		{name: "v2.8", vs: kversion.V2_8_0(), exp: KafkaVersion{2, 8}},
+		{name: "v0.11", vs: kversion.V0_11_0(), exp: KafkaVersion{0, 11}},
+		{name: "v0.8.0", vs: kversion.V0_8_0(), exp: KafkaVersion{0, 8}},
+		{name: "v0.10.2", vs: kversion.V0_10_2(), exp: KafkaVersion{0, 10}},
+		{name: "v3.0", vs: with(kversion.V2_8_0(), 65, 0), exp: KafkaVersion{3, 0}},
+		{name: "between v2.4 and v2.5", vs: with(kversion.V2_4_0(), 0, 9), exp: KafkaVersion{2, 4}},
+		{name: "no inter-broker keys", vs: with(kversion.V2_4_0(), 4, -1), exp: KafkaVersion{2, 4}},
+		{name: "missing a v2.4 key", vs: with(kversion.V2_4_0(), 45, -1), exp: KafkaVersion{2, 3}},
+
+		{name: "nothing", vs: new(kversion.Versions), expErr: true},
+		{name: "missing a v0.8 key", vs: with(kversion.V2_8_0(), 0, -1), expErr: true},
+	} {
+		got, err := kafkaVersionOf(test.vs)
+		gotErr := err != nil
+		if gotErr != test.expErr {
+			t.Errorf("%s: got err? %v (%v), exp err? %v", test.name, gotErr, err, test.expErr)
+			continue
+		}
+		if got != test.exp {
+			t.Errorf("%s: got %v != exp %v", test.name, got, test.exp)
+		}
+	}
+}
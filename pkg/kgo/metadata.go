@@ -117,16 +117,19 @@ func (cl *Client) updateMetadataLoop() {
 	defer close(cl.metadone)
 	var consecutiveErrors int
 	var lastAt time.Time
+	first := true
 
 	ticker := time.NewTicker(cl.cfg.metadataMaxAge)
 	defer ticker.Stop()
 loop:
 	for {
 		var now bool
+		trigger := "triggered"
 		select {
 		case <-cl.ctx.Done():
 			return
 		case <-ticker.C:
+			trigger = "periodic"
 		case <-cl.updateMetadataCh:
 		case <-cl.updateMetadataNowCh:
 			now = true
@@ -173,7 +176,18 @@ loop:
 			}
 		}
 
+		if first {
+			trigger = "startup"
+			first = false
+		}
+		updateStart := time.Now()
 		again, err := cl.updateMetadata()
+		updateDur := time.Since(updateStart)
+		cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookMetadataRefresh); ok {
+				h.OnMetadataRefresh(trigger, updateDur, err)
+			}
+		})
 		if again || err != nil {
 			if now && nowTries < 3 {
 				goto start
@@ -379,6 +393,7 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 					leader:      partMeta.Leader,
 					leaderEpoch: leaderEpoch,
 				},
+				isr: partMeta.ISR,
 
 				records: &recBuf{
 					cl: cl,
@@ -386,7 +401,7 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 					topic:     topicMeta.Topic,
 					partition: partMeta.Partition,
 
-					maxRecordBatchBytes: cl.maxRecordBatchBytesForTopic(topicMeta.Topic),
+					maxRecordBatchBytes: cl.maxRecordBatchBytesForTopic(topicMeta.Topic, cl.cfg.maxRecordBatchBytes),
 
 					recBufsIdx: -1,
 					failing:    partMeta.ErrorCode != 0,
@@ -585,3 +600,152 @@ func (cl *Client) mergeTopicPartitions(
 	}
 	return needsRetry
 }
+
+// loadTopicPartition returns the cached topicPartition for a topic and
+// partition, checking topics being produced to before topics being consumed.
+func (cl *Client) loadTopicPartition(topic string, partition int32) *topicPartition {
+	if partition < 0 {
+		return nil
+	}
+	tpss := []*topicsPartitions{cl.producer.topics}
+	c := &cl.consumer
+	c.mu.Lock()
+	switch {
+	case c.d != nil:
+		tpss = append(tpss, c.d.tps)
+	case c.g != nil:
+		tpss = append(tpss, c.g.tps)
+	}
+	c.mu.Unlock()
+
+	for _, tps := range tpss {
+		parts, exists := tps.load()[topic]
+		if !exists {
+			continue
+		}
+		partitions := parts.load().partitions
+		if int(partition) >= len(partitions) {
+			continue
+		}
+		return partitions[partition]
+	}
+	return nil
+}
+
+// brokerMetadata returns the metadata for a known broker, or metadata with
+// only the NodeID set if the client does not know of the broker.
+func (cl *Client) brokerMetadata(id int32) (BrokerMetadata, bool) {
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+	if b, exists := cl.brokers[id]; exists {
+		return b.meta, true
+	}
+	return BrokerMetadata{NodeID: id}, false
+}
+
+// PartitionLeader returns the leader broker for a topic partition from the
+// client's cached metadata. This does not issue any request, meaning only
+// topics the client is producing to or consuming are known, and the leader
+// is only as fresh as the client's latest metadata update.
+//
+// This returns false if the topic or partition is unknown, if the partition
+// has never successfully loaded a leader, or if the leader is not a broker the
+// client knows of.
+func (cl *Client) PartitionLeader(topic string, partition int32) (BrokerMetadata, bool) {
+	p := cl.loadTopicPartition(topic, partition)
+	if p == nil || p.leader < 0 {
+		return BrokerMetadata{}, false
+	}
+	return cl.brokerMetadata(p.leader)
+}
+
+// PartitionISR returns the in sync replicas for a topic partition from the
+// client's cached metadata. Like PartitionLeader, this does not issue any
+// request and only knows topics the client is producing to or consuming.
+//
+// If a replica is not a broker the client knows of, the replica's metadata
+// only has the NodeID set. This returns false if the topic or partition is
+// unknown.
+func (cl *Client) PartitionISR(topic string, partition int32) ([]BrokerMetadata, bool) {
+	p := cl.loadTopicPartition(topic, partition)
+	if p == nil {
+		return nil, false
+	}
+	isr := make([]BrokerMetadata, 0, len(p.isr))
+	for _, id := range p.isr {
+		meta, _ := cl.brokerMetadata(id)
+		isr = append(isr, meta)
+	}
+	return isr, true
+}
+
+// WatchPartitionCount polls metadata for topic every interval and calls fn
+// with the new and old partition counts whenever the count changes. The
+// returned function stops watching; watching also stops once ctx or the
+// client is closed.
+//
+// The first successful metadata load establishes the initial count and does
+// not call fn. Failed metadata loads, or loads for a topic that does not
+// exist, are skipped. The fn is called serially from the watching goroutine.
+//
+// The client already picks up new partitions on its own during its periodic
+// metadata refresh: produced records begin being partitioned to them and
+// consumers begin consuming them (for groups, once the group rebalances). If
+// a change is detected here, the client triggers an immediate metadata
+// refresh so that producing and consuming reacts to the new partitions as
+// soon as possible, rather than waiting for the metadata max age. The fn is
+// for anything outside of the client that needs to react, such as a custom
+// partitioner that caches partition counts or manually assigned partitions.
+//
+// If interval is not positive, the client's MetadataMinAge is used. The fn
+// can be nil if you only want the client to react to changes sooner.
+func (cl *Client) WatchPartitionCount(ctx context.Context, topic string, interval time.Duration, fn func(newCount, oldCount int32)) context.CancelFunc {
+	if interval <= 0 {
+		interval = cl.cfg.metadataMinAge
+	}
+	if fn == nil {
+		fn = func(int32, int32) {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		count := int32(-1)
+		for {
+			if n, ok := cl.loadPartitionCount(ctx, topic); ok {
+				if count >= 0 && n != count {
+					cl.cfg.logger.Log(LogLevelInfo, "watched topic partition count changed", "topic", topic, "old_count", count, "new_count", n)
+					cl.triggerUpdateMetadataNow()
+					fn(n, count)
+				}
+				count = n
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-cl.ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// loadPartitionCount issues a metadata request for topic and returns its
+// number of partitions, if the request succeeded and the topic exists.
+func (cl *Client) loadPartitionCount(ctx context.Context, topic string) (int32, bool) {
+	_, meta, err := cl.fetchMetadataForTopics(ctx, false, []string{topic})
+	if err != nil {
+		return 0, false
+	}
+	for _, t := range meta.Topics {
+		if t.Topic == topic && t.ErrorCode == 0 {
+			return int32(len(t.Partitions)), true
+		}
+	}
+	return 0, false
+}
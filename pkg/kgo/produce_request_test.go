@@ -451,3 +451,45 @@ func BenchmarkAppendBatch(b *testing.B) {
 	}
 
 }
+
+func TestMaxRecordOverheadBytes(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		key     []byte
+		headers []RecordHeader
+		exp     int
+	}{
+		// 66 batch (including the flexible length) + 5 record length +
+		// 3 attrs & deltas + 1 key len + 5 value len + 1 headers len
+		{exp: 81},
+
+		// above + 3 key
+		{key: []byte("key"), exp: 84},
+
+		// above + 1 + 2 header key + 1 + 2 header value
+		{
+			key:     []byte("key"),
+			headers: []RecordHeader{{"hk", []byte("hv")}},
+			exp:     90,
+		},
+	} {
+		if got := maxRecordOverheadBytes(test.key, test.headers); got != test.exp {
+			t.Errorf("got overhead %d != exp %d for key %q headers %v", got, test.exp, test.key, test.headers)
+		}
+	}
+
+	// A value of exactly the computed max must fit in an empty batch for
+	// every produce version.
+	const maxBatchBytes = 1000
+	key := []byte("key")
+	headers := []RecordHeader{{"hk", []byte("hv")}}
+	value := make([]byte, maxBatchBytes-maxRecordOverheadBytes(key, headers))
+	for _, version := range []int32{-1, 0, 2, 3, 9} {
+		batch := &recBatch{wireLength: recordBatchOverhead}
+		pr := promisedRec{Record: &Record{Key: key, Value: value, Headers: headers}}
+		if appended, _ := batch.tryBuffer(pr, version, maxBatchBytes, false); !appended {
+			t.Errorf("produce version %d: max size value was not buffered", version)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/twmb/franz-go/pkg/kbin"
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kmsg"
 )
@@ -37,14 +38,16 @@ type producer struct {
 
 	idMu       sync.Mutex
 	idVersion  int16
+	fenced     uint32 // 1 if our producer ID was fenced; see maybeFenced
 	waitBuffer chan struct{}
 
 	// notifyMu and notifyCond are used for flush and drain notifications.
 	notifyMu   sync.Mutex
 	notifyCond *sync.Cond
 
-	txnMu sync.Mutex
-	inTxn bool
+	txnMu    sync.Mutex
+	inTxn    bool
+	txnBegan time.Time
 }
 
 type unknownTopicProduces struct {
@@ -217,15 +220,21 @@ func (f *FirstErrPromise) Err() error {
 //
 // The context is used if the client currently has the max amount of buffered
 // records. If so, the client waits for some records to complete or for the
-// context or client to quit. If the context / client quits, the promise is
-// called with ctx.Err().
+// context or client to quit. If the client quits, the promise is called with
+// the client's context error. If the context quits, the promise is called with
+// an error that matches both ErrProduceBufferFull and ctx.Err() with
+// errors.Is.
 //
-// The context is also used on a per-partition basis to abort buffered records.
-// If the context is done for the first record buffered in a partition, and if
-// it is valid to abort records (i.e., we can avoid invalid sequence numbers),
-// then all buffered records for a partition are aborted. The context checked
-// for doneness is always the first buffered record's context. The context is
-// evaluated before or after writing a request.
+// The context is also used to avoid sending records that are no longer
+// wanted: if the context is done right before the record is first sent, the
+// record is not sent and the promise is called with ctx.Err(). As well, the
+// context is used on a per-partition basis to abort buffered records. If the
+// context is done for the first record buffered in a partition, and if it is
+// valid to abort records (i.e., we can avoid invalid sequence numbers), then
+// all buffered records for a partition are aborted. The context checked for
+// doneness is always the first buffered record's context. The context is
+// evaluated before or after writing a request. Both of these behaviors can be
+// disabled with ProduceContextDeadlineEnforced(false).
 //
 // The first buffered record for an unknown topic begins a timeout for the
 // configured record timeout limit; all records buffered within the wait will
@@ -241,6 +250,9 @@ func (f *FirstErrPromise) Err() error {
 // If the client is transactional and a transaction has not been begun, the
 // promise is immediately called with an error corresponding to not being in
 // a transaction.
+//
+// If the client's producer ID has been fenced, the promise is immediately
+// called with ErrProducerFenced. See OnProducerFenced.
 func (cl *Client) Produce(
 	ctx context.Context,
 	r *Record,
@@ -258,6 +270,7 @@ func (cl *Client) Produce(
 			return
 		}
 	}
+	cl.injectRecordContext(ctx, r)
 
 	p := &cl.producer
 
@@ -266,6 +279,12 @@ func (cl *Client) Produce(
 		return
 	}
 
+	if atomic.LoadUint32(&p.fenced) == 1 {
+		go promise(r, ErrProducerFenced)
+		return
+	}
+
+	cl.hookBufferedProduceRecord(r)
 	if atomic.AddInt64(&p.bufferedRecords, 1) > cl.cfg.maxBufferedRecords {
 		// If the client ctx cancels or the produce ctx cancels, we
 		// need to un-count our buffering of this record. We also need
@@ -282,7 +301,7 @@ func (cl *Client) Produce(
 		// promise in a goroutine would lead to a deadlock.
 		drainBuffered := func(err error) {
 			go func() { <-p.waitBuffer }()
-			go cl.finishRecordPromise(promisedRec{ctx, promise, r}, err)
+			go cl.finishRecordPromise(promisedRec{ctx: ctx, promise: promise, Record: r}, err)
 		}
 		if cl.cfg.manualFlushing {
 			drainBuffered(ErrMaxBuffered)
@@ -294,17 +313,233 @@ func (cl *Client) Produce(
 			drainBuffered(cl.ctx.Err())
 			return
 		case <-ctx.Done():
-			drainBuffered(ctx.Err())
+			drainBuffered(&errBufferFull{ctx.Err()})
 			return
 		}
 	}
 
-	cl.partitionRecord(promisedRec{ctx, promise, r})
+	cl.partitionRecord(promisedRec{ctx: ctx, promise: promise, Record: r})
+}
+
+// ProduceBytesKV is a shortcut for producing a record to topic with the given
+// key and value, and is equivalent to
+//
+//	cl.Produce(ctx, &Record{Topic: topic, Key: key, Value: value}, promise)
+//
+// See Produce for more details. A nil key is produced as a null key.
+func (cl *Client) ProduceBytesKV(
+	ctx context.Context,
+	topic string,
+	key, value []byte,
+	promise func(*Record, error),
+) {
+	cl.Produce(ctx, &Record{Topic: topic, Key: key, Value: value}, promise)
+}
+
+// ProduceString is a shortcut for producing a record to topic with the given
+// string key and value. The strings are not copied; see KeyStringRecord for
+// more details. An empty key or value is produced as empty, not null; to
+// produce a null key or value, use ProduceBytesKV with a nil slice.
+//
+// See Produce for more details.
+func (cl *Client) ProduceString(
+	ctx context.Context,
+	topic string,
+	key, value string,
+	promise func(*Record, error),
+) {
+	r := KeyStringRecord(key, value)
+	if r.Key == nil {
+		r.Key = []byte{}
+	}
+	if r.Value == nil {
+		r.Value = []byte{}
+	}
+	r.Topic = topic
+	cl.Produce(ctx, r, promise)
+}
+
+// RecordBatch is a group of records that are produced together with
+// ProduceBatch.
+type RecordBatch struct {
+	records []*Record
+}
+
+// Append adds a record to the batch, returning the batch for chaining.
+func (b *RecordBatch) Append(r *Record) *RecordBatch {
+	b.records = append(b.records, r)
+	return b
+}
+
+// Records returns the records in the batch.
+func (b *RecordBatch) Records() []*Record {
+	return b.records
+}
+
+// producingBatch tracks the records of a RecordBatch being produced, calling
+// the user's callback once every record is finished.
+type producingBatch struct {
+	batch *RecordBatch
+	fn    func(*RecordBatch, error)
+	prs   []promisedRec
+
+	mu        sync.Mutex
+	remaining int
+	err       error
+}
+
+func (pb *producingBatch) promise(_ *Record, err error) {
+	pb.mu.Lock()
+	if pb.err == nil {
+		pb.err = err
+	}
+	pb.remaining--
+	done := pb.remaining == 0
+	err = pb.err
+	pb.mu.Unlock()
+
+	if done {
+		pb.fn(pb.batch, err)
+	}
+}
+
+// ProduceBatch produces all records in batch together, calling fn once every
+// record has been acknowledged or failed. The error passed to fn is the first
+// error any record in the batch failed with, if any.
+//
+// All records in the batch must be for the same topic; records with an empty
+// Topic use the DefaultProduceTopic, as with Produce. The partitioner is
+// consulted only with the first record, and every record is produced to the
+// partition it picks. The records always begin a new record batch and are
+// never split across record batches or produce requests, meaning Kafka writes
+// them to the partition atomically. Later records may be added to the same
+// record batch, and the record batch may be sent with others in one produce
+// request. If the records do not fit in one record batch, they all fail with
+// kerr.MessageTooLarge.
+//
+// This otherwise behaves as Produce does with respect to the context,
+// buffering limits, transactions, and updating fields on each record.
+func (cl *Client) ProduceBatch(
+	ctx context.Context,
+	batch *RecordBatch,
+	fn func(*RecordBatch, error),
+) {
+	if fn == nil {
+		fn = func(*RecordBatch, error) {}
+	}
+	if len(batch.records) == 0 {
+		go fn(batch, nil)
+		return
+	}
+
+	var topic string
+	for _, r := range batch.records {
+		if r.Topic == "" {
+			r.Topic = cl.cfg.defaultProduceTopic
+		}
+		if r.Topic == "" {
+			go fn(batch, errors.New("cannot produce to a record that does not have a topic set"))
+			return
+		}
+		if topic == "" {
+			topic = r.Topic
+		}
+		if r.Topic != topic {
+			go fn(batch, fmt.Errorf("cannot produce a batch with records for multiple topics (%s and %s)", topic, r.Topic))
+			return
+		}
+	}
+	for _, r := range batch.records {
+		cl.injectRecordContext(ctx, r)
+	}
+
+	p := &cl.producer
+
+	if cl.cfg.txnID != nil && atomic.LoadUint32(&p.producingTxn) != 1 {
+		go fn(batch, errNotInTransaction)
+		return
+	}
+
+	if atomic.LoadUint32(&p.fenced) == 1 {
+		go fn(batch, ErrProducerFenced)
+		return
+	}
+
+	// We reserve a buffered slot for each record just as Produce does. If
+	// we fail waiting for a slot, we release everything reserved so far.
+	for i, r := range batch.records {
+		cl.hookBufferedProduceRecord(r)
+		if atomic.AddInt64(&p.bufferedRecords, 1) <= cl.cfg.maxBufferedRecords {
+			continue
+		}
+		var err error
+		if cl.cfg.manualFlushing {
+			err = ErrMaxBuffered
+		} else {
+			select {
+			case <-p.waitBuffer:
+				continue
+			case <-cl.ctx.Done():
+				err = cl.ctx.Err()
+			case <-ctx.Done():
+				err = &errBufferFull{ctx.Err()}
+			}
+		}
+		reserved := batch.records[:i+1]
+		go func() { <-p.waitBuffer }()
+		go func() {
+			fn(batch, err)
+			for _, r := range reserved {
+				cl.finishRecordPromise(promisedRec{ctx: ctx, promise: noPromise, Record: r}, err)
+			}
+		}()
+		return
+	}
+
+	pb := &producingBatch{
+		batch:     batch,
+		fn:        fn,
+		remaining: len(batch.records),
+	}
+	for _, r := range batch.records {
+		pb.prs = append(pb.prs, promisedRec{ctx: ctx, promise: pb.promise, Record: r})
+	}
+	head := pb.prs[0]
+	head.batch = pb
+	cl.partitionRecord(head)
+}
+
+func (cl *Client) hookBufferedProduceRecord(r *Record) {
+	cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookProduceRecordBuffered); ok {
+			h.OnProduceRecordBuffered(r)
+		}
+	})
 }
 
 func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
+	if pr.batch != nil {
+		for _, bpr := range pr.batch.prs {
+			cl.finishRecordPromise(bpr, err)
+		}
+		return
+	}
+
 	p := &cl.producer
 
+	if err != nil {
+		atomic.AddInt64(&cl.stats.produceErrors, 1)
+		if fn := cl.cfg.onRecordDropped; fn != nil {
+			fn(pr.Record, err)
+		}
+	}
+	cl.trackTopicProduce(pr, err)
+	cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookProduceRecordUnbuffered); ok {
+			h.OnProduceRecordUnbuffered(pr.Record, err)
+		}
+	})
+
 	// We call the promise before finishing the record; this allows users
 	// of Flush to know that all buffered records are completely done
 	// before Flush returns.
@@ -320,6 +555,88 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 	}
 }
 
+// ProduceInflightRecords returns the number of records that have been passed
+// to Produce (or ProduceBatch) and whose promises have not yet been called.
+// Records blocked in Produce waiting for buffer space (see MaxBufferedRecords)
+// are counted, so this can exceed MaxBufferedRecords. This is the same count
+// that HookProduceRecordBuffered and HookProduceRecordUnbuffered can track.
+func (cl *Client) ProduceInflightRecords() int {
+	return int(atomic.LoadInt64(&cl.producer.bufferedRecords))
+}
+
+// EstimatedProduceLag returns the number of records per topic and partition
+// that are buffered and not yet acknowledged by Kafka.
+//
+// Every partition the client currently knows of for producing is included,
+// even if it has no buffered records. Records for topics that have not yet
+// loaded have no partition and are not counted, whereas HealthCheck's
+// ProduceQueueDepth includes every buffered record. The counts are
+// read atomically per partition, so the returned map is not a consistent
+// snapshot across partitions.
+func (cl *Client) EstimatedProduceLag() map[string]map[int32]int64 {
+	lag := make(map[string]map[int32]int64)
+	for topic, parts := range cl.producer.topics.load() {
+		partitions := parts.load().partitions
+		if len(partitions) == 0 {
+			continue
+		}
+		tlag := make(map[int32]int64, len(partitions))
+		for i, partition := range partitions {
+			if partition.records == nil {
+				continue
+			}
+			tlag[int32(i)] = atomic.LoadInt64(&partition.records.buffered)
+		}
+		lag[topic] = tlag
+	}
+	return lag
+}
+
+// MaxRecordOverheadBytes returns the number of bytes, other than the value
+// itself, that a record with the given key and headers uses when it is the
+// only record in a record batch. A record alone in a batch is the largest a
+// record can be, so the largest value that can be produced with this key and
+// headers is
+//
+//	BatchMaxBytes - MaxRecordOverheadBytes(key, headers)
+//
+// Producing a record with a larger value fails with kerr.MessageTooLarge.
+// Note that BatchMaxBytes is further limited by BrokerMaxWriteBytes
+// minus produce request overhead, and that brokers and topics have their own
+// limits (message.max.bytes and max.message.bytes).
+//
+// This calculation is for message format v2 (Kafka 0.11.0+) and is an upper
+// bound: length prefixes whose size depends on the value size are counted at
+// their maximum size, as is the length prefix of the batch in flexible
+// produce requests. Compression, if enabled, only shrinks the batch.
+func (*Client) MaxRecordOverheadBytes(key []byte, headers []RecordHeader) int {
+	return maxRecordOverheadBytes(key, headers)
+}
+
+func maxRecordOverheadBytes(key []byte, headers []RecordHeader) int {
+	const maxVarintLen32 = 5
+
+	l := 1 + // attributes, int8 unused
+		1 + // timestamp delta, always 0 for the first record in a batch
+		1 + // offset delta, always 0 for the first record in a batch
+		kbin.VarintLen(int32(len(key))) +
+		len(key) +
+		maxVarintLen32 + // value length
+		kbin.VarintLen(int32(len(headers)))
+
+	for _, h := range headers {
+		l += kbin.VarintLen(int32(len(h.Key))) +
+			len(h.Key) +
+			kbin.VarintLen(int32(len(h.Value))) +
+			len(h.Value)
+	}
+
+	return recordBatchOverhead +
+		1 + // flexible produce requests use a uvarint batch length prefix, up to one byte more than non-flexible
+		maxVarintLen32 + // record length
+		l
+}
+
 // partitionRecord loads the partitions for a topic and produce to them. If
 // the topic does not currently exist, the record is buffered in unknownTopics
 // for a metadata update to deal with.
@@ -362,6 +679,11 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 
 	partition := mapping[pick]
 
+	if pr.batch != nil {
+		partition.records.bufferBatch(pr.batch.prs)
+		return
+	}
+
 	processed := partition.records.bufferRecord(pr, true) // KIP-480
 	if !processed {
 		parts.partitioner.OnNewBatch()
@@ -421,7 +743,7 @@ func (cl *Client) producerID() (int64, int16, error) {
 			} else {
 				newID, keep := cl.doInitProducerID(id.id, id.epoch)
 				if keep {
-					id = newID
+					id = cl.maybeFenced(newID)
 					p.id.Store(id)
 				} else {
 					// If we are not keeping the producer ID,
@@ -467,6 +789,9 @@ func (cl *Client) failProducerID(id int64, epoch int16, err error) {
 	defer p.idMu.Unlock()
 
 	current := p.id.Load().(*producerID)
+	if current.err == ErrProducerFenced {
+		return // fenced is permanent
+	}
 	if current.id != id || current.epoch != epoch {
 		cl.cfg.logger.Log(LogLevelInfo, "ignoring a fail producer id request due to current id being different",
 			"current_id", current.id,
@@ -486,11 +811,42 @@ func (cl *Client) failProducerID(id int64, epoch int16, err error) {
 	//
 	// If this is UnknownProducerID with a txnID, then EndTransaction will
 	// recover us.
-	p.id.Store(&producerID{
+	p.id.Store(cl.maybeFenced(&producerID{
 		id:    id,
 		epoch: epoch,
 		err:   err,
-	})
+	}))
+}
+
+// maybeFenced checks if a producer ID failed because we were fenced. If so,
+// this calls the user's OnProducerFenced function (once) and returns the
+// producer ID with ErrProducerFenced, which permanently fails producing.
+//
+// This is called with the producer's idMu held.
+func (cl *Client) maybeFenced(id *producerID) *producerID {
+	fenced := id.err == kerr.ProducerFenced ||
+		// Before KIP-588, InvalidProducerEpoch was not recoverable and
+		// meant we were fenced.
+		id.err == kerr.InvalidProducerEpoch && cl.cfg.txnID != nil && cl.producer.idVersion < 4
+	if !fenced {
+		return id
+	}
+
+	if atomic.SwapUint32(&cl.producer.fenced, 1) == 0 {
+		cl.cfg.logger.Log(LogLevelError, "producer was fenced, failing all future produces",
+			"producer_id", id.id,
+			"producer_epoch", id.epoch,
+			"err", id.err,
+		)
+		if fn := cl.cfg.onProducerFenced; fn != nil {
+			go fn(id.id, id.epoch, id.err)
+		}
+	}
+	return &producerID{
+		id:    id.id,
+		epoch: id.epoch,
+		err:   ErrProducerFenced,
+	}
 }
 
 // doInitProducerID inits the idempotent ID and potentially the transactional
@@ -711,7 +1067,7 @@ func (cl *Client) Flush(ctx context.Context) error {
 	// linger because the producer's flushing atomic int32 is nonzero. We
 	// must wake anything that could be lingering up, after which all sinks
 	// will loop draining.
-	if cl.cfg.linger > 0 || cl.cfg.manualFlushing {
+	if cl.cfg.linger > 0 || cl.cfg.manualFlushing || len(cl.loadTopicProduceCfgs()) > 0 {
 		for _, parts := range p.topics.load() {
 			for _, part := range parts.load().partitions {
 				part.records.unlingerAndManuallyDrain()
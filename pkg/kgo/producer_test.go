@@ -0,0 +1,123 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testUnbufferedHook struct{ errs chan error }
+
+func (h *testUnbufferedHook) OnProduceRecordUnbuffered(_ *Record, err error) { h.errs <- err }
+
+func TestProduceBatchBufferFull(t *testing.T) {
+	t.Parallel()
+
+	h := &testUnbufferedHook{errs: make(chan error, 2)}
+	cl, err := NewClient(
+		ManualFlushing(),
+		MaxBufferedRecords(1),
+		WithHooks(h),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	batchErr := make(chan error, 1)
+	batch := new(RecordBatch).Append(&Record{Topic: "t"}).Append(&Record{Topic: "t"})
+	cl.ProduceBatch(context.Background(), batch, func(_ *RecordBatch, err error) { batchErr <- err })
+
+	if err := <-batchErr; !errors.Is(err, ErrMaxBuffered) {
+		t.Errorf("got batch err %v != exp ErrMaxBuffered", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-h.errs; !errors.Is(err, ErrMaxBuffered) {
+			t.Errorf("got unbuffered hook err %v != exp ErrMaxBuffered", err)
+		}
+	}
+
+	stats := cl.TopicProduceStats()["t"]
+	if stats.TotalRecords != 0 || stats.TotalErrors != 2 {
+		t.Errorf("got (%d records, %d errors) != exp (0, 2)", stats.TotalRecords, stats.TotalErrors)
+	}
+}
+
+func TestTopicProduceStatsLatency(t *testing.T) {
+	t.Parallel()
+
+	cl := new(Client)
+	eventTime := time.Now().Add(-time.Hour)
+
+	// The latency is measured from when the record was buffered, not from
+	// its timestamp, and a record that was never buffered is not counted
+	// in the average.
+	cl.trackTopicProduce(promisedRec{
+		Record:     &Record{Topic: "t", Timestamp: eventTime},
+		bufferedAt: time.Now().Add(-100 * time.Millisecond),
+	}, nil)
+	cl.trackTopicProduce(promisedRec{Record: &Record{Topic: "t", Timestamp: eventTime}}, nil)
+
+	stats := cl.TopicProduceStats()["t"]
+	if stats.TotalRecords != 2 {
+		t.Errorf("got %d records != exp 2", stats.TotalRecords)
+	}
+	if stats.AverageLatencyMs < 100 || stats.AverageLatencyMs > time.Minute.Seconds()*1e3 {
+		t.Errorf("got average latency %vms, exp around 100ms", stats.AverageLatencyMs)
+	}
+}
+
+func TestProduceInflightRecordsCountsBlocked(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(MaxBufferedRecords(1))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// The first record is buffered waiting for its topic to load; the
+	// second blocks in Produce waiting for space until ctx is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cl.Produce(ctx, &Record{Topic: "t"}, nil)
+	blocked := make(chan error, 1)
+	go cl.Produce(ctx, &Record{Topic: "t"}, func(_ *Record, err error) { blocked <- err })
+
+	deadline := time.Now().Add(10 * time.Second)
+	for cl.ProduceInflightRecords() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d inflight records, exp 2", cl.ProduceInflightRecords())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-blocked; !errors.Is(err, context.Canceled) {
+		t.Errorf("got blocked err %v, exp context.Canceled", err)
+	}
+}
+
+type testBufferedHook struct{ rs chan *Record }
+
+func (h *testBufferedHook) OnProduceRecordBuffered(r *Record) { h.rs <- r }
+
+func TestProduceStringEmptyIsNotNull(t *testing.T) {
+	t.Parallel()
+
+	h := &testBufferedHook{rs: make(chan *Record, 1)}
+	cl, err := NewClient(WithHooks(h))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	cl.ProduceString(context.Background(), "t", "", "", nil)
+	r := <-h.rs
+	if r.Key == nil || len(r.Key) != 0 {
+		t.Errorf("got key %v, exp empty non-null key", r.Key)
+	}
+	if r.Value == nil || len(r.Value) != 0 {
+		t.Errorf("got value %v, exp empty non-null value", r.Value)
+	}
+}
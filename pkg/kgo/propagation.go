@@ -0,0 +1,107 @@
+package kgo
+
+import "context"
+
+// ContextPropagator injects values from a context into records when
+// producing, and extracts values from records into a context when consuming.
+// This is most commonly used to propagate trace context through record
+// headers; see WithProduceContextPropagator and WithConsumeContextPropagator.
+//
+// kgo does not depend on any tracing library. To use an OpenTelemetry
+// propagation.TextMapPropagator, wrap it and pass a RecordCarrier, which
+// satisfies OpenTelemetry's propagation.TextMapCarrier:
+//
+//	type otelPropagator struct{ p propagation.TextMapPropagator }
+//
+//	func (o otelPropagator) Inject(ctx context.Context, r *kgo.Record) {
+//	        o.p.Inject(ctx, kgo.NewRecordCarrier(r))
+//	}
+//
+//	func (o otelPropagator) Extract(ctx context.Context, r *kgo.Record) context.Context {
+//	        return o.p.Extract(ctx, kgo.NewRecordCarrier(r))
+//	}
+type ContextPropagator interface {
+	// Inject injects values from ctx into the record.
+	Inject(ctx context.Context, r *Record)
+	// Extract returns ctx enriched with values extracted from the record.
+	Extract(ctx context.Context, r *Record) context.Context
+}
+
+// RecordCarrier adapts a record's headers to a string key/value carrier, as
+// used by propagation libraries.
+type RecordCarrier struct {
+	r *Record
+}
+
+// NewRecordCarrier returns a carrier for the record's headers.
+func NewRecordCarrier(r *Record) RecordCarrier {
+	return RecordCarrier{r}
+}
+
+// Get returns the value of the first header with the given key, or an empty
+// string if there is no such header.
+func (c RecordCarrier) Get(key string) string {
+	for _, h := range c.r.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set sets the value of the first header with the given key, adding a header
+// if there is no such header.
+//
+// The record's headers are copied before being modified, since the same
+// header slice is commonly shared across many produced records.
+func (c RecordCarrier) Set(key, value string) {
+	headers := make([]RecordHeader, len(c.r.Headers), len(c.r.Headers)+1)
+	copy(headers, c.r.Headers)
+	c.r.Headers = headers
+
+	for i, h := range headers {
+		if h.Key == key {
+			headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.r.Headers = append(headers, RecordHeader{Key: key, Value: []byte(value)})
+}
+
+// Keys returns the keys of all headers.
+func (c RecordCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.r.Headers))
+	for _, h := range c.r.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// injectRecordContext calls the produce propagator, if any, defaulting the
+// record's context to the produce context.
+func (cl *Client) injectRecordContext(ctx context.Context, r *Record) {
+	p := cl.cfg.producePropagator
+	if p == nil {
+		return
+	}
+	if r.Context == nil {
+		r.Context = ctx
+	}
+	p.Inject(ctx, r)
+}
+
+// extractRecordContexts calls the consume propagator, if any, for every
+// record in a fetch that is about to be buffered.
+func (s *source) extractRecordContexts(fetch Fetch) {
+	p := s.cl.cfg.consumePropagator
+	if p == nil {
+		return
+	}
+	for _, t := range fetch.Topics {
+		for _, fp := range t.Partitions {
+			for _, r := range fp.Records {
+				r.Context = p.Extract(context.Background(), r)
+			}
+		}
+	}
+}
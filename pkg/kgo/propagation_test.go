@@ -0,0 +1,54 @@
+package kgo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecordCarrierSetCopiesHeaders(t *testing.T) {
+	t.Parallel()
+
+	shared := make([]RecordHeader, 1, 4)
+	shared[0] = RecordHeader{Key: "a", Value: []byte("1")}
+
+	r1, r2 := &Record{Headers: shared}, &Record{Headers: shared}
+	NewRecordCarrier(r1).Set("trace", "x")
+	NewRecordCarrier(r2).Set("trace", "y")
+	NewRecordCarrier(r2).Set("a", "2")
+
+	if exp := []RecordHeader{{Key: "a", Value: []byte("1")}}; !reflect.DeepEqual(shared, exp) {
+		t.Errorf("shared headers modified: got %v != exp %v", shared, exp)
+	}
+	if got := NewRecordCarrier(r1).Get("trace"); got != "x" {
+		t.Errorf("got r1 trace %q != exp x", got)
+	}
+	if got, exp := NewRecordCarrier(r2).Keys(), []string{"a", "trace"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got r2 keys %v != exp %v", got, exp)
+	}
+}
+
+type testPropagator struct{}
+
+func (testPropagator) Inject(_ context.Context, r *Record) { NewRecordCarrier(r).Set("k", "v") }
+
+func (testPropagator) Extract(ctx context.Context, _ *Record) context.Context { return ctx }
+
+func TestInjectRecordContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cl := new(Client)
+	r := new(Record)
+	cl.injectRecordContext(ctx, r)
+	if r.Context != nil || r.Headers != nil {
+		t.Errorf("record modified without a propagator: %+v", r)
+	}
+
+	cl.cfg.producePropagator = testPropagator{}
+	cl.injectRecordContext(ctx, r)
+	if r.Context != ctx || NewRecordCarrier(r).Get("k") != "v" {
+		t.Errorf("record not injected with a propagator: %+v", r)
+	}
+}
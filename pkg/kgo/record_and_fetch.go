@@ -1,6 +1,7 @@
 package kgo
 
 import (
+	"context"
 	"reflect"
 	"time"
 	"unsafe"
@@ -133,6 +134,15 @@ type Record struct {
 	// the offset used in the produce request and does not mirror the
 	// offset actually stored within Kafka.
 	Offset int64
+
+	// Context is an optional field that is used for enriching records.
+	//
+	// For producing, if this field is nil and a propagator is configured
+	// with WithProduceContextPropagator, it is set to the context passed
+	// to Produce; otherwise, it is left alone. For consuming, this is set
+	// to the context returned from the WithConsumeContextPropagator
+	// propagator, if one is configured.
+	Context context.Context
 }
 
 // StringRecord returns a Record with the Value field set to the input value
@@ -211,7 +221,15 @@ type FetchPartition struct {
 	// LogStartOffset is the low watermark of this partition, otherwise
 	// known as the earliest offset in the partition.
 	LogStartOffset int64
-	// Records contains feched records for this partition.
+	// Records contains feched records for this partition, in offset
+	// order.
+	//
+	// This slice can be ranged over directly rather than using the
+	// EachRecord helpers. The client does not read from nor reuse the
+	// slice after it is returned from polling, meaning the slice can be
+	// reordered (e.g., sorted) before processing. Note that reordering
+	// does not change what offsets are committed: the client tracks the
+	// last record per partition before returning a poll.
 	Records []*Record
 }
 
@@ -291,6 +309,18 @@ func (f Fetch) hasErrorsOrRecords() bool {
 	return false
 }
 
+// IsEmpty returns whether the fetches contain no records and no errors, which
+// is the case if polling returned because its context was canceled or because
+// the FetchPollTimeout was reached.
+func (fs Fetches) IsEmpty() bool {
+	for _, f := range fs {
+		if f.hasErrorsOrRecords() {
+			return false
+		}
+	}
+	return true
+}
+
 // IsClientClosed returns whether the fetches includes an error indicating that
 // the client is closed.
 //
@@ -81,6 +81,7 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		idempotent:    s.cl.idempotent(),
 
 		compressor: s.cl.compressor,
+		topicCfgs:  s.cl.loadTopicProduceCfgs(),
 
 		wireLength:      s.cl.baseProduceRequestLength(), // start length with no topics
 		wireLengthLimit: s.cl.cfg.maxBrokerWriteBytes,
@@ -108,6 +109,13 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 		}
 
 		batch := recBuf.batches[recBuf.batchDrainIdx]
+		if batch.tries == 0 && s.cl.cfg.enforceProduceCtx {
+			if batch = recBuf.lockedFailCanceledRecords(recBuf.batchDrainIdx); batch == nil {
+				moreToDrain = moreToDrain || len(recBuf.batches) > recBuf.batchDrainIdx
+				recBuf.mu.Unlock()
+				continue
+			}
+		}
 		if added := req.tryAddBatch(atomic.LoadInt32(&s.produceVersion), recBuf, batch); !added {
 			recBuf.mu.Unlock()
 			moreToDrain = true
@@ -220,7 +228,7 @@ func (s *sink) drain() {
 	// helps when a high volume new sink began draining with no linger;
 	// rather than immediately eating just one record, we allow it to
 	// buffer a bit before we loop draining.
-	if s.cl.cfg.linger == 0 && !s.cl.cfg.manualFlushing {
+	if !s.cl.cfg.manualFlushing && s.anyNoLinger() {
 		time.Sleep(5 * time.Millisecond)
 	}
 
@@ -240,6 +248,22 @@ func (s *sink) drain() {
 	}
 }
 
+// anyNoLinger returns whether any partition on this sink does not linger,
+// which can differ per topic with SetTopicProduceOptions.
+func (s *sink) anyNoLinger() bool {
+	if len(s.cl.loadTopicProduceCfgs()) == 0 {
+		return s.cl.cfg.linger == 0
+	}
+	s.recBufsMu.Lock()
+	defer s.recBufsMu.Unlock()
+	for _, recBuf := range s.recBufs {
+		if recBuf.linger() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *sink) produce(sem <-chan struct{}) bool {
 	var produced bool
 	defer func() {
@@ -533,8 +557,14 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 	// that we count as not-written (not the first batch, error) is removed
 	// from metrics before we return.
 	defer func() {
+		for _, partitions := range req.metrics {
+			for _, metrics := range partitions {
+				atomic.AddInt64(&s.cl.stats.produceRecords, int64(metrics.NumRecords))
+				atomic.AddInt64(&s.cl.stats.produceBytes, int64(metrics.UncompressedBytes))
+			}
+		}
 		if len(req.metrics) > 0 {
-			s.cl.cfg.hooks.each(func(h Hook) {
+			s.cl.hooks.each(func(h Hook) {
 				if h, ok := h.(HookProduceBatchWritten); ok {
 					go func() {
 						for topic, partitions := range req.metrics {
@@ -622,6 +652,13 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 			if retry {
 				reqRetry.addSeqBatch(topic, partition, batch)
 			}
+			if err := kerr.ErrorForCode(rPartition.ErrorCode); err != nil {
+				s.cl.hooks.each(func(h Hook) {
+					if h, ok := h.(HookProducePartitionError); ok {
+						h.OnProducePartitionError(br.meta, topic, partition, err)
+					}
+				})
+			}
 			if !didProduce {
 				delete(tmetrics, partition)
 			}
@@ -755,7 +792,7 @@ func (s *sink) handleReqRespBatch(
 			)
 			s.cl.failProducerID(producerID, producerEpoch, err)
 
-			s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, err)
+			s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, s.produceErrFn(err))
 			if debug {
 				fmt.Fprintf(b, "fatal@%d,%d(%s)}, ", baseOffset, len(batch.records), err)
 			}
@@ -813,7 +850,7 @@ func (s *sink) handleReqRespBatch(
 				"max_retries_reached", batch.tries >= s.cl.cfg.produceRetries,
 			)
 		}
-		s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, err)
+		s.cl.finishBatch(batch.recBatch, producerID, producerEpoch, partition, baseOffset, s.produceErrFn(err))
 		didProduce = err == nil
 		if debug {
 			if err != nil {
@@ -826,19 +863,43 @@ func (s *sink) handleReqRespBatch(
 	return false, didProduce // no retry
 }
 
+// produceErrFn returns a function that wraps a Kafka produce error into a
+// ProduceError for a record, or nil if err is nil.
+func (s *sink) produceErrFn(err error) func(*Record) error {
+	if err == nil {
+		return nil
+	}
+	pe := ProduceError{
+		BrokerID: s.nodeID,
+		APIKey:   0, // produce
+		Message:  err.Error(),
+		Err:      err,
+	}
+	if ke, ok := err.(*kerr.Error); ok {
+		pe.KafkaCode = ke.Code
+		pe.Message = ke.Message
+	}
+	return func(r *Record) error {
+		pe := pe
+		pe.Record = r
+		return pe
+	}
+}
+
 // finishBatch removes a batch from its owning record buffer and finishes all
-// records in the batch.
+// records in the batch. If errFn is non-nil, Kafka replied with an error for
+// the batch and all records are failed with the error returned from errFn.
 //
 // This is safe even if the owning recBuf migrated sinks, since we are
 // finishing based off the status of an inflight req from the original sink.
-func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch int16, partition int32, baseOffset int64, err error) {
+func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch int16, partition int32, baseOffset int64, errFn func(*Record) error) {
 	recBuf := batch.owner
 
-	if err != nil {
+	if errFn != nil {
 		// We know that Kafka replied this batch is a failure. We can
 		// fail this batch and all batches in this partition.
 		// This will keep sequence numbers correct.
-		recBuf.failAllRecords(err)
+		recBuf.failAllRecordsFn(errFn)
 		return
 	}
 
@@ -854,6 +915,7 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 	batch.records = nil
 	batch.mu.Unlock()
 
+	atomic.AddInt64(&recBuf.buffered, -int64(len(records)))
 	for i, pnr := range records {
 		pnr.Offset = baseOffset + int64(i)
 		pnr.Partition = partition
@@ -867,7 +929,7 @@ func (cl *Client) finishBatch(batch *recBatch, producerID int64, producerEpoch i
 		// attrs to our own RecordAttrs.
 		pnr.Attrs = RecordAttrs{uint8(attrs)}
 
-		cl.finishRecordPromise(pnr.promisedRec, err)
+		cl.finishRecordPromise(pnr.promisedRec, nil)
 		records[i] = noPNR
 	}
 	cl.pnrPool.put(records)
@@ -957,6 +1019,12 @@ type recBuf struct {
 	// all buffered records are flushed (if the API is used correctly).
 	addedToTxn bool
 
+	// buffered is the number of records currently buffered in this
+	// recBuf that have not yet been finished. This is atomically updated
+	// when a record is buffered and when a batch's records are finished,
+	// and is read in EstimatedProduceLag.
+	buffered int64
+
 	mu sync.Mutex // guards r/w access to all fields below
 
 	// sink is who is currently draining us. This can be modified
@@ -1032,7 +1100,8 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 	// Timestamp after locking to ensure sequential, and truncate to
 	// milliseconds to avoid some accumulated rounding error problems
 	// (see Shopify/sarama#1455)
-	pr.Timestamp = time.Now().Truncate(time.Millisecond)
+	pr.bufferedAt = time.Now()
+	pr.Timestamp = pr.bufferedAt.Truncate(time.Millisecond)
 
 	var (
 		newBatch       = true
@@ -1042,13 +1111,13 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 
 	if !onDrainBatch {
 		batch := recBuf.batches[len(recBuf.batches)-1]
-		appended, _ := batch.tryBuffer(pr, produceVersion, recBuf.maxRecordBatchBytes, false)
+		appended, _ := batch.tryBuffer(pr, produceVersion, recBuf.maxBatchBytes(), false)
 		newBatch = !appended
 	}
 
 	if newBatch {
 		newBatch := recBuf.newRecordBatch()
-		appended, aborted := newBatch.tryBuffer(pr, produceVersion, recBuf.maxRecordBatchBytes, abortOnNewBatch)
+		appended, aborted := newBatch.tryBuffer(pr, produceVersion, recBuf.maxBatchBytes(), abortOnNewBatch)
 
 		switch {
 		case aborted: // not processed
@@ -1061,8 +1130,49 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 
 		recBuf.batches = append(recBuf.batches, newBatch)
 	}
+	atomic.AddInt64(&recBuf.buffered, 1)
+
+	recBuf.lockedMaybeDrain(newBatch, onDrainBatch)
+	return true
+}
+
+// bufferBatch buffers all records of a RecordBatch into a new record batch
+// so that the records are not split across batches. If the records do not
+// all fit in one batch, every record is failed with kerr.MessageTooLarge.
+func (recBuf *recBuf) bufferBatch(prs []promisedRec) {
+	recBuf.mu.Lock()
+	defer recBuf.mu.Unlock()
+
+	var (
+		bufferedAt     = time.Now()
+		now            = bufferedAt.Truncate(time.Millisecond)
+		onDrainBatch   = recBuf.batchDrainIdx == len(recBuf.batches)
+		produceVersion = atomic.LoadInt32(&recBuf.sink.produceVersion)
+		batch          = recBuf.newRecordBatch()
+	)
 
-	if recBuf.cl.cfg.linger == 0 {
+	for i := range prs {
+		prs[i].bufferedAt = bufferedAt
+		prs[i].Timestamp = now
+		if appended, _ := batch.tryBuffer(prs[i], produceVersion, recBuf.maxBatchBytes(), false); !appended {
+			for _, pr := range prs {
+				recBuf.cl.finishRecordPromise(pr, kerr.MessageTooLarge)
+			}
+			return
+		}
+	}
+
+	recBuf.batches = append(recBuf.batches, batch)
+	atomic.AddInt64(&recBuf.buffered, int64(len(prs)))
+
+	recBuf.lockedMaybeDrain(true, onDrainBatch)
+}
+
+// lockedMaybeDrain begins draining or lingering after buffering, depending
+// on whether we buffered into a new batch and whether that batch is the one
+// to be drained next.
+func (recBuf *recBuf) lockedMaybeDrain(newBatch, onDrainBatch bool) {
+	if recBuf.linger() == 0 {
 		if onDrainBatch {
 			recBuf.sink.maybeDrain()
 		}
@@ -1078,8 +1188,6 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 			recBuf.sink.maybeDrain()
 		}
 	}
-
-	return true
 }
 
 // Stops lingering, potentially restarting it, and returns whether there is
@@ -1090,7 +1198,7 @@ func (recBuf *recBuf) bufferRecord(pr promisedRec, abortOnNewBatch bool) bool {
 // lingering, then we are flushing and also indicate there is more to drain.
 func (recBuf *recBuf) tryStopLingerForDraining() bool {
 	recBuf.lockedStopLinger()
-	canLinger := recBuf.cl.cfg.linger == 0
+	canLinger := recBuf.linger() == 0
 	moreToDrain := !canLinger && len(recBuf.batches) > recBuf.batchDrainIdx ||
 		canLinger && (len(recBuf.batches) > recBuf.batchDrainIdx+1 ||
 			len(recBuf.batches) == recBuf.batchDrainIdx+1 && !recBuf.lockedMaybeStartLinger())
@@ -1102,7 +1210,7 @@ func (recBuf *recBuf) lockedMaybeStartLinger() bool {
 	if atomic.LoadInt32(&recBuf.cl.producer.flushing) == 1 {
 		return false
 	}
-	recBuf.lingering = time.AfterFunc(recBuf.cl.cfg.linger, recBuf.sink.maybeDrain)
+	recBuf.lingering = time.AfterFunc(recBuf.linger(), recBuf.sink.maybeDrain)
 	return true
 }
 
@@ -1151,6 +1259,12 @@ func (recBuf *recBuf) bumpRepeatedLoadErr(err error) {
 //   - if not idempotent && hit retry / timeout limit
 //   - if batch fails fatally when producing
 func (recBuf *recBuf) failAllRecords(err error) {
+	recBuf.failAllRecordsFn(func(*Record) error { return err })
+}
+
+// failAllRecordsFn is failAllRecords, but fails each record with the error
+// returned from errFn for that record.
+func (recBuf *recBuf) failAllRecordsFn(errFn func(*Record) error) {
 	recBuf.lockedStopLinger()
 	for _, batch := range recBuf.batches {
 		// We need to guard our clearing of records against a
@@ -1165,8 +1279,9 @@ func (recBuf *recBuf) failAllRecords(err error) {
 		batch.records = nil
 		batch.mu.Unlock()
 
+		atomic.AddInt64(&recBuf.buffered, -int64(len(records)))
 		for i, pnr := range records {
-			recBuf.cl.finishRecordPromise(pnr.promisedRec, err)
+			recBuf.cl.finishRecordPromise(pnr.promisedRec, errFn(pnr.Record))
 			records[i] = noPNR
 		}
 		recBuf.cl.pnrPool.put(records)
@@ -1175,6 +1290,46 @@ func (recBuf *recBuf) failAllRecords(err error) {
 	recBuf.batches = nil
 }
 
+// lockedFailCanceledRecords fails every record in the unsent batch at idx
+// whose produce context is done. The remaining records are renumbered into a
+// new batch that replaces the old one; since the batch has never been sent,
+// no sequence numbers have been used. If every record is failed, the batch is
+// removed and this returns nil.
+func (recBuf *recBuf) lockedFailCanceledRecords(idx int) *recBatch {
+	batch := recBuf.batches[idx]
+
+	var anyCanceled bool
+	for _, pnr := range batch.records {
+		if pnr.ctx.Err() != nil {
+			anyCanceled = true
+			break
+		}
+	}
+	if !anyCanceled {
+		return batch
+	}
+
+	keep := recBuf.newRecordBatch()
+	for i, pnr := range batch.records {
+		if err := pnr.ctx.Err(); err != nil {
+			atomic.AddInt64(&recBuf.buffered, -1)
+			recBuf.cl.finishRecordPromise(pnr.promisedRec, err)
+		} else {
+			keep.appendRecord(pnr.promisedRec, keep.calculateRecordNumbers(pnr.Record))
+		}
+		batch.records[i] = noPNR
+	}
+	recBuf.cl.pnrPool.put(batch.records)
+
+	if len(keep.records) == 0 {
+		recBuf.cl.pnrPool.put(keep.records)
+		recBuf.batches = append(recBuf.batches[:idx], recBuf.batches[idx+1:]...)
+		return nil
+	}
+	recBuf.batches[idx] = keep
+	return keep
+}
+
 // clearFailing clears a buffer's failing state if it is failing.
 //
 // This is called when a buffer is added to a sink (to clear a failing state
@@ -1201,6 +1356,14 @@ type promisedRec struct {
 	ctx     context.Context
 	promise func(*Record, error)
 	*Record
+
+	// batch, if non-nil, means this record heads a RecordBatch that is
+	// partitioned and buffered as a whole; see ProduceBatch.
+	batch *producingBatch
+
+	// bufferedAt is when the record was buffered into its partition, and
+	// is zero if the record failed before being buffered.
+	bufferedAt time.Time
 }
 
 // promisedNumberedRecord ties a promised record to its calculated numbers.
@@ -1237,7 +1400,7 @@ type recBatch struct {
 
 // Returns an error if the batch should fail.
 func (b *recBatch) maybeFailErr(cfg *cfg) error {
-	if len(b.records) > 0 {
+	if len(b.records) > 0 && cfg.enforceProduceCtx {
 		ctx := b.records[0].ctx
 		select {
 		case <-ctx.Done():
@@ -1279,22 +1442,25 @@ func (b *recBatch) appendRecord(pr promisedRec, nums recordNumbers) {
 	})
 }
 
+// recordBatchOverhead is the wire length of a v2 record batch with no
+// records, including the non-flexible int32 bytes array length prefix.
+const recordBatchOverhead = 4 + // array len
+	8 + // firstOffset
+	4 + // batchLength
+	4 + // partitionLeaderEpoch
+	1 + // magic
+	4 + // crc
+	2 + // attributes
+	4 + // lastOffsetDelta
+	8 + // firstTimestamp
+	8 + // maxTimestamp
+	8 + // producerID
+	2 + // producerEpoch
+	4 + // seq
+	4 // record array length
+
 // newRecordBatch returns a new record batch for a topic and partition.
 func (recBuf *recBuf) newRecordBatch() *recBatch {
-	const recordBatchOverhead = 4 + // array len
-		8 + // firstOffset
-		4 + // batchLength
-		4 + // partitionLeaderEpoch
-		1 + // magic
-		4 + // crc
-		2 + // attributes
-		4 + // lastOffsetDelta
-		8 + // firstTimestamp
-		8 + // maxTimestamp
-		8 + // producerID
-		2 + // producerEpoch
-		4 + // seq
-		4 // record array length
 	return &recBatch{
 		owner:      recBuf,
 		records:    recBuf.cl.pnrPool.get()[:0],
@@ -1359,6 +1525,7 @@ type produceRequest struct {
 	metrics map[string]map[int32]ProduceBatchMetrics
 
 	compressor *compressor
+	topicCfgs  map[string]*topicProduceCfg // per-topic compressor overrides
 
 	// wireLength is initially the size of sending a produce request,
 	// including the request header, with no topics. We start with the
@@ -1417,6 +1584,7 @@ func (r *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch
 		recBuf.partition,
 		recBuf.seq,
 		batch,
+		batch.tries-1,
 	)
 	return true
 }
@@ -1425,11 +1593,15 @@ func (r *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch
 type seqRecBatch struct {
 	seq int32
 	*recBatch
+
+	// retries is how many times the batch was tried before this request,
+	// captured while the batch's recBuf is locked.
+	retries int64
 }
 
 type seqRecBatches map[string]map[int32]seqRecBatch
 
-func (rbs *seqRecBatches) addBatch(topic string, part int32, seq int32, batch *recBatch) {
+func (rbs *seqRecBatches) addBatch(topic string, part int32, seq int32, batch *recBatch, retries int64) {
 	if *rbs == nil {
 		*rbs = make(seqRecBatches, 5)
 	}
@@ -1438,7 +1610,7 @@ func (rbs *seqRecBatches) addBatch(topic string, part int32, seq int32, batch *r
 		topicBatches = make(map[int32]seqRecBatch, 1)
 		(*rbs)[topic] = topicBatches
 	}
-	topicBatches[part] = seqRecBatch{seq, batch}
+	topicBatches[part] = seqRecBatch{seq, batch, retries}
 }
 
 func (rbs *seqRecBatches) addSeqBatch(topic string, part int32, batch seqRecBatch) {
@@ -1529,7 +1701,10 @@ func (cl *Client) baseProduceRequestLength() int32 {
 //
 // Thus in the worst case, we have 14 bytes of prefixes for non-flexible vs.
 // 11 bytes for flexible. We default to the more limiting size: non-flexible.
-func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
+//
+// The result is further limited by cfgLimit, the configured max record batch
+// bytes for the topic.
+func (cl *Client) maxRecordBatchBytesForTopic(topic string, cfgLimit int32) int32 {
 	minOnePartitionBatchLength := cl.baseProduceRequestLength() +
 		2 + // int16 topic string length prefix length
 		int32(len(topic)) +
@@ -1540,7 +1715,7 @@ func (cl *Client) maxRecordBatchBytesForTopic(topic string) int32 {
 	wireLengthLimit := cl.cfg.maxBrokerWriteBytes
 
 	recordBatchLimit := wireLengthLimit - minOnePartitionBatchLength
-	if cfgLimit := cl.cfg.maxRecordBatchBytes; cfgLimit < recordBatchLimit {
+	if cfgLimit < recordBatchLimit {
 		recordBatchLimit = cfgLimit
 	}
 	return recordBatchLimit
@@ -1697,6 +1872,10 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 			dst = kbin.AppendString(dst, topic)
 			dst = kbin.AppendArrayLen(dst, len(partitions))
 		}
+		compressor := p.compressor
+		if tcfg := p.topicCfgs[topic]; tcfg != nil && tcfg.hasCompressor {
+			compressor = tcfg.compressor
+		}
 		tmetrics := make(map[int32]ProduceBatchMetrics)
 		p.metrics[topic] = tmetrics
 		for partition, batch := range partitions {
@@ -1713,11 +1892,12 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 			}
 			var pmetrics ProduceBatchMetrics
 			if p.version < 3 {
-				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), p.compressor)
+				dst, pmetrics = batch.appendToAsMessageSet(dst, uint8(p.version), compressor)
 			} else {
-				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.idempotent, p.txnID != nil, p.compressor)
+				dst, pmetrics = batch.appendTo(dst, p.version, p.producerID, p.producerEpoch, p.idempotent, p.txnID != nil, compressor)
 			}
 			batch.mu.Unlock()
+			pmetrics.NumRetries = int(batch.retries)
 			tmetrics[partition] = pmetrics
 			if flexible {
 				dst = append(dst, 0)
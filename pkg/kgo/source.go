@@ -327,6 +327,7 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 
 			rp.Records = p.Records[:take]
 			p.Records = p.Records[take:]
+			s.hookUnbufferedRecords(rp.Records)
 
 			n -= take
 			taken += take
@@ -367,12 +368,53 @@ func (s *source) takeNBuffered(n int) (Fetch, int, bool) {
 func (s *source) takeBufferedFn(offsetFn func(usedOffsets)) Fetch {
 	r := s.buffered
 	s.buffered = bufferedFetch{}
+	s.hookBufferedFetch(r.fetch, false)
 	offsetFn(r.usedOffsets)
 	r.doneFetch <- struct{}{}
 	close(s.sem)
 	return r.fetch
 }
 
+// hookBufferedFetch calls the fetch record buffered or unbuffered hooks for
+// every record in a fetch.
+func (s *source) hookBufferedFetch(fetch Fetch, buffered bool) {
+	for _, t := range fetch.Topics {
+		for _, p := range t.Partitions {
+			if buffered {
+				s.hookBufferedRecords(p.Records)
+			} else {
+				s.hookUnbufferedRecords(p.Records)
+			}
+		}
+	}
+}
+
+func (s *source) hookBufferedRecords(rs []*Record) {
+	if len(rs) == 0 {
+		return
+	}
+	s.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookFetchRecordBuffered); ok {
+			for _, r := range rs {
+				h.OnFetchRecordBuffered(r)
+			}
+		}
+	})
+}
+
+func (s *source) hookUnbufferedRecords(rs []*Record) {
+	if len(rs) == 0 {
+		return
+	}
+	s.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookFetchRecordUnbuffered); ok {
+			for _, r := range rs {
+				h.OnFetchRecordUnbuffered(r)
+			}
+		}
+	})
+}
+
 // createReq actually creates a fetch request.
 func (s *source) createReq() *fetchRequest {
 	req := &fetchRequest{
@@ -400,6 +442,15 @@ func (s *source) createReq() *fetchRequest {
 		if !c.usable() {
 			continue
 		}
+		if s.cl.consumer.fetchStopped(c.topic, c.partition) {
+			// If the partition is in our fetch session, the broker
+			// will keep returning it; we reset the session after
+			// this request so the partition is dropped.
+			if _, inSession := req.session.used[c.topic][c.partition]; inSession {
+				req.resetSession = true
+			}
+			continue
+		}
 		req.addCursor(c)
 	}
 
@@ -546,7 +597,7 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 		// processed the request but the client failed to receive it).
 		doneFetch <- struct{}{}
 		alreadySentToDoneFetch = true
-		s.session.reset()
+		s.resetSession(br)
 
 		s.cl.triggerUpdateMetadata(false) // as good a time as any
 		s.consecutiveFailures++
@@ -625,31 +676,34 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 			// establish a session for us (and thus is maxed on
 			// sessions). We stop trying.
 			s.cl.cfg.logger.Log(LogLevelInfo, "session failed with SessionIDNotFound while trying to establish a session; broker likely maxed on sessions; continuing on without using sessions", "broker", s.nodeID)
-			s.session.kill()
+			s.killSession(br)
 		} else {
 			s.cl.cfg.logger.Log(LogLevelInfo, "received SessionIDNotFound from our in use session, our session was likely evicted; resetting session", "broker", s.nodeID)
-			s.session.reset()
+			s.resetSession(br)
 		}
 		return
 	case kerr.InvalidFetchSessionEpoch:
 		s.cl.cfg.logger.Log(LogLevelInfo, "resetting fetch session", "broker", s.nodeID, "err", err)
-		s.session.reset()
+		s.resetSession(br)
 		return
 	}
 
 	if resp.Version < 7 {
 		// If the version is less than 7, we cannot use fetch sessions,
 		// so we kill them on the first response.
-		s.session.kill()
+		s.killSession(br)
 	} else if resp.SessionID > 0 {
 		s.session.bumpEpoch(resp.SessionID)
+		s.onSessionChange(br, false)
 	}
 
 	// If we moved any partitions to preferred replicas, we reset the
 	// session. We do this after bumping the epoch just to ensure that we
 	// have truly reset the session. (TODO switch to usingForgottenTopics)
-	if len(preferreds) > 0 {
-		s.session.reset()
+	//
+	// We similarly reset if a partition in the session was stopped.
+	if len(preferreds) > 0 || req.resetSession {
+		s.resetSession(br)
 	}
 
 	if updateMeta && !reloadOffsets.loadWithSessionNow(consumerSession) {
@@ -664,6 +718,8 @@ func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct
 			usedOffsets: req.usedOffsets,
 		}
 		s.sem = make(chan struct{})
+		s.extractRecordContexts(fetch)
+		s.hookBufferedFetch(fetch, true)
 		s.cl.consumer.addSourceReadyForDraining(s)
 	}
 	return
@@ -719,9 +775,17 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 				continue
 			}
 
-			fetchTopic.Partitions = append(fetchTopic.Partitions, partOffset.processRespPartition(br, resp.Version, rp, s.cl.decompressor, s.cl.cfg.hooks))
+			fetchTopic.Partitions = append(fetchTopic.Partitions, partOffset.processRespPartition(br, resp.Version, rp, s.cl.decompressor, s.cl.hooks.load()))
 			fp := &fetchTopic.Partitions[len(fetchTopic.Partitions)-1]
 			updateMeta = updateMeta || fp.Err != nil
+			if fp.Err != nil {
+				atomic.AddInt64(&s.cl.stats.fetchErrors, 1)
+				s.cl.hooks.each(func(h Hook) {
+					if h, ok := h.(HookFetchPartitionError); ok {
+						h.OnFetchPartitionError(br.meta, topic, partition, fp.Err)
+					}
+				})
+			}
 
 			switch fp.Err {
 			default:
@@ -917,7 +981,7 @@ func (o *cursorOffsetNext) processRespPartition(br *broker, version int16, rp *k
 
 		in = in[length:]
 
-		var m FetchBatchMetrics
+		m := FetchBatchMetrics{LeaderEpoch: -1}
 
 		switch t := r.(type) {
 		case *kmsg.MessageV0:
@@ -933,12 +997,15 @@ func (o *cursorOffsetNext) processRespPartition(br *broker, version int16, rp *k
 		case *kmsg.RecordBatch:
 			m.CompressedBytes = len(t.Records) // for record batches, we only track the record batch length
 			m.CompressionType = uint8(t.Attributes) & 0b0000_0111
+			m.LeaderEpoch = t.PartitionLeaderEpoch
 			m.NumRecords, m.UncompressedBytes = o.processRecordBatch(&fp, t, aborter, decompressor)
 		}
 
 		if m.UncompressedBytes == 0 {
 			m.UncompressedBytes = m.CompressedBytes
 		}
+		atomic.AddInt64(&br.cl.stats.fetchRecords, int64(m.NumRecords))
+		atomic.AddInt64(&br.cl.stats.fetchBytes, int64(m.UncompressedBytes))
 		hooks.each(func(h Hook) {
 			if h, ok := h.(HookFetchBatchRead); ok {
 				h.OnFetchBatchRead(br.meta, o.from.topic, o.from.partition, m)
@@ -1397,6 +1464,10 @@ type fetchRequest struct {
 	// built. If the source is reset, the session it has is reset at the
 	// field level only. Our view of the original session is still valid.
 	session fetchSession
+
+	// resetSession is set if a stopped partition is in the session, in
+	// which case the session is reset after the response is handled.
+	resetSession bool
 }
 
 func (f *fetchRequest) addCursor(c *cursor) {
@@ -1533,6 +1604,40 @@ func (s *fetchSession) lookupTopic(topic string) fetchSessionTopic {
 	return t
 }
 
+// resetSession resets the source's fetch session, calling HookFetchSession
+// hooks if a session was established.
+func (s *source) resetSession(br *broker) {
+	established := s.session.epoch > 0
+	s.session.reset()
+	if established {
+		s.onSessionChange(br, true)
+	}
+}
+
+// killSession kills the source's fetch session, calling HookFetchSession
+// hooks if the session was not already killed.
+func (s *source) killSession(br *broker) {
+	killed := s.session.killed
+	s.session.kill()
+	if !killed {
+		s.onSessionChange(br, true)
+	}
+}
+
+// onSessionChange calls HookFetchSession hooks with the current session.
+// The broker is nil if the fetch failed before it could be loaded.
+func (s *source) onSessionChange(br *broker, reset bool) {
+	meta := BrokerMetadata{NodeID: s.nodeID}
+	if br != nil {
+		meta = br.meta
+	}
+	s.cl.hooks.each(func(h Hook) {
+		if h, ok := h.(HookFetchSession); ok {
+			h.OnFetchSession(meta, s.session.id, s.session.epoch, reset)
+		}
+	})
+}
+
 type fetchSessionOffsetEpoch struct {
 	offset int64
 	epoch  int32
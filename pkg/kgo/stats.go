@@ -0,0 +1,188 @@
+package kgo
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RecordStats is a snapshot of cumulative produce and consume counters, as
+// returned from RecordStats.
+type RecordStats struct {
+	// ProduceRecords is the number of records successfully written to
+	// Kafka.
+	ProduceRecords int64
+	// ProduceBytes is the uncompressed size of successfully written
+	// record batches, as reported in ProduceBatchMetrics.
+	ProduceBytes int64
+
+	// FetchRecords is the number of records read in fetch responses,
+	// before records are filtered for being before the fetch offset or
+	// being aborted transactional records.
+	FetchRecords int64
+	// FetchBytes is the uncompressed size of record batches read in fetch
+	// responses, as reported in FetchBatchMetrics.
+	FetchBytes int64
+
+	// ProduceErrors is the number of records that failed to be produced.
+	ProduceErrors int64
+	// FetchErrors is the number of partitions that were returned with an
+	// error in a fetch response, including retryable errors that the
+	// client handles internally.
+	FetchErrors int64
+
+	// ConnectAttempts is the number of times the client dialed a broker.
+	ConnectAttempts int64
+	// ConnectErrors is the number of dials that failed.
+	ConnectErrors int64
+}
+
+// recordStats contains the atomically updated counters backing RecordStats.
+type recordStats struct {
+	produceRecords  int64
+	produceBytes    int64
+	fetchRecords    int64
+	fetchBytes      int64
+	produceErrors   int64
+	fetchErrors     int64
+	connectAttempts int64
+	connectErrors   int64
+}
+
+// RecordStats returns cumulative counters of records and bytes produced and
+// consumed, as well as produce, fetch, and connect errors, since the client
+// was created or since the last ResetRecordStats.
+//
+// This is a simple alternative to hooks (or the kprom plugin) for basic
+// monitoring. Each counter is loaded individually, meaning the snapshot is
+// not guaranteed to be consistent across fields while the client is active.
+func (cl *Client) RecordStats() RecordStats {
+	s := &cl.stats
+	return RecordStats{
+		ProduceRecords:  atomic.LoadInt64(&s.produceRecords),
+		ProduceBytes:    atomic.LoadInt64(&s.produceBytes),
+		FetchRecords:    atomic.LoadInt64(&s.fetchRecords),
+		FetchBytes:      atomic.LoadInt64(&s.fetchBytes),
+		ProduceErrors:   atomic.LoadInt64(&s.produceErrors),
+		FetchErrors:     atomic.LoadInt64(&s.fetchErrors),
+		ConnectAttempts: atomic.LoadInt64(&s.connectAttempts),
+		ConnectErrors:   atomic.LoadInt64(&s.connectErrors),
+	}
+}
+
+// ResetRecordStats resets all counters returned from RecordStats to zero.
+func (cl *Client) ResetRecordStats() {
+	s := &cl.stats
+	for _, n := range []*int64{
+		&s.produceRecords,
+		&s.produceBytes,
+		&s.fetchRecords,
+		&s.fetchBytes,
+		&s.produceErrors,
+		&s.fetchErrors,
+		&s.connectAttempts,
+		&s.connectErrors,
+	} {
+		atomic.StoreInt64(n, 0)
+	}
+}
+
+// TopicProduceStats contains cumulative produce counters for a single topic,
+// as returned from TopicProduceStats.
+type TopicProduceStats struct {
+	// TotalRecords is the number of records successfully produced to the
+	// topic.
+	TotalRecords int64
+	// TotalBytes is the number of key, value, and header bytes of records
+	// successfully produced to the topic.
+	TotalBytes int64
+	// TotalErrors is the number of records that failed to be produced to
+	// the topic.
+	TotalErrors int64
+	// AverageLatencyMs is the average time, in milliseconds, from when a
+	// successfully produced record was buffered into a partition to when
+	// it was acknowledged.
+	AverageLatencyMs float64
+}
+
+// topicProduceStats contains the atomically updated counters backing
+// TopicProduceStats.
+type topicProduceStats struct {
+	records        int64
+	bytes          int64
+	errors         int64
+	latencyNs      int64 // total latency of all measured successful records
+	latencyRecords int64 // number of records measured in latencyNs
+}
+
+// TopicProduceStats returns cumulative per-topic produce counters since the
+// client was created, keyed by topic. A topic is included once any record
+// produced to it has finished, successfully or not.
+//
+// Like RecordStats, this is a simple alternative to hooks for basic
+// monitoring, and each counter is loaded individually.
+func (cl *Client) TopicProduceStats() map[string]TopicProduceStats {
+	all, _ := cl.topicStats.Load().(map[string]*topicProduceStats)
+	stats := make(map[string]TopicProduceStats, len(all))
+	for topic, s := range all {
+		ts := TopicProduceStats{
+			TotalRecords: atomic.LoadInt64(&s.records),
+			TotalBytes:   atomic.LoadInt64(&s.bytes),
+			TotalErrors:  atomic.LoadInt64(&s.errors),
+		}
+		if measured := atomic.LoadInt64(&s.latencyRecords); measured > 0 {
+			ts.AverageLatencyMs = float64(atomic.LoadInt64(&s.latencyNs)) / float64(measured) / 1e6
+		}
+		stats[topic] = ts
+	}
+	return stats
+}
+
+// trackTopicProduce updates the per-topic produce stats for a finished
+// record.
+func (cl *Client) trackTopicProduce(pr promisedRec, err error) {
+	r := pr.Record
+	if r.Topic == "" {
+		return
+	}
+	s := cl.loadTopicProduceStats(r.Topic)
+	if err != nil {
+		atomic.AddInt64(&s.errors, 1)
+		return
+	}
+
+	size := len(r.Key) + len(r.Value)
+	for _, h := range r.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	atomic.AddInt64(&s.records, 1)
+	atomic.AddInt64(&s.bytes, int64(size))
+	if !pr.bufferedAt.IsZero() {
+		atomic.AddInt64(&s.latencyNs, int64(time.Since(pr.bufferedAt)))
+		atomic.AddInt64(&s.latencyRecords, 1)
+	}
+}
+
+// loadTopicProduceStats returns the stats for a topic, creating them if
+// necessary. Creation copies the existing map so that reads can be lockless.
+func (cl *Client) loadTopicProduceStats(topic string) *topicProduceStats {
+	all, _ := cl.topicStats.Load().(map[string]*topicProduceStats)
+	if s, exists := all[topic]; exists {
+		return s
+	}
+
+	cl.topicStatsMu.Lock()
+	defer cl.topicStatsMu.Unlock()
+
+	all, _ = cl.topicStats.Load().(map[string]*topicProduceStats)
+	if s, exists := all[topic]; exists {
+		return s
+	}
+	next := make(map[string]*topicProduceStats, len(all)+1)
+	for t, s := range all {
+		next[t] = s
+	}
+	s := new(topicProduceStats)
+	next[topic] = s
+	cl.topicStats.Store(next)
+	return s
+}
@@ -0,0 +1,145 @@
+package kgo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TopicProduceOptions contains produce settings for a single topic that
+// override the client's producer options, and is built with TopicProduceOpts.
+// Settings that are not set use the client's options.
+//
+// Acks cannot be overridden per topic: every produce request carries a single
+// acks setting for all topics in the request, so RequiredAcks always applies
+// to the whole client.
+type TopicProduceOptions struct {
+	topic string
+
+	codecs  []CompressionCodec
+	hasComp bool
+	maxSize int
+	linger  *time.Duration
+}
+
+// TopicProduceOpts returns options for producing to topic, with no settings
+// overridden.
+func TopicProduceOpts(topic string) TopicProduceOptions {
+	return TopicProduceOptions{topic: topic}
+}
+
+// Compression sets the compression codec preference for the topic, as in the
+// BatchCompression producer option.
+func (o TopicProduceOptions) Compression(preference ...CompressionCodec) TopicProduceOptions {
+	o.codecs = preference
+	o.hasComp = true
+	return o
+}
+
+// MaxBatchSize sets the maximum record batch size for the topic, as in the
+// BatchMaxBytes producer option. This must not be larger than the client's
+// BrokerMaxWriteBytes.
+func (o TopicProduceOptions) MaxBatchSize(n int) TopicProduceOptions {
+	o.maxSize = n
+	return o
+}
+
+// Linger sets how long partitions for the topic linger, as in the Linger
+// producer option.
+func (o TopicProduceOptions) Linger(linger time.Duration) TopicProduceOptions {
+	o.linger = &linger
+	return o
+}
+
+// topicProduceCfg is the validated form of TopicProduceOptions.
+type topicProduceCfg struct {
+	hasCompressor bool
+	compressor    *compressor
+
+	maxRecordBatchBytes int32 // zero if not overridden
+
+	hasLinger bool
+	linger    time.Duration
+}
+
+// SetTopicProduceOptions sets per-topic produce options, replacing any
+// options previously set for the same topics. Passing options with no
+// settings, i.e. just TopicProduceOpts(topic), clears any overrides for that
+// topic.
+//
+// Changes take effect for subsequent produce requests: compression applies to
+// batches written in any later request, the max batch size applies to records
+// buffered after this call, and linger applies to lingers started after this
+// call.
+//
+// If any option is invalid, this returns an error and no options are set.
+func (cl *Client) SetTopicProduceOptions(opts ...TopicProduceOptions) error {
+	tcfgs := make(map[string]*topicProduceCfg, len(opts))
+	for _, o := range opts {
+		if o.topic == "" {
+			return errors.New("invalid empty topic in topic produce options")
+		}
+		tcfg := new(topicProduceCfg)
+		if o.hasComp {
+			compressor, err := newCompressor(append([]CompressionCodec(nil), o.codecs...)...)
+			if err != nil {
+				return fmt.Errorf("topic %s: %w", o.topic, err)
+			}
+			tcfg.hasCompressor = true
+			tcfg.compressor = compressor
+		}
+		if o.maxSize != 0 {
+			if o.maxSize < 512 || o.maxSize > 268435454 || int64(o.maxSize) > int64(cl.cfg.maxBrokerWriteBytes) {
+				return fmt.Errorf("topic %s: invalid max batch size %d, must be between 512 and min(268435454, max broker write bytes %d)", o.topic, o.maxSize, cl.cfg.maxBrokerWriteBytes)
+			}
+			tcfg.maxRecordBatchBytes = int32(o.maxSize)
+		}
+		if o.linger != nil {
+			if *o.linger < 0 || *o.linger > time.Minute {
+				return fmt.Errorf("topic %s: invalid linger %v, must be between 0 and 1m", o.topic, *o.linger)
+			}
+			tcfg.hasLinger = true
+			tcfg.linger = *o.linger
+		}
+		tcfgs[o.topic] = tcfg
+	}
+
+	cl.topicProduceCfgsMu.Lock()
+	defer cl.topicProduceCfgsMu.Unlock()
+
+	existing := cl.loadTopicProduceCfgs()
+	next := make(map[string]*topicProduceCfg, len(existing)+len(tcfgs))
+	for topic, tcfg := range existing {
+		next[topic] = tcfg
+	}
+	for topic, tcfg := range tcfgs {
+		if !tcfg.hasCompressor && tcfg.maxRecordBatchBytes == 0 && !tcfg.hasLinger {
+			delete(next, topic)
+			continue
+		}
+		next[topic] = tcfg
+	}
+	cl.topicProduceCfgs.Store(next)
+	return nil
+}
+
+func (cl *Client) loadTopicProduceCfgs() map[string]*topicProduceCfg {
+	tcfgs, _ := cl.topicProduceCfgs.Load().(map[string]*topicProduceCfg)
+	return tcfgs
+}
+
+// linger returns the linger for this recBuf's topic.
+func (recBuf *recBuf) linger() time.Duration {
+	if tcfg := recBuf.cl.loadTopicProduceCfgs()[recBuf.topic]; tcfg != nil && tcfg.hasLinger {
+		return tcfg.linger
+	}
+	return recBuf.cl.cfg.linger
+}
+
+// maxBatchBytes returns the max record batch bytes for this recBuf's topic.
+func (recBuf *recBuf) maxBatchBytes() int32 {
+	if tcfg := recBuf.cl.loadTopicProduceCfgs()[recBuf.topic]; tcfg != nil && tcfg.maxRecordBatchBytes > 0 {
+		return recBuf.cl.maxRecordBatchBytesForTopic(recBuf.topic, tcfg.maxRecordBatchBytes)
+	}
+	return recBuf.maxRecordBatchBytes
+}
@@ -239,6 +239,10 @@ type topicPartition struct {
 	// whether the data changed (leader or leader epoch, etc.).
 	topicPartitionData
 
+	// isr is the in sync replica set from the metadata response that
+	// created this topicPartition, for PartitionISR.
+	isr []int32
+
 	// If we do not have a load error, we copy the records and cursor
 	// pointers from the old after updating any necessary fields in them
 	// (see migrate functions below).
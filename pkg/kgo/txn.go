@@ -368,6 +368,73 @@ retryUnattempted:
 	}
 }
 
+// ExactlyOnceGroupConsume runs the full consume-transform-produce loop for
+// exactly once semantics: poll, begin a transaction, call processFunc with the
+// polled records, and end the transaction, committing if processFunc returns
+// nil. The processFunc should produce its output with this session's Produce
+// or ProduceSync so that the output is part of the transaction.
+//
+// If processFunc returns an error, the transaction is aborted, the consumer is
+// reset to the last committed offsets, and the error is returned. Calling this
+// again reprocesses the same records. If a rebalance happens while
+// processing, the transaction is aborted and the loop continues; the records
+// are reprocessed by whichever member is assigned their partitions. This
+// returns when ctx is canceled, when the client is closed (ErrClientClosed),
+// when a poll returns any partition error, or when ending a transaction fails.
+//
+// The guarantee is that, for records consumed from Kafka, the records produced
+// by processFunc and the consumed offsets are committed atomically: consumers
+// of the output reading with ReadCommitted see the output of each input record
+// exactly once. This does NOT make processFunc itself run once per record: it
+// is called again for records in aborted transactions, so any side effects
+// outside of producing to Kafka (database writes, HTTP calls) can happen more
+// than once. Output read with ReadUncommitted can include aborted records.
+//
+// Transactions are ended with a background context, because canceling a
+// transaction as it ends can leave the client in an undesirable state (see
+// End). Canceling ctx stops polling and is passed to processFunc, but does not
+// interrupt ending a transaction. If ending fails, this aborts anything left
+// of the transaction, resets the consumer to the last committed offsets, and
+// returns the error.
+func (s *GroupTransactSession) ExactlyOnceGroupConsume(ctx context.Context, processFunc func(context.Context, []*Record) error) error {
+	for {
+		fetches := s.PollFetches(ctx)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if fetches.IsClientClosed() {
+			return ErrClientClosed
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			e := errs[0]
+			return fmt.Errorf("poll error on topic %s partition %d: %w", e.Topic, e.Partition, e.Err)
+		}
+
+		var records []*Record
+		fetches.EachRecord(func(r *Record) { records = append(records, r) })
+		if len(records) == 0 {
+			continue
+		}
+
+		if err := s.Begin(); err != nil {
+			return err
+		}
+		processErr := processFunc(ctx, records)
+		if _, err := s.End(context.Background(), processErr == nil); err != nil {
+			bg := context.Background()
+			s.cl.AbortBufferedRecords(bg)
+			if abortErr := s.cl.EndTransaction(bg, TryAbort); abortErr != nil && abortErr != errNotInTransaction {
+				s.cl.cfg.logger.Log(LogLevelError, "unable to abort transaction after failing to end it", "end_err", err, "abort_err", abortErr)
+			}
+			s.cl.SetOffsets(s.cl.CommittedOffsets())
+			return err
+		}
+		if processErr != nil {
+			return processErr
+		}
+	}
+}
+
 // BeginTransaction sets the client to a transactional state, erroring if there
 // is no transactional ID, or if the producer is currently in a fatal
 // (unrecoverable) state, or if the client is already in a transaction.
@@ -392,6 +459,7 @@ func (cl *Client) BeginTransaction() error {
 	}
 
 	cl.producer.inTxn = true
+	cl.producer.txnBegan = time.Now()
 	atomic.StoreUint32(&cl.producer.producingTxn, 1) // allow produces for txns now
 	cl.cfg.logger.Log(LogLevelInfo, "beginning transaction", "transactional_id", *cl.cfg.txnID)
 
@@ -453,7 +521,7 @@ func (cl *Client) AbortBufferedRecords(ctx context.Context) error {
 // undesirable state, because canceling the context may cancel the in-flight
 // EndTransaction request, making it impossible to know whether the commit or
 // abort was successful. It is recommended to not cancel the context.
-func (cl *Client) EndTransaction(ctx context.Context, commit TransactionEndTry) error {
+func (cl *Client) EndTransaction(ctx context.Context, commit TransactionEndTry) (err error) {
 	cl.producer.txnMu.Lock()
 	defer cl.producer.txnMu.Unlock()
 
@@ -482,6 +550,15 @@ func (cl *Client) EndTransaction(ctx context.Context, commit TransactionEndTry)
 	}
 	cl.producer.inTxn = false
 
+	txnDur := time.Since(cl.producer.txnBegan)
+	defer func() {
+		cl.hooks.each(func(h Hook) {
+			if h, ok := h.(HookTransactionEnd); ok {
+				h.OnTransactionEnd(bool(commit), txnDur, err)
+			}
+		})
+	}()
+
 	// After the flush, no records are being produced to, and we can set
 	// addedToTxn to false outside of any mutex.
 	for _, parts := range cl.producer.topics.load() {
@@ -655,7 +732,7 @@ func (cl *Client) commitTransactionOffsets(
 
 	g := cl.consumer.g
 	if g == nil {
-		onDone(new(kmsg.TxnOffsetCommitRequest), new(kmsg.TxnOffsetCommitResponse), errNotGroup)
+		onDone(new(kmsg.TxnOffsetCommitRequest), new(kmsg.TxnOffsetCommitResponse), ErrNotInGroup)
 		return nil
 	}
 	if len(uncommitted) == 0 {
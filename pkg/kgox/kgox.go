@@ -0,0 +1,47 @@
+// Package kgox contains convenience helpers for the kgo client that are kept
+// out of kgo itself to avoid extra imports in the core client.
+package kgox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ProduceJSON marshals value with encoding/json and produces it to topic
+// with the given key, calling fn once the record is produced or fails, as in
+// kgo.Client.Produce.
+//
+// If marshaling fails, the marshal error is returned and fn is not called.
+// An empty key produces a record with a nil key.
+func ProduceJSON(ctx context.Context, cl *kgo.Client, topic, key string, value interface{}, fn func(*kgo.Record, error)) error {
+	r, err := jsonRecord(topic, key, value)
+	if err != nil {
+		return err
+	}
+	cl.Produce(ctx, r, fn)
+	return nil
+}
+
+// ProduceJSONSync is a synchronous version of ProduceJSON, returning either
+// the marshal error or the produce error.
+func ProduceJSONSync(ctx context.Context, cl *kgo.Client, topic, key string, value interface{}) error {
+	r, err := jsonRecord(topic, key, value)
+	if err != nil {
+		return err
+	}
+	return cl.ProduceSync(ctx, r).FirstErr()
+}
+
+func jsonRecord(topic, key string, value interface{}) (*kgo.Record, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	r := &kgo.Record{Topic: topic, Value: v}
+	if key != "" {
+		r.Key = []byte(key)
+	}
+	return r, nil
+}
@@ -0,0 +1,315 @@
+package kmock
+
+import (
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func (b *Broker) handleApiVersions() kmsg.Response {
+	resp := kmsg.NewPtrApiVersionsResponse()
+	for key, min := range handled {
+		max := kmsg.RequestForKey(key).MaxVersion()
+		resp.ApiKeys = append(resp.ApiKeys, kmsg.ApiVersionsResponseApiKey{
+			ApiKey:     key,
+			MinVersion: min,
+			MaxVersion: max,
+		})
+	}
+	return resp
+}
+
+func (b *Broker) handleMetadata(req *kmsg.MetadataRequest) kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := kmsg.NewPtrMetadataResponse()
+	resp.Brokers = append(resp.Brokers, kmsg.MetadataResponseBroker{
+		NodeID: 0,
+		Host:   b.host,
+		Port:   b.port,
+	})
+	resp.ClusterID = &b.clusterID
+	resp.ControllerID = 0
+
+	addTopic := func(topic string) {
+		t := kmsg.NewMetadataResponseTopic()
+		t.Topic = topic
+		ps, exists := b.topics[topic]
+		if !exists {
+			// Auto creation defaults to true before v4.
+			if req.Version >= 4 && !req.AllowAutoTopicCreation {
+				t.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				resp.Topics = append(resp.Topics, t)
+				return
+			}
+			b.ensurePartitions(topic, b.defaultPartitions)
+			ps = b.topics[topic]
+		}
+		for i := range ps {
+			p := kmsg.NewMetadataResponseTopicPartition()
+			p.Partition = int32(i)
+			p.Leader = 0
+			p.LeaderEpoch = 0
+			p.Replicas = []int32{0}
+			p.ISR = []int32{0}
+			t.Partitions = append(t.Partitions, p)
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+
+	if req.Topics == nil {
+		for topic := range b.topics {
+			addTopic(topic)
+		}
+	} else {
+		for _, t := range req.Topics {
+			if t.Topic != nil {
+				addTopic(*t.Topic)
+			}
+		}
+	}
+	return resp
+}
+
+func (b *Broker) handleProduce(req *kmsg.ProduceRequest) kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := kmsg.NewPtrProduceResponse()
+	var added bool
+	for _, rt := range req.Topics {
+		st := kmsg.NewProduceResponseTopic()
+		st.Topic = rt.Topic
+		for _, rp := range rt.Partitions {
+			sp := kmsg.NewProduceResponseTopicPartition()
+			sp.Partition = rp.Partition
+			sp.LogAppendTime = -1
+
+			ps := b.topics[rt.Topic]
+			if rp.Partition < 0 || int(rp.Partition) >= len(ps) {
+				sp.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				st.Partitions = append(st.Partitions, sp)
+				continue
+			}
+			batches, err := splitBatches(rp.Records)
+			if err != nil || len(batches) == 0 {
+				sp.ErrorCode = kerr.CorruptMessage.Code
+				st.Partitions = append(st.Partitions, sp)
+				continue
+			}
+
+			p := ps[rp.Partition]
+			sp.BaseOffset = p.hwm
+			for _, raw := range batches {
+				rs, err := decodeBatch(raw)
+				if err != nil {
+					b.t.Errorf("kmock: unable to decode record batch produced to %s[%d]: %v", rt.Topic, rp.Partition, err)
+				}
+				b.checkExpectsLocked(rt.Topic, rp.Partition, rs)
+				p.append(raw)
+			}
+			added = true
+			st.Partitions = append(st.Partitions, sp)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	if added {
+		b.notifyLocked()
+	}
+
+	if req.Acks == 0 {
+		return nil
+	}
+	return resp
+}
+
+func (b *Broker) handleFetch(req *kmsg.FetchRequest) kmsg.Response {
+	deadline := time.Now().Add(time.Duration(req.MaxWaitMillis) * time.Millisecond)
+	for {
+		b.mu.Lock()
+		resp, nbytes := b.buildFetchLocked(req)
+		notify := b.notify
+		b.mu.Unlock()
+
+		wait := time.Until(deadline)
+		if nbytes >= int(req.MinBytes) && (nbytes > 0 || req.MinBytes <= 0) || wait <= 0 {
+			return resp
+		}
+		b.waitFor(notify, wait)
+
+		select {
+		case <-b.die:
+			return resp
+		default:
+		}
+	}
+}
+
+func (b *Broker) buildFetchLocked(req *kmsg.FetchRequest) (*kmsg.FetchResponse, int) {
+	resp := kmsg.NewPtrFetchResponse()
+	var nbytes int
+	for _, rt := range req.Topics {
+		st := kmsg.NewFetchResponseTopic()
+		st.Topic = rt.Topic
+		for _, rp := range rt.Partitions {
+			sp := kmsg.NewFetchResponseTopicPartition()
+			sp.Partition = rp.Partition
+
+			ps := b.topics[rt.Topic]
+			if rp.Partition < 0 || int(rp.Partition) >= len(ps) {
+				sp.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				st.Partitions = append(st.Partitions, sp)
+				continue
+			}
+			p := ps[rp.Partition]
+			sp.HighWatermark = p.hwm
+			sp.LastStableOffset = p.hwm
+			sp.LogStartOffset = 0
+			if rp.FetchOffset < 0 || rp.FetchOffset > p.hwm {
+				sp.ErrorCode = kerr.OffsetOutOfRange.Code
+				st.Partitions = append(st.Partitions, sp)
+				continue
+			}
+			sp.RecordBatches = p.fetch(rp.FetchOffset, rp.PartitionMaxBytes)
+			nbytes += len(sp.RecordBatches)
+			st.Partitions = append(st.Partitions, sp)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	return resp, nbytes
+}
+
+func (b *Broker) handleListOffsets(req *kmsg.ListOffsetsRequest) kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := kmsg.NewPtrListOffsetsResponse()
+	for _, rt := range req.Topics {
+		st := kmsg.NewListOffsetsResponseTopic()
+		st.Topic = rt.Topic
+		for _, rp := range rt.Partitions {
+			sp := kmsg.NewListOffsetsResponseTopicPartition()
+			sp.Partition = rp.Partition
+
+			ps := b.topics[rt.Topic]
+			if rp.Partition < 0 || int(rp.Partition) >= len(ps) {
+				sp.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				st.Partitions = append(st.Partitions, sp)
+				continue
+			}
+			p := ps[rp.Partition]
+			sp.Timestamp = -1
+			sp.LeaderEpoch = 0
+			switch rp.Timestamp {
+			case -2:
+				sp.Offset = 0
+			case -1:
+				sp.Offset = p.hwm
+			default:
+				sp.Offset = p.offsetForTimestamp(rp.Timestamp)
+			}
+			st.Partitions = append(st.Partitions, sp)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	return resp
+}
+
+func (b *Broker) handleInitProducerID() kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	resp := kmsg.NewPtrInitProducerIDResponse()
+	resp.ProducerID = b.producerID
+	resp.ProducerEpoch = 0
+	b.producerID++
+	return resp
+}
+
+func (b *Broker) handleFindCoordinator() kmsg.Response {
+	resp := kmsg.NewPtrFindCoordinatorResponse()
+	resp.NodeID = 0
+	resp.Host = b.host
+	resp.Port = b.port
+	return resp
+}
+
+func (b *Broker) handleOffsetCommit(req *kmsg.OffsetCommitRequest) kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.groups[req.Group]
+	if group == nil {
+		group = make(map[string]map[int32]committed)
+		b.groups[req.Group] = group
+	}
+
+	resp := kmsg.NewPtrOffsetCommitResponse()
+	for _, rt := range req.Topics {
+		st := kmsg.NewOffsetCommitResponseTopic()
+		st.Topic = rt.Topic
+		topic := group[rt.Topic]
+		if topic == nil {
+			topic = make(map[int32]committed)
+			group[rt.Topic] = topic
+		}
+		for _, rp := range rt.Partitions {
+			sp := kmsg.NewOffsetCommitResponseTopicPartition()
+			sp.Partition = rp.Partition
+			topic[rp.Partition] = committed{
+				offset:   rp.Offset,
+				epoch:    rp.LeaderEpoch,
+				metadata: rp.Metadata,
+			}
+			st.Partitions = append(st.Partitions, sp)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	return resp
+}
+
+func (b *Broker) handleOffsetFetch(req *kmsg.OffsetFetchRequest) kmsg.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.groups[req.Group]
+
+	resp := kmsg.NewPtrOffsetFetchResponse()
+	addPartition := func(st *kmsg.OffsetFetchResponseTopic, partition int32, c committed, ok bool) {
+		sp := kmsg.NewOffsetFetchResponseTopicPartition()
+		sp.Partition = partition
+		sp.Offset = -1
+		sp.LeaderEpoch = -1
+		if ok {
+			sp.Offset = c.offset
+			sp.LeaderEpoch = c.epoch
+			sp.Metadata = c.metadata
+		}
+		st.Partitions = append(st.Partitions, sp)
+	}
+
+	if req.Topics == nil {
+		for topic, partitions := range group {
+			st := kmsg.NewOffsetFetchResponseTopic()
+			st.Topic = topic
+			for partition, c := range partitions {
+				addPartition(&st, partition, c, true)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp
+	}
+
+	for _, rt := range req.Topics {
+		st := kmsg.NewOffsetFetchResponseTopic()
+		st.Topic = rt.Topic
+		for _, partition := range rt.Partitions {
+			c, ok := group[rt.Topic][partition]
+			addPartition(&st, partition, c, ok)
+		}
+		resp.Topics = append(resp.Topics, st)
+	}
+	return resp
+}
@@ -0,0 +1,423 @@
+// Package kmock provides a minimal in-process fake Kafka broker for unit
+// testing code that uses kgo.
+//
+// The fake broker is a single node cluster that speaks the Kafka wire
+// protocol over a local TCP listener. It understands enough of the protocol
+// to produce and consume (ApiVersions, Metadata, Produce, Fetch, ListOffsets,
+// InitProducerID) and to commit and fetch group offsets (FindCoordinator,
+// OffsetCommit, OffsetFetch). Group membership requests (JoinGroup, SyncGroup,
+// Heartbeat, LeaveGroup) and transactions are not supported; the broker does
+// not advertise them, so clients fail those requests with
+// ErrUnknownRequestKey.
+//
+// A broker is created for a single test and is shutdown when the test
+// finishes:
+//
+//	b := kmock.NewBroker(t)
+//	b.ExpectProduce("foo", 0, &kgo.Record{Value: []byte("bar")})
+//
+//	cl, _ := kgo.NewClient(kgo.SeedBrokers(b.Addr()))
+//	defer cl.Close()
+//
+// Any expected produce that is not seen by the time the test finishes fails
+// the test.
+package kmock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kbin"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Opt is an option to configure a Broker.
+type Opt interface {
+	apply(*Broker)
+}
+
+type opt struct{ fn func(*Broker) }
+
+func (o opt) apply(b *Broker) { o.fn(b) }
+
+// SeedTopics creates the given topics with the given number of partitions
+// when the broker starts.
+func SeedTopics(partitions int32, topics ...string) Opt {
+	return opt{func(b *Broker) {
+		for _, topic := range topics {
+			b.ensurePartitions(topic, partitions)
+		}
+	}}
+}
+
+// DefaultPartitions sets the number of partitions that topics are created
+// with when they are auto created from a metadata request, overriding the
+// default of 1.
+//
+// Topics are only auto created if the client allows it with
+// kgo.AllowAutoTopicCreation.
+func DefaultPartitions(partitions int32) Opt {
+	return opt{func(b *Broker) { b.defaultPartitions = partitions }}
+}
+
+// ClusterID sets the cluster ID returned in metadata responses, overriding
+// the default of "kmock".
+func ClusterID(id string) Opt {
+	return opt{func(b *Broker) { b.clusterID = id }}
+}
+
+// Broker is a fake single node Kafka cluster.
+type Broker struct {
+	t    testing.TB
+	ln   net.Listener
+	host string
+	port int32
+
+	defaultPartitions int32
+	clusterID         string
+
+	die   chan struct{}
+	wg    sync.WaitGroup
+	cxnMu sync.Mutex
+	cxns  map[net.Conn]struct{}
+
+	mu sync.Mutex
+
+	// notify is closed and replaced whenever records are added to any
+	// partition, waking up any fetch that is waiting for data.
+	notify chan struct{}
+
+	topics     map[string][]*partition
+	groups     map[string]map[string]map[int32]committed
+	producerID int64
+
+	expects map[string]map[int32][]*kgo.Record
+}
+
+type committed struct {
+	offset   int64
+	epoch    int32
+	metadata *string
+}
+
+// NewBroker starts a fake broker listening on a random local port. The
+// broker is shutdown with t.Cleanup, at which point any unmet produce
+// expectations fail the test.
+func NewBroker(t testing.TB, opts ...Opt) *Broker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("kmock: unable to listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	nport, _ := strconv.Atoi(port)
+
+	b := &Broker{
+		t:    t,
+		ln:   ln,
+		host: host,
+		port: int32(nport),
+
+		defaultPartitions: 1,
+		clusterID:         "kmock",
+
+		die:  make(chan struct{}),
+		cxns: make(map[net.Conn]struct{}),
+
+		notify: make(chan struct{}),
+		topics: make(map[string][]*partition),
+		groups: make(map[string]map[string]map[int32]committed),
+
+		expects: make(map[string]map[int32][]*kgo.Record),
+	}
+	for _, opt := range opts {
+		opt.apply(b)
+	}
+
+	b.wg.Add(1)
+	go b.listen()
+
+	t.Cleanup(b.close)
+	return b
+}
+
+// Addr returns the host:port the broker is listening on, suitable for
+// kgo.SeedBrokers.
+func (b *Broker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+// ExpectProduce expects the given records to be produced, in order, to the
+// given topic and partition. Only the key, value, and headers of each record
+// are compared. Produced records that do not match the next expected record
+// fail the test immediately, and any expected records that have not been
+// produced by the end of the test fail the test at cleanup.
+//
+// Produced records are always accepted and can be consumed afterwards,
+// regardless of any expectations. This creates the topic, or adds
+// partitions to it, if necessary.
+func (b *Broker) ExpectProduce(topic string, partition int32, records ...*kgo.Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ensurePartitions(topic, partition+1)
+	ps := b.expects[topic]
+	if ps == nil {
+		ps = make(map[int32][]*kgo.Record)
+		b.expects[topic] = ps
+	}
+	ps[partition] = append(ps[partition], records...)
+}
+
+// ExpectFetch appends the given records to the given topic and partition so
+// that they are returned to any client consuming the partition. Records are
+// assigned offsets in order, following anything already in the partition.
+// A record with a zero timestamp is given the current time.
+//
+// This creates the topic, or adds partitions to it, if necessary.
+func (b *Broker) ExpectFetch(topic string, partition int32, records ...*kgo.Record) {
+	if len(records) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ensurePartitions(topic, partition+1)
+	p := b.topics[topic][partition]
+	p.append(newBatch(p.hwm, records))
+	b.notifyLocked()
+}
+
+// ensurePartitions ensures that the topic exists with at least the given
+// number of partitions.
+func (b *Broker) ensurePartitions(topic string, partitions int32) {
+	ps := b.topics[topic]
+	for int32(len(ps)) < partitions {
+		ps = append(ps, new(partition))
+	}
+	b.topics[topic] = ps
+}
+
+func (b *Broker) notifyLocked() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// close stops the broker and checks that all expectations were met.
+func (b *Broker) close() {
+	close(b.die)
+	b.ln.Close()
+	b.cxnMu.Lock()
+	for cxn := range b.cxns {
+		cxn.Close()
+	}
+	b.cxnMu.Unlock()
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for topic, ps := range b.expects {
+		for partition, rs := range ps {
+			if len(rs) > 0 {
+				b.t.Errorf("kmock: %d expected record(s) were never produced to %s[%d]", len(rs), topic, partition)
+			}
+		}
+	}
+}
+
+func (b *Broker) listen() {
+	defer b.wg.Done()
+	for {
+		cxn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.cxnMu.Lock()
+		select {
+		case <-b.die:
+			b.cxnMu.Unlock()
+			cxn.Close()
+			return
+		default:
+		}
+		b.cxns[cxn] = struct{}{}
+		b.cxnMu.Unlock()
+
+		b.wg.Add(1)
+		go b.handleConn(cxn)
+	}
+}
+
+// handleConn reads and handles requests serially, which guarantees that
+// responses are written in the order that requests were received.
+func (b *Broker) handleConn(cxn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.cxnMu.Lock()
+		delete(b.cxns, cxn)
+		b.cxnMu.Unlock()
+		cxn.Close()
+	}()
+
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(cxn, size[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(cxn, body); err != nil {
+			return
+		}
+
+		req, corrID, err := parseRequest(body)
+		if err != nil {
+			b.t.Logf("kmock: closing connection: %v", err)
+			return
+		}
+
+		resp := b.handle(req)
+		if resp == nil {
+			continue // acks=0 produce, no response
+		}
+		resp.SetVersion(req.GetVersion())
+
+		buf := append(make([]byte, 4, 64), 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[4:], uint32(corrID))
+		// The ApiVersions response header is never flexible.
+		if resp.IsFlexible() && resp.Key() != 18 {
+			buf = append(buf, 0)
+		}
+		buf = resp.AppendTo(buf)
+		binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+		if _, err := cxn.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+var errUnsupported = errors.New("unsupported request")
+
+func parseRequest(body []byte) (kmsg.Request, int32, error) {
+	r := kbin.Reader{Src: body}
+	key := r.Int16()
+	version := r.Int16()
+	corrID := r.Int32()
+	r.NullableString() // client ID
+
+	min, ok := handled[key]
+	if !ok {
+		return nil, corrID, fmt.Errorf("%w: key %d", errUnsupported, key)
+	}
+	req := kmsg.RequestForKey(key)
+	if version < min || version > req.MaxVersion() {
+		return nil, corrID, fmt.Errorf("%w: key %d version %d", errUnsupported, key, version)
+	}
+	req.SetVersion(version)
+
+	if req.IsFlexible() {
+		for n := r.Uvarint(); n > 0; n-- {
+			r.Uvarint() // tag
+			r.Span(int(r.Uvarint()))
+		}
+	}
+	if err := r.Complete(); err != nil {
+		return nil, corrID, fmt.Errorf("unable to read request header: %w", err)
+	}
+	if err := req.ReadFrom(r.Src); err != nil {
+		return nil, corrID, fmt.Errorf("unable to read key %d version %d: %w", key, version, err)
+	}
+	return req, corrID, nil
+}
+
+// handled is the set of request keys that the broker supports, mapped to
+// the minimum version supported.
+var handled = map[int16]int16{
+	0:  3, // Produce: record batches only
+	1:  4, // Fetch: record batches only
+	2:  1, // ListOffsets
+	3:  0, // Metadata
+	8:  0, // OffsetCommit
+	9:  0, // OffsetFetch
+	10: 0, // FindCoordinator
+	18: 0, // ApiVersions
+	22: 0, // InitProducerID
+}
+
+func (b *Broker) handle(req kmsg.Request) kmsg.Response {
+	switch req := req.(type) {
+	case *kmsg.ApiVersionsRequest:
+		return b.handleApiVersions()
+	case *kmsg.MetadataRequest:
+		return b.handleMetadata(req)
+	case *kmsg.ProduceRequest:
+		return b.handleProduce(req)
+	case *kmsg.FetchRequest:
+		return b.handleFetch(req)
+	case *kmsg.ListOffsetsRequest:
+		return b.handleListOffsets(req)
+	case *kmsg.InitProducerIDRequest:
+		return b.handleInitProducerID()
+	case *kmsg.FindCoordinatorRequest:
+		return b.handleFindCoordinator()
+	case *kmsg.OffsetCommitRequest:
+		return b.handleOffsetCommit(req)
+	case *kmsg.OffsetFetchRequest:
+		return b.handleOffsetFetch(req)
+	}
+	panic("unreachable") // parseRequest validated the key
+}
+
+// checkExpectsLocked compares produced records against any expected records
+// for the partition.
+func (b *Broker) checkExpectsLocked(topic string, partition int32, produced []kgo.Record) {
+	ps := b.expects[topic]
+	for i := range produced {
+		expects := ps[partition]
+		if len(expects) == 0 {
+			return
+		}
+		got, exp := &produced[i], expects[0]
+		ps[partition] = expects[1:]
+		if !bytes.Equal(got.Key, exp.Key) ||
+			!bytes.Equal(got.Value, exp.Value) ||
+			!headersEqual(got.Headers, exp.Headers) {
+			b.t.Errorf("kmock: unexpected record produced to %s[%d]: got key %q value %q, expected key %q value %q",
+				topic, partition, got.Key, got.Value, exp.Key, exp.Value)
+		}
+	}
+}
+
+func headersEqual(l, r []kgo.RecordHeader) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i].Key != r[i].Key || !bytes.Equal(l[i].Value, r[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// waitFor blocks until notify is closed, the broker is shutting down, or the
+// given wait elapses.
+func (b *Broker) waitFor(notify <-chan struct{}, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-notify:
+	case <-timer.C:
+	case <-b.die:
+	}
+}
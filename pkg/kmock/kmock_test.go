@@ -0,0 +1,110 @@
+package kmock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestProduceConsume(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(t)
+	b.ExpectProduce("foo", 0,
+		&kgo.Record{Key: []byte("k1"), Value: []byte("v1")},
+		&kgo.Record{Key: []byte("k2"), Value: []byte("v2")},
+	)
+	b.ExpectFetch("foo", 0, &kgo.Record{Value: []byte("v0")})
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(b.Addr()),
+		kgo.RecordPartitioner(kgo.ManualPartitioner()),
+		kgo.ConsumeTopics("foo"),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := cl.ProduceSync(ctx,
+		&kgo.Record{Topic: "foo", Key: []byte("k1"), Value: []byte("v1")},
+		&kgo.Record{Topic: "foo", Key: []byte("k2"), Value: []byte("v2")},
+	).FirstErr(); err != nil {
+		t.Fatalf("unable to produce: %v", err)
+	}
+
+	var values []string
+	for len(values) < 3 {
+		fs := cl.PollFetches(ctx)
+		if errs := fs.Errors(); len(errs) > 0 {
+			t.Fatalf("unable to consume: %v", errs)
+		}
+		fs.EachRecord(func(r *kgo.Record) {
+			if r.Offset != int64(len(values)) {
+				t.Errorf("got offset %d != exp %d", r.Offset, len(values))
+			}
+			values = append(values, string(r.Value))
+		})
+	}
+	for i, exp := range []string{"v0", "v1", "v2"} {
+		if values[i] != exp {
+			t.Errorf("value %d: got %q != exp %q", i, values[i], exp)
+		}
+	}
+}
+
+func TestOffsetCommitFetch(t *testing.T) {
+	t.Parallel()
+
+	b := NewBroker(t, SeedTopics(2, "foo"))
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(b.Addr()))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	commit := kmsg.NewPtrOffsetCommitRequest()
+	commit.Group = "g"
+	commit.Generation = -1
+	commitTopic := kmsg.NewOffsetCommitRequestTopic()
+	commitTopic.Topic = "foo"
+	commitPartition := kmsg.NewOffsetCommitRequestTopicPartition()
+	commitPartition.Partition = 1
+	commitPartition.Offset = 5
+	commitTopic.Partitions = append(commitTopic.Partitions, commitPartition)
+	commit.Topics = append(commit.Topics, commitTopic)
+	if _, err := commit.RequestWith(ctx, cl); err != nil {
+		t.Fatalf("unable to commit: %v", err)
+	}
+
+	fetch := kmsg.NewPtrOffsetFetchRequest()
+	fetch.Group = "g"
+	fetchTopic := kmsg.NewOffsetFetchRequestTopic()
+	fetchTopic.Topic = "foo"
+	fetchTopic.Partitions = []int32{0, 1}
+	fetch.Topics = append(fetch.Topics, fetchTopic)
+	resp, err := fetch.RequestWith(ctx, cl)
+	if err != nil {
+		t.Fatalf("unable to fetch offsets: %v", err)
+	}
+
+	got := make(map[int32]int64)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			got[p.Partition] = p.Offset
+		}
+	}
+	if got[0] != -1 || got[1] != 5 {
+		t.Errorf("got offsets %v, expected 0=>-1, 1=>5", got)
+	}
+}
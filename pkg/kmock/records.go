@@ -0,0 +1,253 @@
+package kmock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/twmb/franz-go/pkg/kbin"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// partition is an in memory log of raw record batches.
+type partition struct {
+	batches []batch
+	hwm     int64
+}
+
+type batch struct {
+	raw          []byte
+	firstOffset  int64
+	lastOffset   int64
+	maxTimestamp int64
+}
+
+// append adds a raw v2 record batch to the partition, rewriting its first
+// offset to the current high watermark. The first offset is not covered by
+// the batch CRC, so this does not invalidate the batch.
+func (p *partition) append(raw []byte) int64 {
+	base := p.hwm
+	binary.BigEndian.PutUint64(raw, uint64(base))
+	lastOffsetDelta := int64(binary.BigEndian.Uint32(raw[23:]))
+	maxTimestamp := int64(binary.BigEndian.Uint64(raw[35:]))
+	p.batches = append(p.batches, batch{
+		raw:          raw,
+		firstOffset:  base,
+		lastOffset:   base + lastOffsetDelta,
+		maxTimestamp: maxTimestamp,
+	})
+	p.hwm = base + lastOffsetDelta + 1
+	return base
+}
+
+// fetch returns all batches containing offsets at or after offset, stopping
+// once maxBytes is reached. At least one batch is always returned if any are
+// available, mirroring Kafka.
+func (p *partition) fetch(offset int64, maxBytes int32) []byte {
+	var dst []byte
+	for _, b := range p.batches {
+		if b.lastOffset < offset {
+			continue
+		}
+		if len(dst) > 0 && len(dst)+len(b.raw) > int(maxBytes) {
+			break
+		}
+		dst = append(dst, b.raw...)
+	}
+	return dst
+}
+
+// offsetForTimestamp returns the first offset of the first batch that
+// contains a record at or after the given timestamp, or the high watermark.
+func (p *partition) offsetForTimestamp(ts int64) int64 {
+	for _, b := range p.batches {
+		if b.maxTimestamp >= ts {
+			return b.firstOffset
+		}
+	}
+	return p.hwm
+}
+
+// newBatch encodes records into an uncompressed v2 record batch.
+func newBatch(firstOffset int64, records []*kgo.Record) []byte {
+	now := time.Now()
+	ts := func(r *kgo.Record) int64 {
+		if r.Timestamp.IsZero() {
+			return now.UnixNano() / 1e6
+		}
+		return r.Timestamp.UnixNano() / 1e6
+	}
+
+	firstTimestamp := ts(records[0])
+	maxTimestamp := firstTimestamp
+
+	var raw []byte
+	for i, r := range records {
+		rts := ts(r)
+		if rts > maxTimestamp {
+			maxTimestamp = rts
+		}
+		rec := kmsg.Record{
+			TimestampDelta: int32(rts - firstTimestamp),
+			OffsetDelta:    int32(i),
+			Key:            r.Key,
+			Value:          r.Value,
+		}
+		for _, h := range r.Headers {
+			rec.Headers = append(rec.Headers, kmsg.Header{Key: h.Key, Value: h.Value})
+		}
+		// A zero length encodes as a single byte varint, so everything
+		// after the first byte is what the length must cover.
+		rec.Length = int32(len(rec.AppendTo(nil)) - 1)
+		raw = rec.AppendTo(raw)
+	}
+
+	rb := kmsg.RecordBatch{
+		FirstOffset:          firstOffset,
+		Length:               int32(49 + len(raw)),
+		PartitionLeaderEpoch: 0,
+		Magic:                2,
+		LastOffsetDelta:      int32(len(records) - 1),
+		FirstTimestamp:       firstTimestamp,
+		MaxTimestamp:         maxTimestamp,
+		ProducerID:           -1,
+		ProducerEpoch:        -1,
+		FirstSequence:        -1,
+		NumRecords:           int32(len(records)),
+		Records:              raw,
+	}
+	enc := rb.AppendTo(nil)
+	binary.BigEndian.PutUint32(enc[17:], crc32.Checksum(enc[21:], crc32c))
+	return enc
+}
+
+var errMalformedBatch = errors.New("malformed record batch")
+
+// splitBatches splits concatenated raw record batches, validating each
+// batch's magic and CRC. Each returned batch is a copy.
+func splitBatches(src []byte) ([][]byte, error) {
+	var batches [][]byte
+	for len(src) > 0 {
+		if len(src) < 61 {
+			return nil, errMalformedBatch
+		}
+		length := int(int32(binary.BigEndian.Uint32(src[8:])))
+		if length < 49 || len(src) < 12+length {
+			return nil, errMalformedBatch
+		}
+		raw := append([]byte(nil), src[:12+length]...)
+		src = src[12+length:]
+		if raw[16] != 2 {
+			return nil, errMalformedBatch
+		}
+		if binary.BigEndian.Uint32(raw[17:]) != crc32.Checksum(raw[21:], crc32c) {
+			return nil, errMalformedBatch
+		}
+		batches = append(batches, raw)
+	}
+	return batches, nil
+}
+
+// decodeBatch decodes the key, value, and headers of all records in a raw
+// record batch.
+func decodeBatch(raw []byte) ([]kgo.Record, error) {
+	var rb kmsg.RecordBatch
+	if err := rb.ReadFrom(raw); err != nil {
+		return nil, err
+	}
+	src, err := decompress(rb.Records, byte(rb.Attributes&0x07))
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make([]kgo.Record, 0, rb.NumRecords)
+	for i := int32(0); i < rb.NumRecords; i++ {
+		start := src
+		b := kbin.Reader{Src: src}
+		length := int(b.Varint())
+		b.Span(length)
+		if err := b.Complete(); err != nil {
+			return nil, err
+		}
+		src = b.Src
+
+		var rec kmsg.Record
+		if err := rec.ReadFrom(start[:len(start)-len(src)]); err != nil {
+			return nil, err
+		}
+		r := kgo.Record{
+			Key:   rec.Key,
+			Value: rec.Value,
+		}
+		for _, h := range rec.Headers {
+			r.Headers = append(r.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+var xerialPfx = []byte{130, 83, 78, 65, 80, 80, 89, 0}
+
+func decompress(src []byte, codec byte) ([]byte, error) {
+	switch codec {
+	case 0:
+		return src, nil
+	case 1:
+		ungz, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(ungz)
+	case 2:
+		if len(src) > 16 && bytes.HasPrefix(src, xerialPfx) {
+			return xerialDecode(src[16:])
+		}
+		return snappy.Decode(nil, src)
+	case 3:
+		return ioutil.ReadAll(lz4.NewReader(bytes.NewReader(src)))
+	case 4:
+		unzstd, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer unzstd.Close()
+		return unzstd.DecodeAll(src, nil)
+	default:
+		return nil, errors.New("unknown compression codec")
+	}
+}
+
+// xerialDecode decodes xerial framed snappy chunks: a uint32 chunk size
+// followed by a snappy chunk, repeated.
+func xerialDecode(src []byte) ([]byte, error) {
+	var dst []byte
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errMalformedBatch
+		}
+		size := int32(binary.BigEndian.Uint32(src))
+		src = src[4:]
+		if size < 0 || len(src) < int(size) {
+			return nil, errMalformedBatch
+		}
+		chunk, err := snappy.Decode(nil, src[:size])
+		if err != nil {
+			return nil, err
+		}
+		src = src[size:]
+		dst = append(dst, chunk...)
+	}
+	return dst, nil
+}
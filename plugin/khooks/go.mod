@@ -0,0 +1,5 @@
+module github.com/twmb/franz-go/plugin/khooks
+
+go 1.16
+
+require github.com/twmb/franz-go v0.9.0
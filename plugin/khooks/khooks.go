@@ -0,0 +1,281 @@
+// Package khooks provides a Middleware that fans hook calls out to many
+// hooks, allowing hooks to be composed and passed around as one.
+//
+// A client already calls every hook passed to kgo.WithHooks. Middleware is
+// useful when a single hook value is needed, such as when a library accepts
+// one kgo.Hook, or to add or remove a group of hooks at once with
+// kgo.Client.AddHook and kgo.Client.RemoveHook:
+//
+//     m := khooks.NewMiddleware(promMetrics, statsdMetrics)
+//     cl, err := kgo.NewClient(
+//             kgo.WithHooks(m),
+//             // ...other opts
+//     )
+//
+// Middleware implements every hook interface in kgo. Each call is passed, in
+// order, to every member that implements the corresponding interface; members
+// that do not implement it are skipped, exactly as if they were passed to
+// kgo.WithHooks directly.
+package khooks
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.HookBrokerConnect           = new(Middleware)
+	_ kgo.HookBrokerDisconnect        = new(Middleware)
+	_ kgo.HookBrokerWrite             = new(Middleware)
+	_ kgo.HookBrokerRead              = new(Middleware)
+	_ kgo.HookBrokerWriteContext      = new(Middleware)
+	_ kgo.HookBrokerReadContext       = new(Middleware)
+	_ kgo.HookBrokerE2E               = new(Middleware)
+	_ kgo.HookBrokerThrottle          = new(Middleware)
+	_ kgo.HookBrokerThrottleKey       = new(Middleware)
+	_ kgo.HookBrokerSendQueued        = new(Middleware)
+	_ kgo.HookBrokerSASL              = new(Middleware)
+	_ kgo.HookGroupManageError        = new(Middleware)
+	_ kgo.HookGroupOffsetCommit       = new(Middleware)
+	_ kgo.HookGroupRebalance          = new(Middleware)
+	_ kgo.HookMetadataRefresh         = new(Middleware)
+	_ kgo.HookProduceBatchWritten     = new(Middleware)
+	_ kgo.HookProduceRecordBuffered   = new(Middleware)
+	_ kgo.HookProduceRecordUnbuffered = new(Middleware)
+	_ kgo.HookProducePartitionError   = new(Middleware)
+	_ kgo.HookTransactionEnd          = new(Middleware)
+	_ kgo.HookFetchBatchRead          = new(Middleware)
+	_ kgo.HookFetchPartitionError     = new(Middleware)
+	_ kgo.HookFetchSession            = new(Middleware)
+	_ kgo.HookFetchRecordBuffered     = new(Middleware)
+	_ kgo.HookFetchRecordUnbuffered   = new(Middleware)
+	_ kgo.HookClientClosed            = new(Middleware)
+)
+
+// Middleware fans hook calls out to many hooks.
+type Middleware struct {
+	hooks []kgo.Hook
+}
+
+// NewMiddleware returns a Middleware that calls the given hooks in order.
+// Hooks may themselves be Middlewares.
+func NewMiddleware(hooks ...kgo.Hook) *Middleware {
+	return &Middleware{hooks: append([]kgo.Hook(nil), hooks...)}
+}
+
+// Hooks returns the hooks this Middleware calls.
+func (m *Middleware) Hooks() []kgo.Hook {
+	return append([]kgo.Hook(nil), m.hooks...)
+}
+
+func (m *Middleware) OnBrokerConnect(meta kgo.BrokerMetadata, dialDur time.Duration, conn net.Conn, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerConnect); ok {
+			h.OnBrokerConnect(meta, dialDur, conn, err)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerDisconnect(meta kgo.BrokerMetadata, conn net.Conn) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerDisconnect); ok {
+			h.OnBrokerDisconnect(meta, conn)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerWrite(meta kgo.BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerWrite); ok {
+			h.OnBrokerWrite(meta, key, bytesWritten, writeWait, timeToWrite, err)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerRead(meta kgo.BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerRead); ok {
+			h.OnBrokerRead(meta, key, bytesRead, readWait, timeToRead, err)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerWriteContext(ctx context.Context, meta kgo.BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerWriteContext); ok {
+			h.OnBrokerWriteContext(ctx, meta, key, bytesWritten, writeWait, timeToWrite, err)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerReadContext(ctx context.Context, meta kgo.BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerReadContext); ok {
+			h.OnBrokerReadContext(ctx, meta, key, bytesRead, readWait, timeToRead, err)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerE2E(meta kgo.BrokerMetadata, key int16, e2e kgo.BrokerE2E) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerE2E); ok {
+			h.OnBrokerE2E(meta, key, e2e)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerThrottle(meta kgo.BrokerMetadata, throttleInterval time.Duration, throttledAfterResponse bool) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerThrottle); ok {
+			h.OnBrokerThrottle(meta, throttleInterval, throttledAfterResponse)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerThrottleKey(meta kgo.BrokerMetadata, key int16, throttleInterval time.Duration, throttledAfterResponse bool) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerThrottleKey); ok {
+			h.OnBrokerThrottleKey(meta, key, throttleInterval, throttledAfterResponse)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerSendQueued(meta kgo.BrokerMetadata, queueDepth int, queuedAt time.Time) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerSendQueued); ok {
+			h.OnBrokerSendQueued(meta, queueDepth, queuedAt)
+		}
+	}
+}
+
+func (m *Middleware) OnBrokerSASL(meta kgo.BrokerMetadata, mechanism string, authDur time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookBrokerSASL); ok {
+			h.OnBrokerSASL(meta, mechanism, authDur, err)
+		}
+	}
+}
+
+func (m *Middleware) OnGroupManageError(err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookGroupManageError); ok {
+			h.OnGroupManageError(err)
+		}
+	}
+}
+
+func (m *Middleware) OnGroupOffsetCommit(group string, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, commitDur time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookGroupOffsetCommit); ok {
+			h.OnGroupOffsetCommit(group, req, resp, commitDur, err)
+		}
+	}
+}
+
+func (m *Middleware) OnGroupRebalance(group, reason string, rebalanceDur time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookGroupRebalance); ok {
+			h.OnGroupRebalance(group, reason, rebalanceDur, err)
+		}
+	}
+}
+
+func (m *Middleware) OnMetadataRefresh(trigger string, refreshDur time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookMetadataRefresh); ok {
+			h.OnMetadataRefresh(trigger, refreshDur, err)
+		}
+	}
+}
+
+func (m *Middleware) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, partition int32, metrics kgo.ProduceBatchMetrics) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookProduceBatchWritten); ok {
+			h.OnProduceBatchWritten(meta, topic, partition, metrics)
+		}
+	}
+}
+
+func (m *Middleware) OnProduceRecordBuffered(r *kgo.Record) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookProduceRecordBuffered); ok {
+			h.OnProduceRecordBuffered(r)
+		}
+	}
+}
+
+func (m *Middleware) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookProduceRecordUnbuffered); ok {
+			h.OnProduceRecordUnbuffered(r, err)
+		}
+	}
+}
+
+func (m *Middleware) OnProducePartitionError(meta kgo.BrokerMetadata, topic string, partition int32, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookProducePartitionError); ok {
+			h.OnProducePartitionError(meta, topic, partition, err)
+		}
+	}
+}
+
+func (m *Middleware) OnTransactionEnd(commit bool, txnDur time.Duration, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookTransactionEnd); ok {
+			h.OnTransactionEnd(commit, txnDur, err)
+		}
+	}
+}
+
+func (m *Middleware) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, partition int32, metrics kgo.FetchBatchMetrics) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookFetchBatchRead); ok {
+			h.OnFetchBatchRead(meta, topic, partition, metrics)
+		}
+	}
+}
+
+func (m *Middleware) OnFetchPartitionError(meta kgo.BrokerMetadata, topic string, partition int32, err error) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookFetchPartitionError); ok {
+			h.OnFetchPartitionError(meta, topic, partition, err)
+		}
+	}
+}
+
+func (m *Middleware) OnFetchSession(meta kgo.BrokerMetadata, sessionID, epoch int32, reset bool) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookFetchSession); ok {
+			h.OnFetchSession(meta, sessionID, epoch, reset)
+		}
+	}
+}
+
+func (m *Middleware) OnFetchRecordBuffered(r *kgo.Record) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookFetchRecordBuffered); ok {
+			h.OnFetchRecordBuffered(r)
+		}
+	}
+}
+
+func (m *Middleware) OnFetchRecordUnbuffered(r *kgo.Record) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookFetchRecordUnbuffered); ok {
+			h.OnFetchRecordUnbuffered(r)
+		}
+	}
+}
+
+func (m *Middleware) OnClientClosed(cl *kgo.Client) {
+	for _, h := range m.hooks {
+		if h, ok := h.(kgo.HookClientClosed); ok {
+			h.OnClientClosed(cl)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/twmb/franz-go/plugin/kinflux
+
+go 1.17
+
+require (
+	github.com/influxdata/influxdb-client-go/v2 v2.12.3
+	github.com/twmb/franz-go v0.9.0
+)
+
+require (
+	github.com/deepmap/oapi-codegen v1.8.2 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/influxdata/line-protocol v0.0.0-20200327222509-2487e7298839 // indirect
+	github.com/klauspost/compress v1.13.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.7 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/twmb/go-rbtree v1.0.0 // indirect
+	golang.org/x/net v0.7.0 // indirect
+)
@@ -0,0 +1,376 @@
+// Package kinflux provides InfluxDB metrics for a kgo client, written in the
+// line protocol over HTTP through influxdb-client-go, or over UDP.
+//
+// Counters are kept in memory and every flush interval, their cumulative
+// values are written as one point per broker and one point per broker and
+// topic. Broker points are written to the "kafka_broker" measurement, tagged
+// with node_id, with the following integer fields:
+//
+//     connects
+//     connect_errors
+//     disconnects
+//     write_errors
+//     write_bytes
+//     read_errors
+//     read_bytes
+//
+// Topic points are written to the "kafka_topic" measurement, tagged with
+// node_id and topic, with the following integer fields:
+//
+//     produce_bytes
+//     produce_records
+//     fetch_bytes
+//     fetch_records
+//
+// Produce and fetch bytes are uncompressed bytes. Measurement names can be
+// changed with the BrokerMeasurement and TopicMeasurement options.
+//
+// This can be used in a client like so:
+//
+//     m := kinflux.NewMetrics("http://localhost:8086", "kafka")
+//     defer m.Close()
+//     cl, err := kgo.NewClient(
+//             kgo.WithHooks(m),
+//             // ...other opts
+//     )
+//
+// To write to a UDP listener, such as InfluxDB 1.x's UDP service or
+// Telegraf's socket_listener, use a udp:// url:
+//
+//     m := kinflux.NewMetrics("udp://localhost:8089", "")
+//
+// Note that seed brokers use broker IDs starting at math.MinInt32.
+package kinflux
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.HookBrokerConnect       = new(Metrics)
+	_ kgo.HookBrokerDisconnect    = new(Metrics)
+	_ kgo.HookBrokerWrite         = new(Metrics)
+	_ kgo.HookBrokerRead          = new(Metrics)
+	_ kgo.HookProduceBatchWritten = new(Metrics)
+	_ kgo.HookFetchBatchRead      = new(Metrics)
+)
+
+// Metrics provides InfluxDB metrics.
+type Metrics struct {
+	writer pointWriter
+	close  func()
+
+	token             string
+	org               string
+	flushInterval     time.Duration
+	brokerMeasurement string
+	topicMeasurement  string
+	onErr             func(error)
+
+	brokers sync.Map
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+type broker struct {
+	node string
+
+	connects    int64
+	connectErrs int64
+	disconnects int64
+
+	writeErrs  int64
+	writeBytes int64
+
+	readErrs  int64
+	readBytes int64
+
+	topics sync.Map
+}
+
+type brokerTopic struct {
+	produceBytes   int64
+	produceRecords int64
+	fetchBytes     int64
+	fetchRecords   int64
+}
+
+// Opt applies options to further tune how metrics are written.
+type Opt interface {
+	apply(*Metrics)
+}
+
+type opt struct{ fn func(*Metrics) }
+
+func (o opt) apply(m *Metrics) { o.fn(m) }
+
+// AuthToken sets the token to authenticate with. For InfluxDB 1.8+, this can
+// be "username:password".
+func AuthToken(token string) Opt {
+	return opt{func(m *Metrics) { m.token = token }}
+}
+
+// Org sets the organization to write to, which is required for InfluxDB 2+
+// and ignored by InfluxDB 1.8.
+func Org(org string) Opt {
+	return opt{func(m *Metrics) { m.org = org }}
+}
+
+// FlushInterval sets how often metrics are written, overriding the default
+// 10s. Non-positive intervals are ignored.
+func FlushInterval(interval time.Duration) Opt {
+	return opt{func(m *Metrics) { m.flushInterval = interval }}
+}
+
+// BrokerMeasurement sets the measurement broker points are written to,
+// overriding the default "kafka_broker".
+func BrokerMeasurement(measurement string) Opt {
+	return opt{func(m *Metrics) { m.brokerMeasurement = measurement }}
+}
+
+// TopicMeasurement sets the measurement topic points are written to,
+// overriding the default "kafka_topic".
+func TopicMeasurement(measurement string) Opt {
+	return opt{func(m *Metrics) { m.topicMeasurement = measurement }}
+}
+
+// OnWriteError sets a function to call with any error writing metrics. By
+// default, write errors are dropped.
+func OnWriteError(fn func(error)) Opt {
+	return opt{func(m *Metrics) { m.onErr = fn }}
+}
+
+// NewMetrics returns a new Metrics that writes to the InfluxDB server at url
+// in the given database (or for InfluxDB 2+, bucket). This starts a goroutine
+// that writes metrics every flush interval until Close is called.
+//
+// If url begins with udp://, points are instead written as line protocol
+// datagrams to the remainder of the url. The database for UDP writes is
+// configured in the listener, so db, AuthToken, and Org are ignored. Lines are
+// packed into datagrams of at most 1400 bytes; any error dialing or writing is
+// passed to OnWriteError and the next flush tries again.
+func NewMetrics(url, db string, opts ...Opt) *Metrics {
+	m := &Metrics{
+		flushInterval:     10 * time.Second,
+		brokerMeasurement: "kafka_broker",
+		topicMeasurement:  "kafka_topic",
+		onErr:             func(error) {},
+
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(m)
+	}
+	if m.flushInterval <= 0 {
+		m.flushInterval = 10 * time.Second
+	}
+	if addr := strings.TrimPrefix(url, "udp://"); addr != url {
+		w := &udpWriter{addr: addr}
+		m.writer, m.close = w, w.close
+	} else {
+		client := influxdb2.NewClient(url, m.token)
+		m.writer, m.close = client.WriteAPIBlocking(m.org, db), client.Close
+	}
+	go m.loop()
+	return m
+}
+
+// Close stops the flush goroutine, writes metrics one final time, and closes
+// the InfluxDB client or UDP connection.
+func (m *Metrics) Close() {
+	close(m.quit)
+	<-m.done
+	m.close()
+}
+
+func (m *Metrics) loop() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.quit:
+			m.flush()
+			return
+		case <-ticker.C:
+			m.flush()
+		}
+	}
+}
+
+func (m *Metrics) flush() {
+	now := time.Now()
+	var points []*write.Point
+	m.brokers.Range(func(_, v interface{}) bool {
+		b := v.(*broker)
+		points = append(points, influxdb2.NewPoint(
+			m.brokerMeasurement,
+			map[string]string{"node_id": b.node},
+			map[string]interface{}{
+				"connects":       atomic.LoadInt64(&b.connects),
+				"connect_errors": atomic.LoadInt64(&b.connectErrs),
+				"disconnects":    atomic.LoadInt64(&b.disconnects),
+				"write_errors":   atomic.LoadInt64(&b.writeErrs),
+				"write_bytes":    atomic.LoadInt64(&b.writeBytes),
+				"read_errors":    atomic.LoadInt64(&b.readErrs),
+				"read_bytes":     atomic.LoadInt64(&b.readBytes),
+			},
+			now,
+		))
+		b.topics.Range(func(k, v interface{}) bool {
+			t := v.(*brokerTopic)
+			points = append(points, influxdb2.NewPoint(
+				m.topicMeasurement,
+				map[string]string{"node_id": b.node, "topic": k.(string)},
+				map[string]interface{}{
+					"produce_bytes":   atomic.LoadInt64(&t.produceBytes),
+					"produce_records": atomic.LoadInt64(&t.produceRecords),
+					"fetch_bytes":     atomic.LoadInt64(&t.fetchBytes),
+					"fetch_records":   atomic.LoadInt64(&t.fetchRecords),
+				},
+				now,
+			))
+			return true
+		})
+		return true
+	})
+	if len(points) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.flushInterval)
+	defer cancel()
+	if err := m.writer.WritePoint(ctx, points...); err != nil {
+		m.onErr(err)
+	}
+}
+
+// pointWriter is satisfied by influxdb-client-go's api.WriteAPIBlocking and
+// by udpWriter.
+type pointWriter interface {
+	WritePoint(ctx context.Context, points ...*write.Point) error
+}
+
+// udpPayloadSize keeps datagrams under a typical 1500 byte ethernet MTU.
+const udpPayloadSize = 1400
+
+// udpWriter writes points as line protocol datagrams. The connection is dialed
+// on first write and redialed after any write error. Writes only happen in
+// the flush goroutine, so no locking is necessary.
+type udpWriter struct {
+	addr string
+	conn net.Conn
+}
+
+func (w *udpWriter) WritePoint(ctx context.Context, points ...*write.Point) error {
+	if w.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", w.addr)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	var buf []byte
+	for _, p := range points {
+		line := write.PointToLineProtocol(p, time.Nanosecond)
+		if len(buf) > 0 && len(buf)+len(line) > udpPayloadSize {
+			if err := w.send(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+		buf = append(buf, line...)
+	}
+	if len(buf) > 0 {
+		return w.send(buf)
+	}
+	return nil
+}
+
+func (w *udpWriter) send(datagram []byte) error {
+	if _, err := w.conn.Write(datagram); err != nil {
+		w.close()
+		return err
+	}
+	return nil
+}
+
+func (w *udpWriter) close() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+func (m *Metrics) loadBroker(id int32) *broker {
+	bi, ok := m.brokers.Load(id)
+	if !ok {
+		bi, _ = m.brokers.LoadOrStore(id, &broker{node: strconv.Itoa(int(id))})
+	}
+	return bi.(*broker)
+}
+
+func (b *broker) loadTopic(topic string) *brokerTopic {
+	ti, ok := b.topics.Load(topic)
+	if !ok {
+		ti, _ = b.topics.LoadOrStore(topic, new(brokerTopic))
+	}
+	return ti.(*brokerTopic)
+}
+
+func (m *Metrics) OnBrokerConnect(meta kgo.BrokerMetadata, _ time.Duration, _ net.Conn, err error) {
+	b := m.loadBroker(meta.NodeID)
+	if err != nil {
+		atomic.AddInt64(&b.connectErrs, 1)
+		return
+	}
+	atomic.AddInt64(&b.connects, 1)
+}
+
+func (m *Metrics) OnBrokerDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
+	b := m.loadBroker(meta.NodeID)
+	atomic.AddInt64(&b.disconnects, 1)
+}
+
+func (m *Metrics) OnBrokerWrite(meta kgo.BrokerMetadata, _ int16, bytesWritten int, _, _ time.Duration, err error) {
+	b := m.loadBroker(meta.NodeID)
+	if err != nil {
+		atomic.AddInt64(&b.writeErrs, 1)
+		return
+	}
+	atomic.AddInt64(&b.writeBytes, int64(bytesWritten))
+}
+
+func (m *Metrics) OnBrokerRead(meta kgo.BrokerMetadata, _ int16, bytesRead int, _, _ time.Duration, err error) {
+	b := m.loadBroker(meta.NodeID)
+	if err != nil {
+		atomic.AddInt64(&b.readErrs, 1)
+		return
+	}
+	atomic.AddInt64(&b.readBytes, int64(bytesRead))
+}
+
+func (m *Metrics) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, _ int32, pbm kgo.ProduceBatchMetrics) {
+	t := m.loadBroker(meta.NodeID).loadTopic(topic)
+	atomic.AddInt64(&t.produceBytes, int64(pbm.UncompressedBytes))
+	atomic.AddInt64(&t.produceRecords, int64(pbm.NumRecords))
+}
+
+func (m *Metrics) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, _ int32, fbm kgo.FetchBatchMetrics) {
+	t := m.loadBroker(meta.NodeID).loadTopic(topic)
+	atomic.AddInt64(&t.fetchBytes, int64(fbm.UncompressedBytes))
+	atomic.AddInt64(&t.fetchRecords, int64(fbm.NumRecords))
+}
@@ -0,0 +1,19 @@
+module github.com/twmb/franz-go/plugin/kotel
+
+go 1.19
+
+require (
+	github.com/twmb/franz-go v0.9.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/metric v1.16.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/klauspost/compress v1.13.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.7 // indirect
+	github.com/twmb/go-rbtree v1.0.0 // indirect
+	go.opentelemetry.io/otel/trace v1.16.0 // indirect
+)
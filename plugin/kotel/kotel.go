@@ -0,0 +1,234 @@
+// Package kotel provides OpenTelemetry metrics for a kgo client.
+//
+// This package tracks the following instruments, following the OpenTelemetry
+// semantic conventions for messaging systems where they apply. Every
+// measurement has the messaging.system="kafka" attribute; broker measurements
+// additionally have node_id, and topic measurements additionally have
+// messaging.destination.name.
+//
+// Counters:
+//
+//     messaging.kafka.connects.count{node_id}
+//     messaging.kafka.connect_errors.count{node_id}
+//     messaging.kafka.disconnects.count{node_id}
+//     messaging.kafka.write_errors.count{node_id}
+//     messaging.kafka.write_bytes{node_id}
+//     messaging.kafka.read_errors.count{node_id}
+//     messaging.kafka.read_bytes{node_id}
+//     messaging.kafka.produce_bytes.count{node_id,messaging.destination.name}
+//     messaging.kafka.fetch_bytes.count{node_id,messaging.destination.name}
+//     messaging.client.published.messages{node_id,messaging.destination.name}
+//     messaging.client.consumed.messages{node_id,messaging.destination.name}
+//
+// Histograms, in seconds:
+//
+//     messaging.kafka.connect.duration{node_id}
+//     messaging.kafka.write.duration{node_id}
+//     messaging.kafka.read.duration{node_id}
+//
+// Produce and fetch bytes are uncompressed bytes.
+//
+// This can be used in a client like so:
+//
+//     m := kotel.NewMetrics()
+//     cl, err := kgo.NewClient(
+//             kgo.WithHooks(m),
+//             // ...other opts
+//     )
+//
+// By default, instruments are created from the global meter provider, but
+// this can be overridden with the MeterProvider option.
+//
+// Note that seed brokers use broker IDs starting at math.MinInt32; seed
+// brokers are labeled as node_id="seed_#{n}", where n is the index of the
+// seed broker.
+package kotel
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const instrumentationName = "github.com/twmb/franz-go/plugin/kotel"
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.HookBrokerConnect       = new(Metrics)
+	_ kgo.HookBrokerDisconnect    = new(Metrics)
+	_ kgo.HookBrokerWrite         = new(Metrics)
+	_ kgo.HookBrokerRead          = new(Metrics)
+	_ kgo.HookProduceBatchWritten = new(Metrics)
+	_ kgo.HookFetchBatchRead      = new(Metrics)
+)
+
+var systemAttr = attribute.String("messaging.system", "kafka")
+
+// Metrics provides OpenTelemetry metrics.
+type Metrics struct {
+	provider metric.MeterProvider
+
+	connects    metric.Int64Counter
+	connectErrs metric.Int64Counter
+	disconnects metric.Int64Counter
+
+	connectDuration metric.Float64Histogram
+
+	writeErrs     metric.Int64Counter
+	writeBytes    metric.Int64Counter
+	writeDuration metric.Float64Histogram
+
+	readErrs     metric.Int64Counter
+	readBytes    metric.Int64Counter
+	readDuration metric.Float64Histogram
+
+	produceBytes   metric.Int64Counter
+	produceRecords metric.Int64Counter
+	fetchBytes     metric.Int64Counter
+	fetchRecords   metric.Int64Counter
+}
+
+// Opt applies options to further tune how metrics are gathered.
+type Opt interface {
+	apply(*Metrics)
+}
+
+type opt struct{ fn func(*Metrics) }
+
+func (o opt) apply(m *Metrics) { o.fn(m) }
+
+// MeterProvider sets the meter provider to create instruments with, rather
+// than the global meter provider.
+func MeterProvider(provider metric.MeterProvider) Opt {
+	return opt{func(m *Metrics) { m.provider = provider }}
+}
+
+// NewMetrics returns a new Metrics.
+//
+// Errors creating instruments are passed to otel.Handle.
+func NewMetrics(opts ...Opt) *Metrics {
+	m := &Metrics{
+		provider: otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt.apply(m)
+	}
+
+	meter := m.provider.Meter(instrumentationName)
+	counter := func(name, unit, desc string) metric.Int64Counter {
+		c, err := meter.Int64Counter(name, metric.WithUnit(unit), metric.WithDescription(desc))
+		if err != nil {
+			otel.Handle(err)
+		}
+		return c
+	}
+	histogram := func(name, desc string) metric.Float64Histogram {
+		h, err := meter.Float64Histogram(name, metric.WithUnit("s"), metric.WithDescription(desc))
+		if err != nil {
+			otel.Handle(err)
+		}
+		return h
+	}
+
+	// connections
+
+	m.connects = counter("messaging.kafka.connects.count", "{connection}", "Total number of connections opened")
+	m.connectErrs = counter("messaging.kafka.connect_errors.count", "{error}", "Total number of connection errors")
+	m.disconnects = counter("messaging.kafka.disconnects.count", "{connection}", "Total number of connections closed")
+	m.connectDuration = histogram("messaging.kafka.connect.duration", "Time spent dialing successful connections")
+
+	// writes & reads
+
+	m.writeErrs = counter("messaging.kafka.write_errors.count", "{error}", "Total number of write errors")
+	m.writeBytes = counter("messaging.kafka.write_bytes", "By", "Total number of bytes written")
+	m.writeDuration = histogram("messaging.kafka.write.duration", "Time spent writing requests")
+	m.readErrs = counter("messaging.kafka.read_errors.count", "{error}", "Total number of read errors")
+	m.readBytes = counter("messaging.kafka.read_bytes", "By", "Total number of bytes read")
+	m.readDuration = histogram("messaging.kafka.read.duration", "Time spent reading responses")
+
+	// produce & fetch
+
+	m.produceBytes = counter("messaging.kafka.produce_bytes.count", "By", "Total number of uncompressed bytes produced")
+	m.produceRecords = counter("messaging.client.published.messages", "{message}", "Total number of records produced")
+	m.fetchBytes = counter("messaging.kafka.fetch_bytes.count", "By", "Total number of uncompressed bytes fetched")
+	m.fetchRecords = counter("messaging.client.consumed.messages", "{message}", "Total number of records fetched")
+
+	return m
+}
+
+func (m *Metrics) OnBrokerConnect(meta kgo.BrokerMetadata, dialDur time.Duration, _ net.Conn, err error) {
+	attrs := brokerAttrs(meta)
+	if err != nil {
+		m.connectErrs.Add(context.Background(), 1, attrs)
+		return
+	}
+	m.connects.Add(context.Background(), 1, attrs)
+	m.connectDuration.Record(context.Background(), dialDur.Seconds(), attrs)
+}
+
+func (m *Metrics) OnBrokerDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
+	m.disconnects.Add(context.Background(), 1, brokerAttrs(meta))
+}
+
+func (m *Metrics) OnBrokerWrite(meta kgo.BrokerMetadata, _ int16, bytesWritten int, _, timeToWrite time.Duration, err error) {
+	attrs := brokerAttrs(meta)
+	if err != nil {
+		m.writeErrs.Add(context.Background(), 1, attrs)
+		return
+	}
+	m.writeBytes.Add(context.Background(), int64(bytesWritten), attrs)
+	m.writeDuration.Record(context.Background(), timeToWrite.Seconds(), attrs)
+}
+
+func (m *Metrics) OnBrokerRead(meta kgo.BrokerMetadata, _ int16, bytesRead int, _, timeToRead time.Duration, err error) {
+	attrs := brokerAttrs(meta)
+	if err != nil {
+		m.readErrs.Add(context.Background(), 1, attrs)
+		return
+	}
+	m.readBytes.Add(context.Background(), int64(bytesRead), attrs)
+	m.readDuration.Record(context.Background(), timeToRead.Seconds(), attrs)
+}
+
+func (m *Metrics) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, _ int32, pbm kgo.ProduceBatchMetrics) {
+	attrs := topicAttrs(meta, topic)
+	m.produceBytes.Add(context.Background(), int64(pbm.UncompressedBytes), attrs)
+	m.produceRecords.Add(context.Background(), int64(pbm.NumRecords), attrs)
+}
+
+func (m *Metrics) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, _ int32, fbm kgo.FetchBatchMetrics) {
+	attrs := topicAttrs(meta, topic)
+	m.fetchBytes.Add(context.Background(), int64(fbm.UncompressedBytes), attrs)
+	m.fetchRecords.Add(context.Background(), int64(fbm.NumRecords), attrs)
+}
+
+func brokerAttrs(meta kgo.BrokerMetadata) metric.MeasurementOption {
+	return metric.WithAttributes(
+		systemAttr,
+		attribute.String("node_id", nodeID(meta.NodeID)),
+	)
+}
+
+func topicAttrs(meta kgo.BrokerMetadata, topic string) metric.MeasurementOption {
+	return metric.WithAttributes(
+		systemAttr,
+		attribute.String("node_id", nodeID(meta.NodeID)),
+		attribute.String("messaging.destination.name", topic),
+	)
+}
+
+// nodeID returns the node_id attribute value for a broker, which is the
+// broker's ID, or seed_#{n} for seed brokers.
+func nodeID(id int32) string {
+	if id < 0 {
+		return "seed_" + strconv.Itoa(int(id-math.MinInt32))
+	}
+	return strconv.Itoa(int(id))
+}
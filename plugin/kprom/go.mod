@@ -3,6 +3,7 @@ module github.com/twmb/franz-go/plugin/kprom
 go 1.16
 
 require (
-	github.com/prometheus/client_golang v1.11.0
-	github.com/twmb/franz-go v0.8.2
+	github.com/prometheus/client_golang v1.14.0
+	github.com/prometheus/client_model v0.3.0
+	github.com/twmb/franz-go v0.9.0
 )
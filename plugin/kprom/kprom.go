@@ -4,13 +4,180 @@
 // all metrics being counter vecs:
 //
 //     #{ns}_connects_total{node_id="#{node}"}
-//     #{ns}_connect_errors_total{node_id="#{node}"}
-//     #{ns}_write_errors_total{node_id="#{node}"}
+//     #{ns}_connect_errors_total{node_id="#{node}",error_type="#{type}"}
+//     #{ns}_write_errors_total{node_id="#{node}",request_type="#{type}"}
 //     #{ns}_write_bytes_total{node_id="#{node}"}
-//     #{ns}_read_errors_total{node_id="#{node}"}
+//     #{ns}_read_errors_total{node_id="#{node}",request_type="#{type}"}
 //     #{ns}_read_bytes_total{node_id="#{node}"}
 //     #{ns}_produce_bytes_total{node_id="#{node}",topic="#{topic}"}
 //     #{ns}_fetch_bytes_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_compressed_bytes_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_fetch_compressed_bytes_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_records_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_fetch_records_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_retries_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_errors_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_fetch_errors_total{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_drops_total{topic="#{topic}"}
+//
+// The error_type label classifies why a connection failed; see ErrorClassifier.
+// The request_type label is the name of the request's API key (e.g.,
+// "Produce" or "Metadata"), or the key as a number if the key is unknown.
+//
+// The produce drops counter is only incremented if OnRecordDropped is passed
+// to the client with kgo.OnRecordDropped. If the WithPartitionLabel or
+// WithCompressionLabel options are used, the produce and fetch bytes counters
+// (both uncompressed and compressed) also have a partition or compression
+// label.
+//
+// Cumulative time brokers throttled the client due to quotas is tracked as a
+// counter vec:
+//
+//     #{ns}_throttle_duration_seconds_total{node_id="#{node}",request_type="#{type}"}
+//
+// The number of currently open connections to each broker is tracked as a
+// gauge vec. A broker's series is created at zero on the first connection
+// attempt to it:
+//
+//     #{ns}_broker_connections{node_id="#{node}"}
+//
+// This package also tracks how many fetched records are buffered waiting to
+// be polled as a gauge:
+//
+//     #{ns}_fetch_queue_depth
+//
+// If the WithRecordLatencies option is used, how long fetched records wait to
+// be polled is tracked as a histogram:
+//
+//     #{ns}_fetch_queue_latency_seconds
+//
+// Incremental fetch sessions (KIP-227) that are reset or killed, which cause
+// the next fetch to resend every partition, are tracked as a counter vec, and
+// the epoch of each broker's session as a gauge vec; see
+// kgo.HookFetchSession:
+//
+//     #{ns}_fetch_session_revocations_total{node_id="#{node}"}
+//     #{ns}_fetch_session_epoch{node_id="#{node}"}
+//
+// On the producing side, the number of records buffered waiting to be
+// produced, and the serialized size of their keys, values, and headers, are
+// tracked as gauges:
+//
+//     #{ns}_producer_buffered_records
+//     #{ns}_producer_buffered_bytes
+//
+// If the WithRecordLatencies option is used, the end to end latency of
+// produced records, from being buffered to their promise being called, which
+// includes time spent batching, writing, and waiting for the broker to
+// acknowledge, is tracked as a histogram vec:
+//
+//     #{ns}_produce_record_latency_seconds{topic="#{topic}"}
+//
+// How long requests wait in each broker's send queue before the client begins
+// writing them, which is a signal of broker backpressure, is tracked as a
+// histogram vec:
+//
+//     #{ns}_broker_send_queue_duration_seconds{node_id="#{node}"}
+//
+// Broker write and read latencies are tracked as histogram vecs, with the
+// phase label separating time spent waiting to write or read ("wait") from
+// the time spent actually writing ("write") or reading ("read"):
+//
+//     #{ns}_write_latency_seconds{node_id="#{node}",phase="wait|write"}
+//     #{ns}_read_latency_seconds{node_id="#{node}",phase="wait|read"}
+//
+// How long successful connections took to establish, including any TLS
+// handshake, is tracked as a histogram vec:
+//
+//     #{ns}_connect_duration_seconds{node_id="#{node}"}
+//
+// Metadata refreshes, and how long they take, are tracked as a counter vec and
+// histogram vec. The triggered_by label is "startup", "periodic", or
+// "triggered"; see kgo.HookMetadataRefresh:
+//
+//     #{ns}_metadata_fetches_total{triggered_by="#{trigger}"}
+//     #{ns}_metadata_fetch_duration_seconds{triggered_by="#{trigger}"}
+//
+// If consuming as part of a group, group rebalances (joins and syncs) are
+// tracked as a counter vec, and how long successful rebalances took as a
+// histogram vec. The reason label is "join", "rebalance", or "error"; see
+// kgo.HookGroupRebalance:
+//
+//     #{ns}_rebalances_total{group_id="#{group}",reason="#{reason}"}
+//     #{ns}_rebalance_duration_seconds{group_id="#{group}"}
+//
+// If consuming as part of a group, committed partition offsets and partitions
+// that failed to commit are tracked as counter vecs, and how long commits took
+// as a histogram vec. If a commit request fails entirely, every partition in
+// the request is counted as an error:
+//
+//     #{ns}_offset_commits_total{group_id="#{group}",topic="#{topic}"}
+//     #{ns}_offset_commit_errors_total{group_id="#{group}",topic="#{topic}"}
+//     #{ns}_offset_commit_duration_seconds{group_id="#{group}"}
+//
+// If producing transactionally, ended transactions are tracked as a counter
+// vec, and how long transactions were open as a histogram vec. The result
+// label is "commit", "abort", or "error" if ending the transaction failed;
+// see kgo.HookTransactionEnd:
+//
+//     #{ns}_transactions_total{result="#{result}"}
+//     #{ns}_transaction_duration_seconds{result="#{result}"}
+//
+// If TLS is used, how long successful connections took to dial and complete
+// the TLS handshake is tracked as a histogram vec, and connection errors that
+// are classified as "tls" (see ErrorClassifier) as a counter vec:
+//
+//     #{ns}_tls_handshake_duration_seconds{node_id="#{node}",tls_version="#{version}"}
+//     #{ns}_tls_errors_total{node_id="#{node}"}
+//
+// If SASL is used, how long successful authentications took is tracked as a
+// histogram vec, and authentication failures as a counter vec:
+//
+//     #{ns}_sasl_handshake_duration_seconds{node_id="#{node}",mechanism="#{mechanism}"}
+//     #{ns}_sasl_errors_total{node_id="#{node}",mechanism="#{mechanism}"}
+//
+// The sizes of individual requests written and responses read are tracked as
+// histogram vecs. If the WithRequestTypeLabel option is used, these also have
+// a request_type label (e.g., "Produce"):
+//
+//     #{ns}_write_bytes_per_request{node_id="#{node}"}
+//     #{ns}_read_bytes_per_request{node_id="#{node}"}
+//
+// The number of requests written to each broker and awaiting a response,
+// which is the depth of the request pipeline, is tracked as a gauge vec. A
+// gauge that only grows indicates a broker accepting requests but not
+// replying:
+//
+//     #{ns}_requests_in_flight{node_id="#{node}"}
+//
+// The connect, metadata, TLS, SASL, write, and read latency histograms
+// default to exponential buckets from 100us to ~3.3s. Buckets for all
+// duration histograms can be overridden with the WithHistogramBuckets option.
+//
+// If the WithBatchHistograms option is used, the distribution of bytes and
+// records per produced and fetched batch is tracked as histogram vecs:
+//
+//     #{ns}_produce_batch_bytes{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_fetch_batch_bytes{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_produce_batch_records{node_id="#{node}",topic="#{topic}"}
+//     #{ns}_fetch_batch_records{node_id="#{node}",topic="#{topic}"}
+//
+// If a client is registered with TrackProduceLag, this package also tracks the
+// following gauge vec:
+//
+//     #{ns}_produce_lag_records{topic="#{topic}",partition="#{partition}"}
+//
+// If a client is registered with TrackIdleConnections, this package also
+// tracks how many of each broker's open connections have not written a
+// request within the IdleThreshold, which can show connections that could be
+// closed sooner to reduce open file descriptors:
+//
+//     #{ns}_idle_connections{node_id="#{node}"}
+//
+// A LagReporter, created with NewLagReporter, periodically polls the lag of
+// the group a client is consuming in and tracks it as a gauge vec:
+//
+//     #{ns}_consumer_group_lag{topic="#{topic}",partition="#{partition}"}
 //
 // This can be used in a client like so:
 //
@@ -21,40 +188,105 @@
 //     )
 //
 // By default, metrics are installed under the a new prometheus registry, but
-// this can be overridden with the Registry option.
+// this can be overridden with the Registry option. If multiple clients share
+// a registry, each client's Metrics must use a unique namespace, WithPrefix,
+// or ConstLabels.
+//
+// Classes of metrics can be disabled entirely with the WithoutProduceMetrics,
+// WithoutFetchMetrics, WithoutBrokerConnectMetrics, and WithoutBrokerIOMetrics
+// options. Disabled metrics are not registered and their hooks do nothing.
 //
-// Note that seed brokers use broker IDs starting at math.MinInt32.
+// The write and read latency histograms can carry exemplars, such as trace IDs
+// pulled from request contexts, with the WithExemplarFromContext option.
+//
+// Series are kept until deleted. Clients that work through many short lived
+// topics or brokers can delete stale series with Cleanup and CleanupBroker.
+//
+// Note that seed brokers use broker IDs starting at math.MinInt32. How seed
+// brokers are labeled can be changed with the SeedBrokerLabelMode option.
 package kprom
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 
+	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
 )
 
 var ( // interface checks to ensure we implement the hooks properly
 	_ kgo.HookBrokerConnect       = new(Metrics)
 	_ kgo.HookBrokerDisconnect    = new(Metrics)
-	_ kgo.HookBrokerWrite         = new(Metrics)
-	_ kgo.HookBrokerRead          = new(Metrics)
+	_ kgo.HookBrokerWriteContext  = new(Metrics)
+	_ kgo.HookBrokerReadContext   = new(Metrics)
+	_ kgo.HookBrokerE2E           = new(Metrics)
+	_ kgo.HookBrokerSendQueued    = new(Metrics)
+	_ kgo.HookBrokerThrottleKey   = new(Metrics)
+	_ kgo.HookBrokerSASL          = new(Metrics)
+	_ kgo.HookMetadataRefresh     = new(Metrics)
+	_ kgo.HookGroupRebalance      = new(Metrics)
+	_ kgo.HookGroupOffsetCommit   = new(Metrics)
+	_ kgo.HookTransactionEnd      = new(Metrics)
 	_ kgo.HookProduceBatchWritten = new(Metrics)
 	_ kgo.HookFetchBatchRead      = new(Metrics)
+
+	_ kgo.HookFetchRecordBuffered     = new(Metrics)
+	_ kgo.HookFetchRecordUnbuffered   = new(Metrics)
+	_ kgo.HookProduceRecordBuffered   = new(Metrics)
+	_ kgo.HookProduceRecordUnbuffered = new(Metrics)
+	_ kgo.HookProducePartitionError   = new(Metrics)
+	_ kgo.HookFetchPartitionError     = new(Metrics)
+	_ kgo.HookFetchSession            = new(Metrics)
+	_ kgo.HookClientClosed            = new(Metrics)
 )
 
 // Metrics provides prometheus metrics to a given registry.
 type Metrics struct {
 	cfg cfg
 
+	namespace string
+
 	connects    *prometheus.CounterVec
 	connectErrs *prometheus.CounterVec
 	disconnects *prometheus.CounterVec
+	connections *prometheus.GaugeVec
+
+	connectDuration *prometheus.HistogramVec
+
+	metadataFetches  *prometheus.CounterVec
+	metadataDuration *prometheus.HistogramVec
+
+	rebalances        *prometheus.CounterVec
+	rebalanceDuration *prometheus.HistogramVec
+
+	offsetCommits        *prometheus.CounterVec
+	offsetCommitErrs     *prometheus.CounterVec
+	offsetCommitDuration *prometheus.HistogramVec
+
+	transactions        *prometheus.CounterVec
+	transactionDuration *prometheus.HistogramVec
+
+	tlsDuration *prometheus.HistogramVec
+	tlsErrs     *prometheus.CounterVec
+
+	saslDuration *prometheus.HistogramVec
+	saslErrs     *prometheus.CounterVec
 
 	writeErrs  *prometheus.CounterVec
 	writeBytes *prometheus.CounterVec
@@ -62,8 +294,48 @@ type Metrics struct {
 	readErrs  *prometheus.CounterVec
 	readBytes *prometheus.CounterVec
 
-	produceBytes *prometheus.CounterVec
-	fetchBytes   *prometheus.CounterVec
+	throttleDuration *prometheus.CounterVec
+
+	produceBytes           *prometheus.CounterVec
+	fetchBytes             *prometheus.CounterVec
+	produceCompressedBytes *prometheus.CounterVec
+	fetchCompressedBytes   *prometheus.CounterVec
+	produceRecords         *prometheus.CounterVec
+	fetchRecords           *prometheus.CounterVec
+	produceRetries         *prometheus.CounterVec
+	produceErrs            *prometheus.CounterVec
+	fetchErrs              *prometheus.CounterVec
+	produceDrops           *prometheus.CounterVec
+
+	fetchQueueDepth   prometheus.Gauge
+	fetchQueueLatency prometheus.Histogram // nil unless WithRecordLatencies
+	fetchBufferedAt   sync.Map             // *kgo.Record => time.Time
+
+	fetchSessionRevocations *prometheus.CounterVec
+	fetchSessionEpoch       *prometheus.GaugeVec
+
+	produceBufferedRecords prometheus.Gauge
+	produceBufferedBytes   prometheus.Gauge
+	produceRecordLatency   *prometheus.HistogramVec // nil unless WithRecordLatencies
+	produceBufferedAt      sync.Map                 // *kgo.Record => time.Time
+
+	sendQueueDuration *prometheus.HistogramVec
+
+	writeLatency *prometheus.HistogramVec
+	readLatency  *prometheus.HistogramVec
+
+	writeRequestBytes *prometheus.HistogramVec
+	readRequestBytes  *prometheus.HistogramVec
+
+	requestsInFlight *prometheus.GaugeVec
+
+	produceBatchBytes   *prometheus.HistogramVec // nil unless WithBatchHistograms
+	fetchBatchBytes     *prometheus.HistogramVec
+	produceBatchRecords *prometheus.HistogramVec
+	fetchBatchRecords   *prometheus.HistogramVec
+
+	topicVecs []*prometheus.MetricVec // vecs with a topic label, for Cleanup
+	nodeVecs  []*prometheus.MetricVec // vecs with a node_id label, for CleanupBroker
 }
 
 // Registry returns the prometheus registry that metrics were added to.
@@ -79,11 +351,118 @@ func (m *Metrics) Handler() http.Handler {
 	return promhttp.HandlerFor(m.cfg.reg, m.cfg.handlerOpts)
 }
 
+// MetricsSnapshot is a point in time copy of metric values, keyed by the
+// metric name and its labels as they appear in the Prometheus text format,
+// such as:
+//
+//     ns_connects_total{node_id="1"}
+//
+// Histograms have a _count and _sum entry for each label set; buckets are
+// not included.
+type MetricsSnapshot map[string]float64
+
+// Snapshot returns the current values of all metrics under this Metrics'
+// namespace and prefix in the registry. This is useful in tests, or to feed
+// metrics into systems that do not scrape Prometheus.
+//
+// Gather errors are not returned: Snapshot returns whatever metrics could be
+// gathered. Metrics registered by this package always gather successfully, so
+// an error can only come from collectors added to a user provided Registry.
+// To see such errors, call Gather on the Registry directly.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	var prefix string
+	for _, part := range []string{m.namespace, m.cfg.prefix} {
+		if part != "" {
+			prefix += part + "_"
+		}
+	}
+
+	mfs, _ := m.cfg.reg.Gather()
+	snap := make(MetricsSnapshot)
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := snapshotLabels(metric.GetLabel())
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				snap[name+labels] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				snap[name+labels] = metric.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				snap[name+labels] = metric.GetUntyped().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				h := metric.GetHistogram()
+				snap[name+"_count"+labels] = float64(h.GetSampleCount())
+				snap[name+"_sum"+labels] = h.GetSampleSum()
+			}
+		}
+	}
+	return snap
+}
+
+// snapshotLabels formats label pairs as they appear in the Prometheus text
+// format. Gathered labels are already sorted by name.
+func snapshotLabels(pairs []*dto.LabelPair) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, pair := range pairs {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pair.GetName())
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(pair.GetValue()))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
 type cfg struct {
 	reg *prometheus.Registry
 
 	handlerOpts  promhttp.HandlerOpts
 	goCollectors bool
+	prefix       string
+	seedMode     SeedLabelMode
+	seedLabel    string
+	rack         bool
+	classifier   ErrorClassifier
+	constLabels  prometheus.Labels
+	labelRenames map[string]string
+	allowTopics  map[string]struct{}
+	denyTopics   map[string]struct{}
+	allowRe      *regexp.Regexp
+	denyRe       *regexp.Regexp
+	buckets      []float64
+	partitions   bool
+	compression  bool
+
+	requestTypes bool
+
+	batchHistograms  bool
+	batchByteBuckets []float64
+
+	recordLatencies bool
+
+	nativeHistograms   bool
+	nativeBucketFactor float64
+	nativeMaxBuckets   uint32
+	nativeMinReset     time.Duration
+
+	noProduce       bool
+	noFetch         bool
+	noBrokerConnect bool
+	noBrokerIO      bool
+
+	exemplarFn func(context.Context) prometheus.Labels
+
+	idleThreshold time.Duration
 }
 
 // Opt applies options to further tune how prometheus metrics are gathered or
@@ -107,6 +486,341 @@ func GoCollectors() Opt {
 	return opt{func(c *cfg) { c.goCollectors = true }}
 }
 
+// WithPrefix prepends prefix and an underscore to every metric name, after
+// the namespace if one is used. For example, with namespace "ns" and prefix
+// "east", metrics are named ns_east_connects_total and so on.
+//
+// Every Metrics registered to the same registry must have unique metric
+// names or constant labels. When running multiple clients in one process (say,
+// one per cluster) that share a registry, give each client's Metrics a unique
+// namespace, prefix, or ConstLabels. Alternatively, use a separate registry
+// per client with the Registry option.
+func WithPrefix(prefix string) Opt {
+	return opt{func(c *cfg) { c.prefix = prefix }}
+}
+
+// ConstLabels adds the given constant labels to every metric, such as
+// cluster="prod" or client="inventory-consumer".
+//
+// Multiple Metrics that use the same namespace and prefix can share a
+// registry as long as each uses the same label names with different values.
+func ConstLabels(labels prometheus.Labels) Opt {
+	return opt{func(c *cfg) { c.constLabels = labels }}
+}
+
+// OtherTopicLabel is the topic label value that topics are aggregated under if
+// they are filtered with AllowTopics or DenyTopics.
+const OtherTopicLabel = "__other__"
+
+// AllowTopics sets topics to label individually in per-topic metrics; all
+// other topics are aggregated under the OtherTopicLabel topic label. This can
+// be used to limit cardinality in clusters with many topics while keeping
+// totals accurate.
+//
+// This option can be used multiple times to allow more topics. Topic
+// filtering applies to the per-topic produce and fetch metrics and to
+// produce_drops_total, but not to produce_lag_records.
+func AllowTopics(topics ...string) Opt {
+	return opt{func(c *cfg) {
+		if c.allowTopics == nil {
+			c.allowTopics = make(map[string]struct{})
+		}
+		for _, topic := range topics {
+			c.allowTopics[topic] = struct{}{}
+		}
+	}}
+}
+
+// DenyTopics sets topics to aggregate under the OtherTopicLabel topic label in
+// per-topic metrics, rather than labeling them individually. If a topic is
+// both allowed and denied, it is denied.
+//
+// This option can be used multiple times to deny more topics. See AllowTopics
+// for which metrics are filtered.
+func DenyTopics(topics ...string) Opt {
+	return opt{func(c *cfg) {
+		if c.denyTopics == nil {
+			c.denyTopics = make(map[string]struct{})
+		}
+		for _, topic := range topics {
+			c.denyTopics[topic] = struct{}{}
+		}
+	}}
+}
+
+// AllowTopicPattern labels topics matching the pattern individually in
+// per-topic metrics, aggregating all other topics under the OtherTopicLabel
+// topic label. If AllowTopics is also used, topics that either match the
+// pattern or are exactly allowed are labeled individually.
+//
+// Exact matches take precedence over patterns: a topic denied with DenyTopics
+// is aggregated even if it matches this pattern.
+func AllowTopicPattern(re *regexp.Regexp) Opt {
+	return opt{func(c *cfg) { c.allowRe = re }}
+}
+
+// DenyTopicPattern aggregates topics matching the pattern under the
+// OtherTopicLabel topic label in per-topic metrics.
+//
+// Exact matches take precedence over patterns: a topic allowed with
+// AllowTopics is labeled individually even if it matches this pattern. If a
+// topic matches both DenyTopicPattern and AllowTopicPattern, it is denied.
+func DenyTopicPattern(re *regexp.Regexp) Opt {
+	return opt{func(c *cfg) { c.denyRe = re }}
+}
+
+// WithLabelRename renames labels from their default names (the keys of the
+// map) to new names (the values), for example to use broker_id rather than
+// node_id, or kafka_topic rather than topic.
+func WithLabelRename(renames map[string]string) Opt {
+	return opt{func(c *cfg) { c.labelRenames = renames }}
+}
+
+// SeedLabelMode controls how metrics for seed brokers are labeled; see
+// SeedBrokerLabelMode.
+type SeedLabelMode int8
+
+const (
+	// SeedLabelIndividual labels each seed broker with its own node ID,
+	// which starts at math.MinInt32. This is the default.
+	SeedLabelIndividual SeedLabelMode = iota
+	// SeedLabelAggregated labels all seed brokers with node_id="seed", or
+	// with the value set by SeedBrokerLabelValue.
+	SeedLabelAggregated
+	// SeedLabelOmit does not record any metrics for seed brokers.
+	SeedLabelOmit
+)
+
+// SeedBrokerLabelMode sets how metrics for seed brokers are labeled, by
+// default SeedLabelIndividual.
+//
+// Seed brokers are only used to bootstrap the client and to issue requests
+// before the cluster's brokers are discovered, so their individual node IDs
+// are rarely meaningful. SeedLabelAggregated groups them all under a single
+// "seed" node ID, while SeedLabelOmit skips recording them entirely.
+func SeedBrokerLabelMode(mode SeedLabelMode) Opt {
+	return opt{func(c *cfg) { c.seedMode = mode }}
+}
+
+// SeedBrokerLabelValue labels all seed brokers with the given node_id, rather
+// than the default "seed". This implies SeedBrokerLabelMode with
+// SeedLabelAggregated.
+func SeedBrokerLabelValue(value string) Opt {
+	return opt{func(c *cfg) {
+		c.seedMode = SeedLabelAggregated
+		c.seedLabel = value
+	}}
+}
+
+// WithHistogramBuckets sets the buckets to use for every duration histogram,
+// overriding the defaults (prometheus.DefBuckets for queue durations, and
+// exponential buckets from 100us to ~3.3s for connect, write, and read
+// latencies).
+func WithHistogramBuckets(buckets []float64) Opt {
+	return opt{func(c *cfg) { c.buckets = buckets }}
+}
+
+// WithNativeHistograms enables Prometheus native (sparse) histograms for every
+// histogram. Native histograms have exponential buckets whose resolution is
+// chosen by bucketFactor: each bucket is at most bucketFactor times wider than
+// the previous one, so 1.1 gives buckets roughly 10% wide. If a histogram
+// grows past maxBuckets buckets, its resolution is reduced, or it is reset if
+// it has not been reset within minResetDuration. A maxBuckets of zero means no
+// limit.
+//
+// The classic buckets are kept, so scrapers that do not negotiate the protobuf
+// exposition format still see them. Native histograms are only scraped by
+// Prometheus 2.40+ with the native-histograms feature flag enabled.
+func WithNativeHistograms(bucketFactor float64, maxBuckets uint32, minResetDuration time.Duration) Opt {
+	return opt{func(c *cfg) {
+		c.nativeHistograms = true
+		c.nativeBucketFactor = bucketFactor
+		c.nativeMaxBuckets = maxBuckets
+		c.nativeMinReset = minResetDuration
+	}}
+}
+
+// ErrorClassifier classifies connection errors into the error_type label of
+// the connect_errors_total counter vec.
+type ErrorClassifier interface {
+	// ClassifyError returns the error_type label value for a non-nil
+	// connection error.
+	ClassifyError(error) string
+}
+
+// DefaultErrorClassifier returns the ErrorClassifier used by default, which
+// classifies errors as one of "dns", "tls", "auth", "refused", "timeout", or
+// "other".
+func DefaultErrorClassifier() ErrorClassifier { return defaultClassifier{} }
+
+type defaultClassifier struct{}
+
+func (defaultClassifier) ClassifyError(err error) string {
+	var (
+		dnsErr     *net.DNSError
+		netErr     net.Error
+		recordErr  tls.RecordHeaderError
+		authErr    x509.UnknownAuthorityError
+		certErr    x509.CertificateInvalidError
+		hostErr    x509.HostnameError
+		errMessage = err.Error()
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &recordErr),
+		errors.As(err, &authErr),
+		errors.As(err, &certErr),
+		errors.As(err, &hostErr),
+		strings.Contains(errMessage, "tls:"),
+		strings.Contains(errMessage, "x509:"):
+		return "tls"
+	case errors.Is(err, kerr.SaslAuthenticationFailed),
+		errors.Is(err, kerr.UnsupportedSaslMechanism),
+		errors.Is(err, kerr.IllegalSaslState),
+		strings.Contains(errMessage, "sasl"):
+		return "auth"
+	case errors.Is(err, syscall.ECONNREFUSED),
+		strings.Contains(errMessage, "connection refused"):
+		return "refused"
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "other"
+	}
+}
+
+// WithErrorClassifier sets how connection errors are classified into the
+// error_type label of connect_errors_total, overriding the default of
+// DefaultErrorClassifier. A nil classifier is ignored.
+func WithErrorClassifier(classifier ErrorClassifier) Opt {
+	return opt{func(c *cfg) {
+		if classifier != nil {
+			c.classifier = classifier
+		}
+	}}
+}
+
+// WithRackLabel adds a rack label after the node_id label of every
+// broker-level metric, which can be used to track cross-AZ traffic. Brokers
+// that do not have a rack have an empty rack label.
+func WithRackLabel() Opt {
+	return opt{func(c *cfg) { c.rack = true }}
+}
+
+// WithRequestTypeLabel adds a request_type label to the write_bytes_per_request
+// and read_bytes_per_request histograms, which is the name of the request's
+// API key (e.g., "Produce" or "Metadata").
+func WithRequestTypeLabel() Opt {
+	return opt{func(c *cfg) { c.requestTypes = true }}
+}
+
+// WithBatchHistograms enables the produce_batch_bytes, fetch_batch_bytes,
+// produce_batch_records, and fetch_batch_records histogram vecs, which can be
+// used to tune ProducerBatchMaxBytes and FetchMaxBytes.
+//
+// The bytes histograms default to buckets of powers of two from 512 bytes to
+// 1MiB, which can be overridden with WithBatchBytesBuckets. The records
+// histograms use buckets of powers of two from 1 to 8192.
+func WithBatchHistograms() Opt {
+	return opt{func(c *cfg) { c.batchHistograms = true }}
+}
+
+// WithBatchBytesBuckets sets the buckets to use for the batch bytes
+// histograms enabled with WithBatchHistograms.
+func WithBatchBytesBuckets(buckets []float64) Opt {
+	return opt{func(c *cfg) { c.batchByteBuckets = buckets }}
+}
+
+// WithRecordLatencies enables the fetch_queue_latency_seconds histogram, which
+// tracks how long each fetched record waits to be polled, and the
+// produce_record_latency_seconds histogram vec, which tracks how long each
+// produced record takes from being buffered to its promise being called.
+//
+// This is opt-in because it records the time every record is buffered until
+// the record is unbuffered, which is a per-record cost on the hot path. Times
+// kept for records that are still buffered when the client is closed are
+// released in OnClientClosed.
+func WithRecordLatencies() Opt {
+	return opt{func(c *cfg) { c.recordLatencies = true }}
+}
+
+// IdleThreshold sets how long a connection must go without writing a request
+// to be counted as idle by TrackIdleConnections, overriding the default 10s.
+// The client closes connections that have neither written nor read for its
+// ConnIdleTimeout, which defaults to 20s, so this should be lower than that.
+func IdleThreshold(threshold time.Duration) Opt {
+	return opt{func(c *cfg) { c.idleThreshold = threshold }}
+}
+
+// WithExemplarFromContext sets a function that returns exemplar labels, such
+// as a trace ID, from the context of each request. The labels are attached to
+// the write_latency_seconds and read_latency_seconds observations for the
+// request, allowing drilling down from slow latencies into specific traces.
+// If the function returns no labels, the observation has no exemplar.
+//
+// Exemplars are only exposed if the handler is configured with
+// promhttp.HandlerOpts.EnableOpenMetrics. Prometheus limits exemplar labels to
+// 128 runes in total; observing longer exemplars panics.
+//
+// Only requests issued with a caller's context, such as through
+// kgo.Client.Request, carry that context; produce and fetch requests batch many
+// records and are issued with the client's own context.
+func WithExemplarFromContext(fn func(context.Context) prometheus.Labels) Opt {
+	return opt{func(c *cfg) { c.exemplarFn = fn }}
+}
+
+// WithoutProduceMetrics disables all produce metrics: the produce bytes,
+// records, retries, errors, and drops counter vecs, the producer buffered
+// gauges, the produce record latency histogram, and the produce batch
+// histograms. This is useful to avoid the cost of per-topic labels in high
+// throughput producers that do not need them.
+func WithoutProduceMetrics() Opt {
+	return opt{func(c *cfg) { c.noProduce = true }}
+}
+
+// WithoutFetchMetrics disables all fetch metrics: the fetch bytes, records,
+// and errors counter vecs, the fetch queue depth gauge and latency histogram,
+// the fetch session metrics, and the fetch batch histograms.
+func WithoutFetchMetrics() Opt {
+	return opt{func(c *cfg) { c.noFetch = true }}
+}
+
+// WithoutBrokerConnectMetrics disables the connects, connect errors,
+// disconnects, connections, connect duration, and TLS metrics.
+func WithoutBrokerConnectMetrics() Opt {
+	return opt{func(c *cfg) { c.noBrokerConnect = true }}
+}
+
+// WithoutBrokerIOMetrics disables the write and read bytes and errors counter
+// vecs, the write and read latency histograms, the bytes per request
+// histograms, and the requests in flight gauge.
+func WithoutBrokerIOMetrics() Opt {
+	return opt{func(c *cfg) { c.noBrokerIO = true }}
+}
+
+// WithPartitionLabel adds a partition label to the produce_bytes_total and
+// fetch_bytes_total counters, which can be used to detect imbalanced (hot)
+// partitions.
+//
+// This is opt-in because it significantly increases the cardinality of these
+// metrics for topics with many partitions.
+func WithPartitionLabel() Opt {
+	return opt{func(c *cfg) { c.partitions = true }}
+}
+
+// WithCompressionLabel adds a compression label to the produce and fetch bytes
+// counters (both uncompressed and compressed), with values none, gzip,
+// snappy, lz4, or zstd. This can be used to verify codec rollouts and to
+// compare compression ratios per codec.
+//
+// This is opt-in because it increases the cardinality of these metrics for
+// topics with mixed codecs.
+func WithCompressionLabel() Opt {
+	return opt{func(c *cfg) { c.compression = true }}
+}
+
 // HandlerOpts sets handler options to use if you wish you use the
 // Metrics.Handler function.
 //
@@ -119,11 +833,14 @@ func HandlerOpts(opts promhttp.HandlerOpts) Opt {
 // NewMetrics returns a new Metrics that adds prometheus metrics to the
 // registry under the given namespace.
 func NewMetrics(namespace string, opts ...Opt) *Metrics {
-	cfg := cfg{
-		reg: prometheus.NewRegistry(),
+	cfg := newCfg(opts)
+
+	topicLabels := []string{"node_id", "topic"}
+	if cfg.partitions {
+		topicLabels = append(topicLabels, "partition")
 	}
-	for _, opt := range opts {
-		opt.apply(&cfg)
+	if cfg.compression {
+		topicLabels = append(topicLabels, "compression")
 	}
 
 	if cfg.goCollectors {
@@ -131,113 +848,1295 @@ func NewMetrics(namespace string, opts ...Opt) *Metrics {
 		cfg.reg.MustRegister(prometheus.NewGoCollector())
 	}
 
-	factory := promauto.With(cfg.reg)
+	var topicVecs, nodeVecs []*prometheus.MetricVec
+	track := func(vec *prometheus.MetricVec, labels []string) {
+		for _, label := range labels {
+			switch label {
+			case "topic":
+				topicVecs = append(topicVecs, vec)
+			case "node_id":
+				nodeVecs = append(nodeVecs, vec)
+			}
+		}
+	}
+
+	newCounterVec := func(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+		opts.Subsystem = cfg.prefix
+		opts.ConstLabels = cfg.constLabels
+		c := prometheus.NewCounterVec(opts, cfg.labelNames(labels))
+		cfg.mustRegister(c)
+		track(c.MetricVec, labels)
+		return c
+	}
+
+	buckets := func(def []float64) []float64 {
+		if cfg.buckets != nil {
+			return cfg.buckets
+		}
+		return def
+	}
+	newGaugeVec := func(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+		opts.Subsystem = cfg.prefix
+		opts.ConstLabels = cfg.constLabels
+		g := prometheus.NewGaugeVec(opts, cfg.labelNames(labels))
+		cfg.mustRegister(g)
+		track(g.MetricVec, labels)
+		return g
+	}
+	newHistogramVec := func(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+		opts.Subsystem = cfg.prefix
+		opts.ConstLabels = cfg.constLabels
+		cfg.native(&opts)
+		h := prometheus.NewHistogramVec(opts, cfg.labelNames(labels))
+		cfg.mustRegister(h)
+		track(h.MetricVec, labels)
+		return h
+	}
+	latencyBuckets := buckets(prometheus.ExponentialBuckets(0.0001, 2, 16))
+
+	requestLabels := []string{"node_id"}
+	if cfg.requestTypes {
+		requestLabels = append(requestLabels, "request_type")
+	}
+	requestBytesBuckets := prometheus.ExponentialBuckets(64, 4, 10) // 64B to 16MiB
 
-	return &Metrics{
+	m := &Metrics{
 		cfg: cfg,
 
-		// connects and disconnects
+		namespace: namespace,
+
+		// metadata
+
+		metadataFetches: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "metadata_fetches_total",
+			Help:      "Total number of metadata refreshes, by what triggered the refresh",
+		}, []string{"triggered_by"}),
+
+		metadataDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "metadata_fetch_duration_seconds",
+			Help:      "Time taken to refresh metadata, by what triggered the refresh",
+			Buckets:   latencyBuckets,
+		}, []string{"triggered_by"}),
+
+		// group
+
+		rebalances: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rebalances_total",
+			Help:      "Total number of group rebalances (joins and syncs), by group and reason",
+		}, []string{"group_id", "reason"}),
+
+		rebalanceDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rebalance_duration_seconds",
+			Help:      "Time taken to join and sync successful group rebalances, by group",
+			Buckets:   buckets(prometheus.DefBuckets),
+		}, []string{"group_id"}),
+
+		offsetCommits: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "offset_commits_total",
+			Help:      "Total number of partition offsets successfully committed, by group and topic",
+		}, []string{"group_id", "topic"}),
+
+		offsetCommitErrs: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "offset_commit_errors_total",
+			Help:      "Total number of partition offsets that failed to commit, by group and topic",
+		}, []string{"group_id", "topic"}),
+
+		offsetCommitDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "offset_commit_duration_seconds",
+			Help:      "Time taken to issue offset commit requests, by group",
+			Buckets:   latencyBuckets,
+		}, []string{"group_id"}),
+
+		transactions: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transactions_total",
+			Help:      "Total number of ended transactions, by result",
+		}, []string{"result"}),
+
+		transactionDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transaction_duration_seconds",
+			Help:      "Time from beginning to ending transactions, by result",
+			Buckets:   buckets(prometheus.DefBuckets),
+		}, []string{"result"}),
+
+		// sasl
+
+		saslDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sasl_handshake_duration_seconds",
+			Help:      "Time taken for successful SASL handshakes and authentications, by broker and mechanism",
+			Buckets:   latencyBuckets,
+		}, []string{"node_id", "mechanism"}),
+
+		saslErrs: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sasl_errors_total",
+			Help:      "Total number of SASL authentication errors, by broker and mechanism",
+		}, []string{"node_id", "mechanism"}),
+
+		// throttle
+
+		throttleDuration: newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "throttle_duration_seconds_total",
+			Help:      "Total time the client was throttled due to quotas, by broker and request type",
+		}, []string{"node_id", "request_type"}),
+
+		sendQueueDuration: newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "broker_send_queue_duration_seconds",
+			Help:      "Time requests waited in a broker's send queue before being written, by broker",
+			Buckets:   buckets(prometheus.DefBuckets),
+		}, []string{"node_id"}),
+	}
+
+	byteBuckets := cfg.batchByteBuckets
+	if byteBuckets == nil {
+		byteBuckets = prometheus.ExponentialBuckets(512, 2, 12)
+	}
+	recordBuckets := prometheus.ExponentialBuckets(1, 2, 14)
 
-		connects: factory.NewCounterVec(prometheus.CounterOpts{
+	if !cfg.noBrokerConnect {
+		m.connects = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "connects_total",
 			Help:      "Total number of connections opened, by broker",
-		}, []string{"node_id"}),
+		}, []string{"node_id"})
 
-		connectErrs: factory.NewCounterVec(prometheus.CounterOpts{
+		m.connectErrs = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "connect_errors_total",
-			Help:      "Total number of connection errors, by broker",
-		}, []string{"node_id"}),
+			Help:      "Total number of connection errors, by broker and error type",
+		}, []string{"node_id", "error_type"})
 
-		disconnects: factory.NewCounterVec(prometheus.CounterOpts{
+		m.disconnects = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "disconnects_total",
 			Help:      "Total number of connections closed, by broker",
-		}, []string{"node_id"}),
+		}, []string{"node_id"})
+
+		m.connections = newGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "broker_connections",
+			Help:      "Number of open connections, by broker",
+		}, []string{"node_id"})
 
-		// write
+		m.connectDuration = newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "connect_duration_seconds",
+			Help:      "Time taken to establish successful connections, by broker",
+			Buckets:   latencyBuckets,
+		}, []string{"node_id"})
+
+		m.tlsDuration = newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tls_handshake_duration_seconds",
+			Help:      "Time taken to dial and complete the TLS handshake for successful TLS connections, by broker and TLS version",
+			Buckets:   latencyBuckets,
+		}, []string{"node_id", "tls_version"})
+
+		m.tlsErrs = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tls_errors_total",
+			Help:      "Total number of connection errors classified as TLS errors, by broker",
+		}, []string{"node_id"})
+	}
 
-		writeErrs: factory.NewCounterVec(prometheus.CounterOpts{
+	if !cfg.noBrokerIO {
+		m.writeErrs = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "write_errors_total",
-			Help:      "Total number of write errors, by broker",
-		}, []string{"node_id"}),
+			Help:      "Total number of write errors, by broker and request type",
+		}, []string{"node_id", "request_type"})
 
-		writeBytes: factory.NewCounterVec(prometheus.CounterOpts{
+		m.writeBytes = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "write_bytes_total",
 			Help:      "Total number of bytes written, by broker",
-		}, []string{"node_id"}),
+		}, []string{"node_id"})
 
-		// read
-
-		readErrs: factory.NewCounterVec(prometheus.CounterOpts{
+		m.readErrs = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "read_errors_total",
-			Help:      "Total number of read errors, by broker",
-		}, []string{"node_id"}),
+			Help:      "Total number of read errors, by broker and request type",
+		}, []string{"node_id", "request_type"})
 
-		readBytes: factory.NewCounterVec(prometheus.CounterOpts{
+		m.readBytes = newCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "read_bytes_total",
 			Help:      "Total number of bytes read, by broker",
-		}, []string{"node_id"}),
+		}, []string{"node_id"})
 
-		// produce & consume
+		m.writeLatency = newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_latency_seconds",
+			Help:      "Time spent waiting to write and writing requests, by broker and phase",
+			Buckets:   latencyBuckets,
+		}, []string{"node_id", "phase"})
 
-		produceBytes: factory.NewCounterVec(prometheus.CounterOpts{
+		m.readLatency = newHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
-			Name:      "produce_bytes_total",
-			Help:      "Total number of uncompressed bytes produced, by broker and topic",
-		}, []string{"node_id", "topic"}),
+			Name:      "read_latency_seconds",
+			Help:      "Time spent waiting to read and reading responses, by broker and phase",
+			Buckets:   latencyBuckets,
+		}, []string{"node_id", "phase"})
 
-		fetchBytes: factory.NewCounterVec(prometheus.CounterOpts{
+		m.writeRequestBytes = newHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
-			Name:      "fetch_bytes_total",
-			Help:      "Total number of uncompressed bytes fetched, by broker and topic",
-		}, []string{"node_id", "topic"}),
-	}
-}
+			Name:      "write_bytes_per_request",
+			Help:      "Bytes written per request, by broker",
+			Buckets:   requestBytesBuckets,
+		}, requestLabels)
 
-func (m *Metrics) OnBrokerConnect(meta kgo.BrokerMetadata, _ time.Duration, _ net.Conn, err error) {
-	node := strconv.Itoa(int(meta.NodeID))
-	if err != nil {
-		m.connectErrs.WithLabelValues(node).Inc()
-		return
+		m.readRequestBytes = newHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "read_bytes_per_request",
+			Help:      "Bytes read per response, by broker",
+			Buckets:   requestBytesBuckets,
+		}, requestLabels)
+
+		m.requestsInFlight = newGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests written and awaiting a response, by broker",
+		}, []string{"node_id"})
 	}
-	m.connects.WithLabelValues(node).Inc()
-}
 
-func (m *Metrics) OnBrokerDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
-	node := strconv.Itoa(int(meta.NodeID))
-	m.disconnects.WithLabelValues(node).Inc()
-}
+	if !cfg.noProduce {
+		m.produceBytes = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_bytes_total",
+			Help:      "Total number of uncompressed bytes produced, by broker and topic",
+		}, topicLabels)
 
-func (m *Metrics) OnBrokerWrite(meta kgo.BrokerMetadata, _ int16, bytesWritten int, _, _ time.Duration, err error) {
-	node := strconv.Itoa(int(meta.NodeID))
-	if err != nil {
-		m.writeErrs.WithLabelValues(node).Inc()
-		return
-	}
-	m.writeBytes.WithLabelValues(node).Add(float64(bytesWritten))
-}
+		m.produceCompressedBytes = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_compressed_bytes_total",
+			Help:      "Total number of compressed bytes produced, by broker and topic",
+		}, topicLabels)
 
-func (m *Metrics) OnBrokerRead(meta kgo.BrokerMetadata, _ int16, bytesRead int, _, _ time.Duration, err error) {
-	node := strconv.Itoa(int(meta.NodeID))
-	if err != nil {
-		m.readErrs.WithLabelValues(node).Inc()
-		return
+		m.produceRecords = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_records_total",
+			Help:      "Total number of records produced, by broker and topic",
+		}, []string{"node_id", "topic"})
+
+		m.produceRetries = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_retries_total",
+			Help:      "Total number of times successfully produced batches were retried, by broker and topic",
+		}, []string{"node_id", "topic"})
+
+		m.produceErrs = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_errors_total",
+			Help:      "Total number of partition errors in produce responses, by broker and topic",
+		}, []string{"node_id", "topic"})
+
+		m.produceDrops = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "produce_drops_total",
+			Help:      "Total number of records that failed to be produced, by topic",
+		}, []string{"topic"})
+
+		m.produceBufferedRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   cfg.prefix,
+			ConstLabels: cfg.constLabels,
+			Name:        "producer_buffered_records",
+			Help:        "Number of records buffered and waiting to be produced",
+		})
+		cfg.mustRegister(m.produceBufferedRecords)
+
+		m.produceBufferedBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   cfg.prefix,
+			ConstLabels: cfg.constLabels,
+			Name:        "producer_buffered_bytes",
+			Help:        "Number of key, value, and header bytes of records buffered and waiting to be produced",
+		})
+		cfg.mustRegister(m.produceBufferedBytes)
+
+		if cfg.recordLatencies {
+			m.produceRecordLatency = newHistogramVec(prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "produce_record_latency_seconds",
+				Help:      "Time from records being buffered to their produce promise being called, by topic",
+				Buckets:   buckets(prometheus.DefBuckets),
+			}, []string{"topic"})
+		}
+
+		if cfg.batchHistograms {
+			m.produceBatchBytes = newHistogramVec(prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "produce_batch_bytes",
+				Help:      "Uncompressed bytes per produced batch, by broker and topic",
+				Buckets:   byteBuckets,
+			}, []string{"node_id", "topic"})
+			m.produceBatchRecords = newHistogramVec(prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "produce_batch_records",
+				Help:      "Records per produced batch, by broker and topic",
+				Buckets:   recordBuckets,
+			}, []string{"node_id", "topic"})
+		}
 	}
-	m.readBytes.WithLabelValues(node).Add(float64(bytesRead))
+
+	if !cfg.noFetch {
+		m.fetchBytes = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_bytes_total",
+			Help:      "Total number of uncompressed bytes fetched, by broker and topic",
+		}, topicLabels)
+
+		m.fetchCompressedBytes = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_compressed_bytes_total",
+			Help:      "Total number of compressed bytes fetched, by broker and topic",
+		}, topicLabels)
+
+		m.fetchRecords = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_records_total",
+			Help:      "Total number of records fetched, by broker and topic",
+		}, []string{"node_id", "topic"})
+
+		m.fetchErrs = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_errors_total",
+			Help:      "Total number of partition errors in fetch responses, by broker and topic",
+		}, []string{"node_id", "topic"})
+
+		m.fetchSessionRevocations = newCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fetch_session_revocations_total",
+			Help:      "Total number of incremental fetch sessions reset or killed, by broker",
+		}, []string{"node_id"})
+
+		m.fetchSessionEpoch = newGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "fetch_session_epoch",
+			Help:      "Epoch of the incremental fetch session the next fetch will use, by broker",
+		}, []string{"node_id"})
+
+		m.fetchQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   cfg.prefix,
+			ConstLabels: cfg.constLabels,
+			Name:        "fetch_queue_depth",
+			Help:        "Number of fetched records buffered and waiting to be polled",
+		})
+		cfg.mustRegister(m.fetchQueueDepth)
+
+		if cfg.recordLatencies {
+			queueOpts := prometheus.HistogramOpts{
+				Namespace:   namespace,
+				Subsystem:   cfg.prefix,
+				ConstLabels: cfg.constLabels,
+				Name:        "fetch_queue_latency_seconds",
+				Help:        "Time fetched records spent buffered before being polled or discarded",
+				Buckets:     buckets(prometheus.DefBuckets),
+			}
+			cfg.native(&queueOpts)
+			m.fetchQueueLatency = prometheus.NewHistogram(queueOpts)
+			cfg.mustRegister(m.fetchQueueLatency)
+		}
+
+		if cfg.batchHistograms {
+			m.fetchBatchBytes = newHistogramVec(prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "fetch_batch_bytes",
+				Help:      "Uncompressed bytes per fetched batch, by broker and topic",
+				Buckets:   byteBuckets,
+			}, []string{"node_id", "topic"})
+			m.fetchBatchRecords = newHistogramVec(prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "fetch_batch_records",
+				Help:      "Records per fetched batch, by broker and topic",
+				Buckets:   recordBuckets,
+			}, []string{"node_id", "topic"})
+		}
+	}
+
+	m.topicVecs = topicVecs
+	m.nodeVecs = nodeVecs
+
+	return m
 }
 
-func (m *Metrics) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, _ int32, pbm kgo.ProduceBatchMetrics) {
-	node := strconv.Itoa(int(meta.NodeID))
-	m.produceBytes.WithLabelValues(node, topic).Add(float64(pbm.UncompressedBytes))
+// Cleanup deletes every metric series labeled with the given topic. Series
+// are never deleted on their own, so long running clients that work through
+// many short lived topics should call this once a topic is deleted or no
+// longer used, to keep the number of series from growing forever.
+//
+// This does nothing if the topic is reported as OtherTopicLabel, since that
+// label aggregates topics that may still be in use.
+func (m *Metrics) Cleanup(topic string) {
+	if m.cfg.topicLabel(topic) != topic {
+		return
+	}
+	m.deleteSeries(m.topicVecs, "topic", topic)
+}
+
+// CleanupBroker deletes every metric series labeled with the given broker,
+// which is useful once a broker is removed from the cluster. Seed brokers
+// have negative node IDs.
+func (m *Metrics) CleanupBroker(nodeID int32) {
+	node, ok := m.nodeLabel(kgo.BrokerMetadata{NodeID: nodeID})
+	if !ok {
+		return
+	}
+	m.deleteSeries(m.nodeVecs, "node_id", node[0])
+}
+
+// deleteSeries deletes all series in vecs whose label has the given value.
+func (m *Metrics) deleteSeries(vecs []*prometheus.MetricVec, label, value string) {
+	label = m.cfg.labelNames([]string{label})[0]
+	for _, vec := range vecs {
+		vec.DeletePartialMatch(prometheus.Labels{label: value})
+	}
+}
+
+// TrackProduceLag registers the produce_lag_records gauge vec, which reports
+// the client's EstimatedProduceLag every time metrics are collected.
+//
+// Since the client must exist to be tracked, this must be called after
+// creating the client with these metrics as hooks. This should only be called
+// once per Metrics.
+func (m *Metrics) TrackProduceLag(cl *kgo.Client) {
+	m.cfg.mustRegister(&produceLagCollector{
+		cl: cl,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(m.namespace, m.cfg.prefix, "produce_lag_records"),
+			"Number of buffered records not yet acknowledged, by topic and partition",
+			m.cfg.labelNames([]string{"topic", "partition"}),
+			m.cfg.constLabels,
+		),
+	})
+}
+
+// TrackIdleConnections registers the idle_connections gauge vec, which reports
+// the client's IdleBrokerConnections every time metrics are collected. A
+// connection is idle if it has not written a request within the
+// IdleThreshold.
+//
+// As with TrackProduceLag, this must be called after creating the client with
+// these metrics as hooks, and should only be called once per Metrics.
+func (m *Metrics) TrackIdleConnections(cl *kgo.Client) {
+	m.cfg.mustRegister(&idleConnectionsCollector{
+		m:  m,
+		cl: cl,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(m.namespace, m.cfg.prefix, "idle_connections"),
+			"Number of open connections that have not written a request within the idle threshold, by broker",
+			m.cfg.labelNames([]string{"node_id"}),
+			m.cfg.constLabels,
+		),
+	})
+}
+
+// newCfg returns the default cfg with opts applied.
+func newCfg(opts []Opt) cfg {
+	cfg := cfg{
+		reg:           prometheus.NewRegistry(),
+		classifier:    defaultClassifier{},
+		seedLabel:     "seed",
+		idleThreshold: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg
+}
+
+// labelNames returns labels with the rack label inserted after node_id if
+// WithRackLabel was used, and with any renames from WithLabelRename applied.
+func (c *cfg) labelNames(labels []string) []string {
+	if c.rack && len(labels) > 0 && labels[0] == "node_id" {
+		labels = append([]string{"node_id", "rack"}, labels[1:]...)
+	}
+	if len(c.labelRenames) == 0 {
+		return labels
+	}
+	renamed := make([]string, len(labels))
+	for i, label := range labels {
+		if rename, ok := c.labelRenames[label]; ok {
+			label = rename
+		}
+		renamed[i] = label
+	}
+	return renamed
+}
+
+// native enables native histograms on opts if WithNativeHistograms was used.
+func (c *cfg) native(opts *prometheus.HistogramOpts) {
+	if !c.nativeHistograms {
+		return
+	}
+	opts.NativeHistogramBucketFactor = c.nativeBucketFactor
+	opts.NativeHistogramMaxBucketNumber = c.nativeMaxBuckets
+	opts.NativeHistogramMinResetDuration = c.nativeMinReset
+}
+
+// mustRegister registers the collector, panicking with an explanation on
+// failure. The most common failure is multiple Metrics sharing a registry
+// without unique metric names.
+func (c *cfg) mustRegister(collector prometheus.Collector) {
+	if err := c.reg.Register(collector); err != nil {
+		panic(fmt.Sprintf("kprom: unable to register metrics: %v; if multiple Metrics use the same registry, each must use a unique namespace, WithPrefix, or ConstLabels, or use a separate Registry", err))
+	}
+}
+
+type produceLagCollector struct {
+	cl   *kgo.Client
+	desc *prometheus.Desc
+}
+
+func (c *produceLagCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *produceLagCollector) Collect(ch chan<- prometheus.Metric) {
+	for topic, partitions := range c.cl.EstimatedProduceLag() {
+		for partition, lag := range partitions {
+			ch <- prometheus.MustNewConstMetric(
+				c.desc,
+				prometheus.GaugeValue,
+				float64(lag),
+				topic,
+				strconv.Itoa(int(partition)),
+			)
+		}
+	}
+}
+
+type idleConnectionsCollector struct {
+	m    *Metrics
+	cl   *kgo.Client
+	desc *prometheus.Desc
+}
+
+func (c *idleConnectionsCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *idleConnectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	// Seed brokers can share one label with SeedLabelAggregated, so we
+	// sum per label before reporting.
+	var order []brokerLabels
+	idle := make(map[string]int)
+	for _, conns := range c.cl.IdleBrokerConnections(c.m.cfg.idleThreshold) {
+		node, ok := c.m.nodeLabel(conns.Meta)
+		if !ok {
+			continue
+		}
+		key := strings.Join(node, "\x00")
+		if _, exists := idle[key]; !exists {
+			order = append(order, node)
+		}
+		idle[key] += conns.Idle
+	}
+	for _, node := range order {
+		ch <- prometheus.MustNewConstMetric(
+			c.desc,
+			prometheus.GaugeValue,
+			float64(idle[strings.Join(node, "\x00")]),
+			node...,
+		)
+	}
+}
+
+// LagReporter periodically polls the lag of the group a client is consuming
+// in and reports it as the consumer_group_lag gauge vec.
+type LagReporter struct {
+	cl       *kgo.Client
+	cfg      cfg
+	interval time.Duration
+	lags     *groupLagCollector
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLagReporter returns a LagReporter that, every interval, lists the
+// partitions assigned in the client's group and reports the group's lag for
+// each of them:
+//
+//     #{ns}_consumer_group_lag{topic="#{topic}",partition="#{partition}"}
+//
+// Each poll issues one DescribeGroups request to list the assignment, then one
+// OffsetFetch request for the group's committed offsets and one ListOffsets
+// request for the end offsets of all assigned partitions. Lag is a partition's
+// high watermark minus the group's committed offset. Partitions the group has
+// not committed to are not reported. If a partition fails in either request,
+// its last known lag is left in place; if any request fails as a whole or the
+// group has no assignment (such as while rebalancing), all last known lags are
+// left in place. Topics filtered with AllowTopics, DenyTopics,
+// AllowTopicPattern, or DenyTopicPattern are not reported.
+//
+// Nothing is reported while the client is not consuming as a group member.
+// The reporter stops once the client is closed, or when Close is called. If
+// interval is not positive, lag is polled every 10s.
+//
+// The same options as NewMetrics can be used, with the exception of
+// GoCollectors, which is ignored. To serve this gauge alongside a Metrics,
+// use the Registry option with the Metrics' registry.
+func NewLagReporter(cl *kgo.Client, namespace string, interval time.Duration, opts ...Opt) *LagReporter {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	cfg := newCfg(opts)
+	lags := &groupLagCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cfg.prefix, "consumer_group_lag"),
+			"Number of records the client's group is behind the end of each partition, by topic and partition",
+			cfg.labelNames([]string{"topic", "partition"}),
+			cfg.constLabels,
+		),
+	}
+	cfg.mustRegister(lags)
+
+	ctx, cancel := context.WithCancel(cl.Context())
+	r := &LagReporter{
+		cl:       cl,
+		cfg:      cfg,
+		interval: interval,
+		lags:     lags,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go r.loop(ctx)
+	return r
+}
+
+// Registry returns the prometheus registry that the lag gauge was added to.
+func (r *LagReporter) Registry() *prometheus.Registry {
+	return r.cfg.reg
+}
+
+// Handler returns an http.Handler providing prometheus metrics.
+func (r *LagReporter) Handler() http.Handler {
+	return promhttp.HandlerFor(r.cfg.reg, r.cfg.handlerOpts)
+}
+
+// Close stops polling and waits for any in progress poll to finish. The last
+// reported lags remain registered. This does not need to be called if the
+// client is closed.
+func (r *LagReporter) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *LagReporter) loop(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *LagReporter) poll(ctx context.Context) {
+	group := r.cl.OwnGroupMemberInfo().Group
+	if group == "" {
+		return
+	}
+	assigned, err := r.cl.ListPartitionsForGroup(ctx, group)
+	if err != nil || len(assigned) == 0 {
+		return
+	}
+
+	fetchReq := kmsg.NewPtrOffsetFetchRequest()
+	fetchReq.Group = group
+	listReq := kmsg.NewPtrListOffsetsRequest()
+	listReq.ReplicaID = -1
+	for topic, partitions := range assigned {
+		if r.cfg.topicLabel(topic) != topic {
+			continue
+		}
+		fetchReq.Topics = append(fetchReq.Topics, kmsg.OffsetFetchRequestTopic{
+			Topic:      topic,
+			Partitions: partitions,
+		})
+		listTopic := kmsg.NewListOffsetsRequestTopic()
+		listTopic.Topic = topic
+		for _, partition := range partitions {
+			listPartition := kmsg.NewListOffsetsRequestTopicPartition()
+			listPartition.Partition = partition
+			listPartition.CurrentLeaderEpoch = -1
+			listPartition.Timestamp = -1 // latest
+			listTopic.Partitions = append(listTopic.Partitions, listPartition)
+		}
+		listReq.Topics = append(listReq.Topics, listTopic)
+	}
+	if len(fetchReq.Topics) == 0 {
+		return
+	}
+
+	committed, err := fetchReq.RequestWith(ctx, r.cl)
+	if err != nil || kerr.ErrorForCode(committed.ErrorCode) != nil {
+		return
+	}
+	ends, err := listReq.RequestWith(ctx, r.cl)
+	if err != nil {
+		return
+	}
+
+	r.lags.mu.Lock()
+	prior := r.lags.lags
+	r.lags.mu.Unlock()
+
+	lags := groupLags(committed, ends, prior)
+
+	r.lags.mu.Lock()
+	r.lags.lags = lags
+	r.lags.mu.Unlock()
+}
+
+// groupLags returns the lag of every committed partition, which is the high
+// watermark minus the committed offset, floored at zero. Partitions that
+// failed in either response keep their prior lag, and partitions without a
+// commit are not reported.
+func groupLags(committed *kmsg.OffsetFetchResponse, ends *kmsg.ListOffsetsResponse, prior map[string]map[int32]int64) map[string]map[int32]int64 {
+	endOffsets := make(map[string]map[int32]int64, len(ends.Topics))
+	for _, t := range ends.Topics {
+		partitions := make(map[int32]int64, len(t.Partitions))
+		for _, p := range t.Partitions {
+			if p.ErrorCode == 0 {
+				partitions[p.Partition] = p.Offset
+			}
+		}
+		endOffsets[t.Topic] = partitions
+	}
+
+	lags := make(map[string]map[int32]int64, len(committed.Topics))
+	for _, t := range committed.Topics {
+		partitions := make(map[int32]int64, len(t.Partitions))
+		for _, p := range t.Partitions {
+			end, ok := endOffsets[t.Topic][p.Partition]
+			switch {
+			case p.ErrorCode != 0 || !ok:
+				if lag, ok := prior[t.Topic][p.Partition]; ok {
+					partitions[p.Partition] = lag
+				}
+			case p.Offset >= 0:
+				lag := end - p.Offset
+				if lag < 0 {
+					lag = 0
+				}
+				partitions[p.Partition] = lag
+			}
+		}
+		lags[t.Topic] = partitions
+	}
+	return lags
+}
+
+type groupLagCollector struct {
+	desc *prometheus.Desc
+
+	mu   sync.Mutex
+	lags map[string]map[int32]int64
+}
+
+func (c *groupLagCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *groupLagCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for topic, partitions := range c.lags {
+		for partition, lag := range partitions {
+			ch <- prometheus.MustNewConstMetric(
+				c.desc,
+				prometheus.GaugeValue,
+				float64(lag),
+				topic,
+				strconv.Itoa(int(partition)),
+			)
+		}
+	}
+}
+
+func (m *Metrics) OnBrokerConnect(meta kgo.BrokerMetadata, dialDur time.Duration, conn net.Conn, err error) {
+	if m.cfg.noBrokerConnect {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	connections := m.connections.WithLabelValues(node...) // create at zero on the first attempt
+	if err != nil {
+		errType := m.cfg.classifier.ClassifyError(err)
+		m.connectErrs.WithLabelValues(node.with(errType)...).Inc()
+		if errType == "tls" {
+			m.tlsErrs.WithLabelValues(node...).Inc()
+		}
+		return
+	}
+	m.connects.WithLabelValues(node...).Inc()
+	connections.Inc()
+	m.connectDuration.WithLabelValues(node...).Observe(dialDur.Seconds())
+
+	// The client's dialer performs the TLS handshake, so we cannot time it
+	// separately from the TCP dial; the dial duration of a TLS connection
+	// is the best approximation we have.
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		if state.HandshakeComplete {
+			m.tlsDuration.WithLabelValues(node.with(tlsVersion(state.Version))...).Observe(dialDur.Seconds())
+		}
+	}
+}
+
+// tlsVersion returns the tls_version label value for a TLS version.
+func tlsVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	default:
+		return strconv.Itoa(int(version))
+	}
+}
+
+func (m *Metrics) OnBrokerSASL(meta kgo.BrokerMetadata, mechanism string, authDur time.Duration, err error) {
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	if err != nil {
+		m.saslErrs.WithLabelValues(node.with(mechanism)...).Inc()
+		return
+	}
+	m.saslDuration.WithLabelValues(node.with(mechanism)...).Observe(authDur.Seconds())
+}
+
+func (m *Metrics) OnMetadataRefresh(trigger string, refreshDur time.Duration, _ error) {
+	m.metadataFetches.WithLabelValues(trigger).Inc()
+	m.metadataDuration.WithLabelValues(trigger).Observe(refreshDur.Seconds())
+}
+
+func (m *Metrics) OnGroupRebalance(group, reason string, rebalanceDur time.Duration, err error) {
+	m.rebalances.WithLabelValues(group, reason).Inc()
+	if err == nil {
+		m.rebalanceDuration.WithLabelValues(group).Observe(rebalanceDur.Seconds())
+	}
+}
+
+func (m *Metrics) OnGroupOffsetCommit(group string, req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse, commitDur time.Duration, err error) {
+	m.offsetCommitDuration.WithLabelValues(group).Observe(commitDur.Seconds())
+	if err != nil {
+		for _, t := range req.Topics {
+			m.offsetCommitErrs.WithLabelValues(group, m.cfg.topicLabel(t.Topic)).Add(float64(len(t.Partitions)))
+		}
+		return
+	}
+	for _, t := range resp.Topics {
+		topic := m.cfg.topicLabel(t.Topic)
+		for _, p := range t.Partitions {
+			if p.ErrorCode != 0 {
+				m.offsetCommitErrs.WithLabelValues(group, topic).Inc()
+			} else {
+				m.offsetCommits.WithLabelValues(group, topic).Inc()
+			}
+		}
+	}
+}
+
+func (m *Metrics) OnTransactionEnd(commit bool, txnDur time.Duration, err error) {
+	result := "commit"
+	switch {
+	case err != nil:
+		result = "error"
+	case !commit:
+		result = "abort"
+	}
+	m.transactions.WithLabelValues(result).Inc()
+	m.transactionDuration.WithLabelValues(result).Observe(txnDur.Seconds())
+}
+
+func (m *Metrics) OnBrokerDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
+	if m.cfg.noBrokerConnect {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.disconnects.WithLabelValues(node...).Inc()
+	m.connections.WithLabelValues(node...).Dec()
+}
+
+func (m *Metrics) OnBrokerWriteContext(ctx context.Context, meta kgo.BrokerMetadata, key int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error) {
+	if m.cfg.noBrokerIO {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	exemplar := m.exemplar(ctx)
+	observe(m.writeLatency.WithLabelValues(node.with("wait")...), writeWait.Seconds(), exemplar)
+	observe(m.writeLatency.WithLabelValues(node.with("write")...), timeToWrite.Seconds(), exemplar)
+	if err != nil {
+		m.writeErrs.WithLabelValues(node.with(requestType(key))...).Inc()
+		return
+	}
+	m.writeBytes.WithLabelValues(node...).Add(float64(bytesWritten))
+	m.writeRequestBytes.WithLabelValues(m.requestLabels(node, key)...).Observe(float64(bytesWritten))
+	m.requestsInFlight.WithLabelValues(node...).Inc()
+}
+
+// OnBrokerE2E is called once for every request written, after its response
+// is read, after the connection dies, or immediately for requests that have
+// no response. Decrementing here rather than in OnBrokerRead keeps
+// requests_in_flight accurate across connection deaths and acks=0 produces.
+func (m *Metrics) OnBrokerE2E(meta kgo.BrokerMetadata, _ int16, e2e kgo.BrokerE2E) {
+	if m.cfg.noBrokerIO || e2e.WriteErr != nil {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.requestsInFlight.WithLabelValues(node...).Dec()
+}
+
+func (m *Metrics) OnBrokerReadContext(ctx context.Context, meta kgo.BrokerMetadata, key int16, bytesRead int, readWait, timeToRead time.Duration, err error) {
+	if m.cfg.noBrokerIO {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	exemplar := m.exemplar(ctx)
+	observe(m.readLatency.WithLabelValues(node.with("wait")...), readWait.Seconds(), exemplar)
+	observe(m.readLatency.WithLabelValues(node.with("read")...), timeToRead.Seconds(), exemplar)
+	if err != nil {
+		m.readErrs.WithLabelValues(node.with(requestType(key))...).Inc()
+		return
+	}
+	m.readBytes.WithLabelValues(node...).Add(float64(bytesRead))
+	m.readRequestBytes.WithLabelValues(m.requestLabels(node, key)...).Observe(float64(bytesRead))
+}
+
+// requestLabels returns the label values for per-request metrics, including
+// the request type if WithRequestTypeLabel was used.
+func (m *Metrics) requestLabels(node brokerLabels, key int16) []string {
+	if m.cfg.requestTypes {
+		return node.with(requestType(key))
+	}
+	return node
 }
 
-func (m *Metrics) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, _ int32, fbm kgo.FetchBatchMetrics) {
+// requestType returns the name of a request key, or the key as a number if
+// the key is unknown.
+func requestType(key int16) string {
+	if name := kmsg.NameForKey(key); name != "Unknown" {
+		return name
+	}
+	return strconv.Itoa(int(key))
+}
+
+func (m *Metrics) OnBrokerThrottleKey(meta kgo.BrokerMetadata, key int16, throttleInterval time.Duration, _ bool) {
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.throttleDuration.WithLabelValues(node.with(requestType(key))...).Add(throttleInterval.Seconds())
+}
+
+func (m *Metrics) OnBrokerSendQueued(meta kgo.BrokerMetadata, _ int, queuedAt time.Time) {
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.sendQueueDuration.WithLabelValues(node...).Observe(time.Since(queuedAt).Seconds())
+}
+
+func (m *Metrics) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, partition int32, pbm kgo.ProduceBatchMetrics) {
+	if m.cfg.noProduce {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	topic = m.cfg.topicLabel(topic)
+	labels := m.topicLabels(node, topic, partition, pbm.CompressionType)
+	m.produceBytes.WithLabelValues(labels...).Add(float64(pbm.UncompressedBytes))
+	m.produceCompressedBytes.WithLabelValues(labels...).Add(float64(pbm.CompressedBytes))
+	m.produceRecords.WithLabelValues(node.with(topic)...).Add(float64(pbm.NumRecords))
+	m.produceRetries.WithLabelValues(node.with(topic)...).Add(float64(pbm.NumRetries))
+	if m.produceBatchBytes != nil {
+		m.produceBatchBytes.WithLabelValues(node.with(topic)...).Observe(float64(pbm.UncompressedBytes))
+		m.produceBatchRecords.WithLabelValues(node.with(topic)...).Observe(float64(pbm.NumRecords))
+	}
+}
+
+func (m *Metrics) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, partition int32, fbm kgo.FetchBatchMetrics) {
+	if m.cfg.noFetch {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	topic = m.cfg.topicLabel(topic)
+	labels := m.topicLabels(node, topic, partition, fbm.CompressionType)
+	m.fetchBytes.WithLabelValues(labels...).Add(float64(fbm.UncompressedBytes))
+	m.fetchCompressedBytes.WithLabelValues(labels...).Add(float64(fbm.CompressedBytes))
+	m.fetchRecords.WithLabelValues(node.with(topic)...).Add(float64(fbm.NumRecords))
+	if m.fetchBatchBytes != nil {
+		m.fetchBatchBytes.WithLabelValues(node.with(topic)...).Observe(float64(fbm.UncompressedBytes))
+		m.fetchBatchRecords.WithLabelValues(node.with(topic)...).Observe(float64(fbm.NumRecords))
+	}
+}
+
+func (m *Metrics) OnProducePartitionError(meta kgo.BrokerMetadata, topic string, _ int32, _ error) {
+	if m.cfg.noProduce {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.produceErrs.WithLabelValues(node.with(m.cfg.topicLabel(topic))...).Inc()
+}
+
+func (m *Metrics) OnFetchPartitionError(meta kgo.BrokerMetadata, topic string, _ int32, _ error) {
+	if m.cfg.noFetch {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	m.fetchErrs.WithLabelValues(node.with(m.cfg.topicLabel(topic))...).Inc()
+}
+
+func (m *Metrics) OnFetchSession(meta kgo.BrokerMetadata, _, epoch int32, reset bool) {
+	if m.cfg.noFetch {
+		return
+	}
+	node, ok := m.nodeLabel(meta)
+	if !ok {
+		return
+	}
+	if reset {
+		m.fetchSessionRevocations.WithLabelValues(node...).Inc()
+	}
+	m.fetchSessionEpoch.WithLabelValues(node...).Set(float64(epoch))
+}
+
+// exemplar returns the exemplar labels for a request's context, or nil if
+// WithExemplarFromContext was not used.
+func (m *Metrics) exemplar(ctx context.Context) prometheus.Labels {
+	if m.cfg.exemplarFn == nil {
+		return nil
+	}
+	return m.cfg.exemplarFn(ctx)
+}
+
+// observe observes v with the exemplar if there is one.
+func observe(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	if len(exemplar) > 0 {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, exemplar)
+			return
+		}
+	}
+	o.Observe(v)
+}
+
+// topicLabel returns the topic label value for a topic, which is
+// OtherTopicLabel if the topic is filtered with AllowTopics, DenyTopics,
+// AllowTopicPattern, or DenyTopicPattern. Exact matches are checked before
+// patterns.
+func (c *cfg) topicLabel(topic string) string {
+	if _, denied := c.denyTopics[topic]; denied {
+		return OtherTopicLabel
+	}
+	if _, allowed := c.allowTopics[topic]; allowed {
+		return topic
+	}
+	if c.denyRe != nil && c.denyRe.MatchString(topic) {
+		return OtherTopicLabel
+	}
+	if c.allowRe != nil {
+		if c.allowRe.MatchString(topic) {
+			return topic
+		}
+		return OtherTopicLabel
+	}
+	if c.allowTopics != nil {
+		return OtherTopicLabel
+	}
+	return topic
+}
+
+// topicLabels returns the label values for per-topic produce and fetch
+// metrics, including the partition if WithPartitionLabel was used and the
+// compression codec if WithCompressionLabel was used.
+func (m *Metrics) topicLabels(node brokerLabels, topic string, partition int32, codec uint8) []string {
+	labels := node.with(topic)
+	if m.cfg.partitions {
+		labels = append(labels, strconv.Itoa(int(partition)))
+	}
+	if m.cfg.compression {
+		labels = append(labels, codecLabel(codec))
+	}
+	return labels
+}
+
+// codecLabel returns the compression label value for a batch's compression
+// type, as documented on kgo.ProduceBatchMetrics.
+func codecLabel(codec uint8) string {
+	switch codec {
+	case 0:
+		return "none"
+	case 1:
+		return "gzip"
+	case 2:
+		return "snappy"
+	case 3:
+		return "lz4"
+	case 4:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+// OnRecordDropped increments the produce_drops_total counter. This is not a
+// hook; to use it, pass it to the client:
+//
+//     kgo.OnRecordDropped(m.OnRecordDropped)
+//
+func (m *Metrics) OnRecordDropped(r *kgo.Record, _ error) {
+	if m.cfg.noProduce {
+		return
+	}
+	m.produceDrops.WithLabelValues(m.cfg.topicLabel(r.Topic)).Inc()
+}
+
+func (m *Metrics) OnFetchRecordBuffered(r *kgo.Record) {
+	if m.cfg.noFetch {
+		return
+	}
+	m.fetchQueueDepth.Inc()
+	if m.fetchQueueLatency != nil {
+		m.fetchBufferedAt.Store(r, time.Now())
+	}
+}
+
+func (m *Metrics) OnFetchRecordUnbuffered(r *kgo.Record) {
+	if m.cfg.noFetch {
+		return
+	}
+	m.fetchQueueDepth.Dec()
+	if m.fetchQueueLatency == nil {
+		return
+	}
+	if at, ok := m.fetchBufferedAt.Load(r); ok {
+		m.fetchBufferedAt.Delete(r)
+		m.fetchQueueLatency.Observe(time.Since(at.(time.Time)).Seconds())
+	}
+}
+
+// OnClientClosed releases the buffer times of records that were still
+// buffered when the client closed. Fetched records buffered when a client
+// closes are never unbuffered. If these
+// metrics are shared by many clients, records buffered by the other clients
+// are then not observed when they are unbuffered.
+func (m *Metrics) OnClientClosed(*kgo.Client) {
+	for _, bufferedAt := range []*sync.Map{&m.fetchBufferedAt, &m.produceBufferedAt} {
+		bufferedAt.Range(func(r, _ interface{}) bool {
+			bufferedAt.Delete(r)
+			return true
+		})
+	}
+}
+
+func (m *Metrics) OnProduceRecordBuffered(r *kgo.Record) {
+	if m.cfg.noProduce {
+		return
+	}
+	m.produceBufferedRecords.Inc()
+	m.produceBufferedBytes.Add(float64(recordSize(r)))
+	if m.produceRecordLatency != nil {
+		m.produceBufferedAt.Store(r, time.Now())
+	}
+}
+
+func (m *Metrics) OnProduceRecordUnbuffered(r *kgo.Record, _ error) {
+	if m.cfg.noProduce {
+		return
+	}
+	m.produceBufferedRecords.Dec()
+	m.produceBufferedBytes.Sub(float64(recordSize(r)))
+	if m.produceRecordLatency == nil {
+		return
+	}
+	if at, ok := m.produceBufferedAt.Load(r); ok {
+		m.produceBufferedAt.Delete(r)
+		m.produceRecordLatency.WithLabelValues(m.cfg.topicLabel(r.Topic)).Observe(time.Since(at.(time.Time)).Seconds())
+	}
+}
+
+// recordSize returns the size of a record's key, value, and headers.
+func recordSize(r *kgo.Record) int {
+	size := len(r.Key) + len(r.Value)
+	for _, h := range r.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
+
+// brokerLabels are the leading label values of every broker-level metric: the
+// node_id, followed by the rack if WithRackLabel was used.
+type brokerLabels []string
+
+// with returns the broker labels followed by values.
+func (b brokerLabels) with(values ...string) []string {
+	return append(b[:len(b):len(b)], values...)
+}
+
+// nodeLabel returns the labels for a broker, or false if metrics for the
+// broker should not be recorded.
+func (m *Metrics) nodeLabel(meta kgo.BrokerMetadata) (brokerLabels, bool) {
 	node := strconv.Itoa(int(meta.NodeID))
-	m.fetchBytes.WithLabelValues(node, topic).Add(float64(fbm.UncompressedBytes))
+	if meta.NodeID < 0 {
+		switch m.cfg.seedMode {
+		case SeedLabelAggregated:
+			node = m.cfg.seedLabel
+		case SeedLabelOmit:
+			return nil, false
+		}
+	}
+	if !m.cfg.rack {
+		return brokerLabels{node}, true
+	}
+	var rack string
+	if meta.Rack != nil {
+		rack = *meta.Rack
+	}
+	return brokerLabels{node, rack}, true
 }
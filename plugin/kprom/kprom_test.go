@@ -0,0 +1,304 @@
+package kprom
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func TestSnapshot(t *testing.T) {
+	m := NewMetrics("ns", GoCollectors()) // go_ metrics must not be snapshot
+	meta := kgo.BrokerMetadata{NodeID: 1}
+
+	const connects = `ns_connects_total{node_id="1"}`
+	if v, ok := m.Snapshot()[connects]; ok {
+		t.Fatalf("got %s = %v before any connect", connects, v)
+	}
+
+	m.OnBrokerConnect(meta, time.Millisecond, nil, nil)
+	m.OnBrokerConnect(meta, time.Millisecond, nil, nil)
+
+	snap := m.Snapshot()
+	if v := snap[connects]; v != 2 {
+		t.Errorf("got %s = %v, exp 2", connects, v)
+	}
+	const dialCount = `ns_connect_duration_seconds_count{node_id="1"}`
+	if v := snap[dialCount]; v != 2 {
+		t.Errorf("got %s = %v, exp 2", dialCount, v)
+	}
+	for name := range snap {
+		if !strings.HasPrefix(name, "ns_") {
+			t.Errorf("snapshot contains %s, which is not under the ns namespace", name)
+		}
+	}
+}
+
+func TestGroupLags(t *testing.T) {
+	committed := &kmsg.OffsetFetchResponse{
+		Topics: []kmsg.OffsetFetchResponseTopic{
+			{
+				Topic: "foo",
+				Partitions: []kmsg.OffsetFetchResponseTopicPartition{
+					{Partition: 0, Offset: 10},                                   // normal lag
+					{Partition: 1, Offset: 30},                                   // committed past the end: floored
+					{Partition: 2, Offset: -1},                                   // no commit: not reported
+					{Partition: 3, ErrorCode: kerr.UnstableOffsetCommit.Code},    // error: prior kept
+					{Partition: 4, Offset: 5},                                    // missing end: prior kept
+					{Partition: 5, ErrorCode: kerr.UnknownTopicOrPartition.Code}, // error, no prior: not reported
+					{Partition: 6, Offset: 7},                                    // end errored: prior kept
+				},
+			},
+			{
+				Topic: "bar",
+				Partitions: []kmsg.OffsetFetchResponseTopicPartition{
+					{Partition: 0, Offset: 0},
+				},
+			},
+		},
+	}
+	ends := &kmsg.ListOffsetsResponse{
+		Topics: []kmsg.ListOffsetsResponseTopic{
+			{
+				Topic: "foo",
+				Partitions: []kmsg.ListOffsetsResponseTopicPartition{
+					{Partition: 0, Offset: 15},
+					{Partition: 1, Offset: 20},
+					{Partition: 2, Offset: 20},
+					{Partition: 3, Offset: 20},
+					{Partition: 5, Offset: 20},
+					{Partition: 6, ErrorCode: kerr.NotLeaderForPartition.Code},
+				},
+			},
+			{
+				Topic: "bar",
+				Partitions: []kmsg.ListOffsetsResponseTopicPartition{
+					{Partition: 0, Offset: 3},
+				},
+			},
+		},
+	}
+	prior := map[string]map[int32]int64{
+		"foo": {0: 100, 3: 4, 4: 8, 6: 9},
+		"old": {0: 1},
+	}
+
+	got := groupLags(committed, ends, prior)
+	exp := map[string]map[int32]int64{
+		"foo": {0: 5, 1: 0, 3: 4, 4: 8, 6: 9},
+		"bar": {0: 3},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v != exp %v", got, exp)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	m := NewMetrics("ns")
+	for _, node := range []int32{1, 2} {
+		meta := kgo.BrokerMetadata{NodeID: node}
+		m.OnBrokerConnect(meta, time.Millisecond, nil, nil)
+		for _, topic := range []string{"foo", "bar"} {
+			m.OnProduceBatchWritten(meta, topic, 0, kgo.ProduceBatchMetrics{NumRecords: 1})
+		}
+	}
+
+	has := func(snap MetricsSnapshot, name string) bool {
+		_, ok := snap[name]
+		return ok
+	}
+
+	m.Cleanup("foo")
+	snap := m.Snapshot()
+	for _, name := range []string{
+		`ns_produce_records_total{node_id="1",topic="foo"}`,
+		`ns_produce_records_total{node_id="2",topic="foo"}`,
+	} {
+		if has(snap, name) {
+			t.Errorf("%s remains after Cleanup", name)
+		}
+	}
+	for _, name := range []string{
+		`ns_produce_records_total{node_id="1",topic="bar"}`,
+		`ns_produce_records_total{node_id="2",topic="bar"}`,
+		`ns_connects_total{node_id="1"}`,
+	} {
+		if !has(snap, name) {
+			t.Errorf("%s was deleted by Cleanup", name)
+		}
+	}
+
+	m.CleanupBroker(2)
+	snap = m.Snapshot()
+	for _, name := range []string{
+		`ns_connects_total{node_id="2"}`,
+		`ns_produce_records_total{node_id="2",topic="bar"}`,
+	} {
+		if has(snap, name) {
+			t.Errorf("%s remains after CleanupBroker", name)
+		}
+	}
+	for _, name := range []string{
+		`ns_connects_total{node_id="1"}`,
+		`ns_produce_records_total{node_id="1",topic="bar"}`,
+	} {
+		if !has(snap, name) {
+			t.Errorf("%s was deleted by CleanupBroker", name)
+		}
+	}
+}
+
+// callGatedHooks calls every hook that is disabled by one of the Without
+// options, which also ensures that no hook uses a metric that was not created.
+func callGatedHooks(m *Metrics) {
+	ctx := context.Background()
+	meta := kgo.BrokerMetadata{NodeID: 1}
+	r := &kgo.Record{Topic: "foo", Value: []byte("v")}
+	errFoo := errors.New("foo")
+
+	m.OnBrokerConnect(meta, time.Millisecond, nil, nil)
+	m.OnBrokerConnect(meta, time.Millisecond, nil, errFoo)
+	m.OnBrokerDisconnect(meta, nil)
+
+	m.OnBrokerWriteContext(ctx, meta, 0, 10, time.Millisecond, time.Millisecond, nil)
+	m.OnBrokerReadContext(ctx, meta, 0, 10, time.Millisecond, time.Millisecond, nil)
+	m.OnBrokerE2E(meta, 0, kgo.BrokerE2E{})
+
+	m.OnProduceBatchWritten(meta, "foo", 0, kgo.ProduceBatchMetrics{NumRecords: 1})
+	m.OnProducePartitionError(meta, "foo", 0, errFoo)
+	m.OnRecordDropped(r, errFoo)
+	m.OnProduceRecordBuffered(r)
+	m.OnProduceRecordUnbuffered(r, nil)
+
+	m.OnFetchBatchRead(meta, "foo", 0, kgo.FetchBatchMetrics{NumRecords: 1})
+	m.OnFetchPartitionError(meta, "foo", 0, errFoo)
+	m.OnFetchSession(meta, 1, 1, false)
+	m.OnFetchRecordBuffered(r)
+	m.OnFetchRecordUnbuffered(r)
+}
+
+func TestWithoutMetrics(t *testing.T) {
+	classes := map[string][]string{
+		"connect": {"ns_connects_total", "ns_connect_errors_total", "ns_disconnects_total"},
+		"io":      {"ns_write_bytes_total", "ns_read_bytes_total", "ns_requests_in_flight"},
+		"produce": {"ns_produce_records_total", "ns_produce_errors_total", "ns_produce_drops_total"},
+		"fetch":   {"ns_fetch_records_total", "ns_fetch_errors_total"},
+	}
+
+	for _, test := range []struct {
+		opt      Opt
+		disabled string
+	}{
+		{nil, ""},
+		{WithoutBrokerConnectMetrics(), "connect"},
+		{WithoutBrokerIOMetrics(), "io"},
+		{WithoutProduceMetrics(), "produce"},
+		{WithoutFetchMetrics(), "fetch"},
+	} {
+		var opts []Opt
+		if test.opt != nil {
+			opts = append(opts, test.opt)
+		}
+		m := NewMetrics("ns", opts...)
+		callGatedHooks(m)
+
+		families := make(map[string]bool)
+		for name := range m.Snapshot() {
+			if brace := strings.IndexByte(name, '{'); brace >= 0 {
+				name = name[:brace]
+			}
+			families[name] = true
+		}
+		for class, names := range classes {
+			for _, name := range names {
+				if exp := class != test.disabled; families[name] != exp {
+					t.Errorf("disabled %q: got %s present %v, exp %v", test.disabled, name, families[name], exp)
+				}
+			}
+		}
+	}
+}
+
+func TestRequestsInFlight(t *testing.T) {
+	m := NewMetrics("ns")
+	ctx := context.Background()
+	meta := kgo.BrokerMetadata{NodeID: 1}
+	errFoo := errors.New("foo")
+
+	const inflight = `ns_requests_in_flight{node_id="1"}`
+	check := func(exp float64) {
+		t.Helper()
+		if got := m.Snapshot()[inflight]; got != exp {
+			t.Errorf("got %s = %v, exp %v", inflight, got, exp)
+		}
+	}
+
+	m.OnBrokerWriteContext(ctx, meta, 0, 10, 0, 0, nil)
+	m.OnBrokerWriteContext(ctx, meta, 0, 10, 0, 0, nil)
+	check(2)
+
+	// A failed write is never in flight, and its e2e does not decrement.
+	m.OnBrokerWriteContext(ctx, meta, 0, 10, 0, 0, errFoo)
+	m.OnBrokerE2E(meta, 0, kgo.BrokerE2E{WriteErr: errFoo})
+	check(2)
+
+	// Responses decrement whether or not reading succeeded.
+	m.OnBrokerReadContext(ctx, meta, 0, 10, 0, 0, nil)
+	m.OnBrokerE2E(meta, 0, kgo.BrokerE2E{})
+	check(1)
+	m.OnBrokerReadContext(ctx, meta, 0, 0, 0, 0, errFoo)
+	m.OnBrokerE2E(meta, 0, kgo.BrokerE2E{ReadErr: errFoo})
+	check(0)
+}
+
+func TestRecordLatencies(t *testing.T) {
+	buffered := func(m *Metrics) int {
+		var n int
+		for _, bufferedAt := range []*sync.Map{&m.fetchBufferedAt, &m.produceBufferedAt} {
+			bufferedAt.Range(func(_, _ interface{}) bool { n++; return true })
+		}
+		return n
+	}
+	r := &kgo.Record{Topic: "foo"}
+
+	m := NewMetrics("ns")
+	m.OnFetchRecordBuffered(r)
+	m.OnProduceRecordBuffered(r)
+	if n := buffered(m); n != 0 {
+		t.Errorf("got %d buffered times without WithRecordLatencies, exp 0", n)
+	}
+	m.OnFetchRecordUnbuffered(r)
+	m.OnProduceRecordUnbuffered(r, nil)
+
+	m = NewMetrics("ns", WithRecordLatencies())
+	m.OnFetchRecordBuffered(r)
+	m.OnProduceRecordBuffered(r)
+	m.OnFetchRecordUnbuffered(r)
+	m.OnProduceRecordUnbuffered(r, nil)
+	if n := buffered(m); n != 0 {
+		t.Errorf("got %d buffered times after unbuffering, exp 0", n)
+	}
+	snap := m.Snapshot()
+	for _, count := range []string{
+		`ns_fetch_queue_latency_seconds_count`,
+		`ns_produce_record_latency_seconds_count{topic="foo"}`,
+	} {
+		if v := snap[count]; v != 1 {
+			t.Errorf("got %s = %v, exp 1", count, v)
+		}
+	}
+
+	m.OnFetchRecordBuffered(r)
+	m.OnProduceRecordBuffered(r)
+	m.OnClientClosed(nil)
+	if n := buffered(m); n != 0 {
+		t.Errorf("got %d buffered times after closing, exp 0", n)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/twmb/franz-go/plugin/kslog
+
+go 1.21
+
+require github.com/twmb/franz-go v0.9.0
+
+require (
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/klauspost/compress v1.13.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.7 // indirect
+	github.com/twmb/go-rbtree v1.0.0 // indirect
+)
@@ -0,0 +1,122 @@
+// Package kslog provides a plug-in kgo.Logger wrapping the standard library's
+// log/slog for usage in a kgo.Client.
+//
+// This can be used like so:
+//
+//     cl, err := kgo.NewClient(
+//             kgo.WithLogger(kslog.New(slog.Default())),
+//             // ...other opts
+//     )
+//
+// By default, the logger chooses the highest level possible that is enabled on
+// the slog logger, and then sticks with that level forever. A variable level
+// can be chosen by specifying the LevelFn option. See the documentation on
+// Level or LevelFn for more info.
+package kslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Logger provides the kgo.Logger interface for usage in kgo.WithLogger when
+// initializing a client.
+type Logger struct {
+	sl *slog.Logger
+
+	levelFn func() kgo.LogLevel
+}
+
+// New returns a new logger that by default forever logs at the highest level
+// enabled in the slog logger.
+func New(sl *slog.Logger, opts ...Opt) *Logger {
+	ctx := context.Background()
+	static := kgo.LogLevelError
+	switch {
+	case sl.Enabled(ctx, slog.LevelDebug):
+		static = kgo.LogLevelDebug
+	case sl.Enabled(ctx, slog.LevelInfo):
+		static = kgo.LogLevelInfo
+	case sl.Enabled(ctx, slog.LevelWarn):
+		static = kgo.LogLevelWarn
+	}
+	l := &Logger{
+		sl:      sl,
+		levelFn: func() kgo.LogLevel { return static },
+	}
+	for _, opt := range opts {
+		opt.apply(l)
+	}
+	return l
+}
+
+// Opt applies options to the logger.
+type Opt interface {
+	apply(*Logger)
+}
+
+type opt struct{ fn func(*Logger) }
+
+func (o opt) apply(l *Logger) { o.fn(l) }
+
+// LevelFn sets a function that can dynamically change the log level.
+//
+// This log level is independent of the slog logger level. The kgo.Client does
+// a *lot* more work to build debug strings than it does for other levels, so
+// the client pre-checks its level before building a log line. This option
+// provides that initial filter before Log is called, after which the slog
+// logger's handler level takes effect.
+func LevelFn(fn func() kgo.LogLevel) Opt {
+	return opt{func(l *Logger) { l.levelFn = fn }}
+}
+
+// Level sets a static level for the kgo.Logger Level function.
+//
+// See LevelFn for how this interacts with the slog logger's level.
+func Level(level kgo.LogLevel) Opt {
+	return LevelFn(func() kgo.LogLevel { return level })
+}
+
+// WithGroup nests all attributes the client logs, including any added with
+// WithAttrs after this option, under the given group name. See
+// slog.Logger.WithGroup.
+func WithGroup(name string) Opt {
+	return opt{func(l *Logger) { l.sl = l.sl.WithGroup(name) }}
+}
+
+// WithAttrs adds attributes to every log line, such as an attribute
+// identifying which client is logging. See slog.Logger.With.
+func WithAttrs(attrs ...slog.Attr) Opt {
+	return opt{func(l *Logger) {
+		args := make([]interface{}, 0, len(attrs))
+		for _, attr := range attrs {
+			args = append(args, attr)
+		}
+		l.sl = l.sl.With(args...)
+	}}
+}
+
+// Level is for the kgo.Logger interface.
+func (l *Logger) Level() kgo.LogLevel {
+	return l.levelFn()
+}
+
+// Log is for the kgo.Logger interface.
+func (l *Logger) Log(level kgo.LogLevel, msg string, keyvals ...interface{}) {
+	var slevel slog.Level
+	switch level {
+	case kgo.LogLevelDebug:
+		slevel = slog.LevelDebug
+	case kgo.LogLevelError:
+		slevel = slog.LevelError
+	case kgo.LogLevelInfo:
+		slevel = slog.LevelInfo
+	case kgo.LogLevelWarn:
+		slevel = slog.LevelWarn
+	default:
+		return
+	}
+	l.sl.Log(context.Background(), slevel, msg, keyvals...)
+}
@@ -0,0 +1,8 @@
+module github.com/twmb/franz-go/plugin/kstatsd
+
+go 1.16
+
+require (
+	github.com/DataDog/datadog-go/v5 v5.3.0
+	github.com/twmb/franz-go v0.9.0
+)
@@ -0,0 +1,194 @@
+// Package kstatsd provides StatsD metrics for a kgo client, sent through a
+// DogStatsD client.
+//
+// This package tracks the following metrics under the following names, all
+// tagged with node_id, and additionally with topic where noted:
+//
+//     connects              (count)
+//     connect_errors        (count)
+//     disconnects           (count)
+//     connect_time          (timing)
+//     write_errors          (count)
+//     write_bytes           (count)
+//     write_wait            (timing)
+//     write_time            (timing)
+//     read_errors           (count)
+//     read_bytes            (count)
+//     read_wait             (timing)
+//     read_time             (timing)
+//     produce_bytes{topic}  (count)
+//     fetch_bytes{topic}    (count)
+//
+// Produce and fetch bytes are uncompressed bytes.
+//
+// This can be used in a client like so:
+//
+//     client, err := statsd.New("127.0.0.1:8125", statsd.WithNamespace("kafka."))
+//     m := kstatsd.NewMetrics(client)
+//     cl, err := kgo.NewClient(
+//             kgo.WithHooks(m),
+//             // ...other opts
+//     )
+//
+// The statsd client is used as is: transport, namespacing, buffering, and
+// global tags are all configured on the client. Errors from the statsd client
+// are dropped.
+//
+// By default, tags are sent in the DogStatsD format. To instead embed tags in
+// the metric name, in the InfluxDB or Graphite format, use the WithTagFormat
+// option.
+//
+// Note that seed brokers use broker IDs starting at math.MinInt32.
+package kstatsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var ( // interface checks to ensure we implement the hooks properly
+	_ kgo.HookBrokerConnect       = new(Metrics)
+	_ kgo.HookBrokerDisconnect    = new(Metrics)
+	_ kgo.HookBrokerWrite         = new(Metrics)
+	_ kgo.HookBrokerRead          = new(Metrics)
+	_ kgo.HookProduceBatchWritten = new(Metrics)
+	_ kgo.HookFetchBatchRead      = new(Metrics)
+)
+
+// TagFormat is how tags are sent with metrics.
+type TagFormat uint8
+
+const (
+	// TagFormatDogStatsD sends tags as DogStatsD tags, which the statsd
+	// client appends to each metric as "|#node_id:1,topic:foo". This is
+	// the default.
+	TagFormatDogStatsD TagFormat = iota
+
+	// TagFormatInfluxDB embeds tags in the metric name in the InfluxDB
+	// StatsD format, "write_bytes,node_id=1".
+	TagFormatInfluxDB
+
+	// TagFormatGraphite embeds tags in the metric name in the Graphite
+	// tagged metric format, "write_bytes;node_id=1".
+	TagFormatGraphite
+)
+
+// Metrics provides StatsD metrics.
+type Metrics struct {
+	client statsd.ClientInterface
+	format TagFormat
+}
+
+// Opt applies options to further tune how metrics are sent.
+type Opt interface {
+	apply(*Metrics)
+}
+
+type opt struct{ fn func(*Metrics) }
+
+func (o opt) apply(m *Metrics) { o.fn(m) }
+
+// WithTagFormat sets the format tags are sent in, overriding the default
+// TagFormatDogStatsD.
+func WithTagFormat(format TagFormat) Opt {
+	return opt{func(m *Metrics) { m.format = format }}
+}
+
+// NewMetrics returns a new Metrics that sends metrics through client.
+func NewMetrics(client statsd.ClientInterface, opts ...Opt) *Metrics {
+	m := &Metrics{
+		client: client,
+	}
+	for _, opt := range opts {
+		opt.apply(m)
+	}
+	return m
+}
+
+// tagged returns the name and tags to send a metric with, given alternating
+// tag keys and values. Tags are either returned as DogStatsD tags or embedded
+// in the name, depending on the tag format.
+func (m *Metrics) tagged(name string, kvs ...string) (string, []string) {
+	switch m.format {
+	case TagFormatInfluxDB, TagFormatGraphite:
+		sep := ","
+		if m.format == TagFormatGraphite {
+			sep = ";"
+		}
+		var sb strings.Builder
+		sb.WriteString(name)
+		for i := 0; i < len(kvs); i += 2 {
+			sb.WriteString(sep)
+			sb.WriteString(kvs[i])
+			sb.WriteByte('=')
+			sb.WriteString(kvs[i+1])
+		}
+		return sb.String(), nil
+	default:
+		tags := make([]string, 0, len(kvs)/2)
+		for i := 0; i < len(kvs); i += 2 {
+			tags = append(tags, kvs[i]+":"+kvs[i+1])
+		}
+		return name, tags
+	}
+}
+
+func (m *Metrics) count(name string, value int64, kvs ...string) {
+	name, tags := m.tagged(name, kvs...)
+	m.client.Count(name, value, tags, 1)
+}
+
+func (m *Metrics) timing(name string, value time.Duration, kvs ...string) {
+	name, tags := m.tagged(name, kvs...)
+	m.client.Timing(name, value, tags, 1)
+}
+
+func (m *Metrics) OnBrokerConnect(meta kgo.BrokerMetadata, dialDur time.Duration, _ net.Conn, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	if err != nil {
+		m.count("connect_errors", 1, "node_id", node)
+		return
+	}
+	m.count("connects", 1, "node_id", node)
+	m.timing("connect_time", dialDur, "node_id", node)
+}
+
+func (m *Metrics) OnBrokerDisconnect(meta kgo.BrokerMetadata, _ net.Conn) {
+	m.count("disconnects", 1, "node_id", strconv.Itoa(int(meta.NodeID)))
+}
+
+func (m *Metrics) OnBrokerWrite(meta kgo.BrokerMetadata, _ int16, bytesWritten int, writeWait, timeToWrite time.Duration, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	m.timing("write_wait", writeWait, "node_id", node)
+	m.timing("write_time", timeToWrite, "node_id", node)
+	if err != nil {
+		m.count("write_errors", 1, "node_id", node)
+		return
+	}
+	m.count("write_bytes", int64(bytesWritten), "node_id", node)
+}
+
+func (m *Metrics) OnBrokerRead(meta kgo.BrokerMetadata, _ int16, bytesRead int, readWait, timeToRead time.Duration, err error) {
+	node := strconv.Itoa(int(meta.NodeID))
+	m.timing("read_wait", readWait, "node_id", node)
+	m.timing("read_time", timeToRead, "node_id", node)
+	if err != nil {
+		m.count("read_errors", 1, "node_id", node)
+		return
+	}
+	m.count("read_bytes", int64(bytesRead), "node_id", node)
+}
+
+func (m *Metrics) OnProduceBatchWritten(meta kgo.BrokerMetadata, topic string, _ int32, pbm kgo.ProduceBatchMetrics) {
+	m.count("produce_bytes", int64(pbm.UncompressedBytes), "node_id", strconv.Itoa(int(meta.NodeID)), "topic", topic)
+}
+
+func (m *Metrics) OnFetchBatchRead(meta kgo.BrokerMetadata, topic string, _ int32, fbm kgo.FetchBatchMetrics) {
+	m.count("fetch_bytes", int64(fbm.UncompressedBytes), "node_id", strconv.Itoa(int(meta.NodeID)), "topic", topic)
+}
@@ -26,7 +26,8 @@ import (
 type Logger struct {
 	zl *zap.Logger
 
-	levelFn func() kgo.LogLevel
+	levelFn  func() kgo.LogLevel
+	maxLevel kgo.LogLevel
 }
 
 // New returns a new logger that by default forever logs at the highest level
@@ -42,8 +43,9 @@ func New(zl *zap.Logger, opts ...Opt) *Logger {
 		static = kgo.LogLevelWarn
 	}
 	l := &Logger{
-		zl:      zl,
-		levelFn: func() kgo.LogLevel { return static },
+		zl:       zl,
+		levelFn:  func() kgo.LogLevel { return static },
+		maxLevel: kgo.LogLevelDebug,
 	}
 	for _, opt := range opts {
 		opt.apply(l)
@@ -88,9 +90,39 @@ func Level(level kgo.LogLevel) Opt {
 	return LevelFn(func() kgo.LogLevel { return level })
 }
 
+// WithLevel caps the kgo.Logger level at the kgo level corresponding to the
+// given zap level, regardless of the level chosen by default or with Level or
+// LevelFn. Zap levels above error are treated as error.
+//
+// This is useful to avoid the client building debug logs when the zap logger
+// is configured at a debug level for other parts of a program.
+func WithLevel(level zapcore.Level) Opt {
+	return opt{func(l *Logger) {
+		switch {
+		case level <= zapcore.DebugLevel:
+			l.maxLevel = kgo.LogLevelDebug
+		case level == zapcore.InfoLevel:
+			l.maxLevel = kgo.LogLevelInfo
+		case level == zapcore.WarnLevel:
+			l.maxLevel = kgo.LogLevelWarn
+		default:
+			l.maxLevel = kgo.LogLevelError
+		}
+	}}
+}
+
+// WithExtraFields adds fields to every log line, such as a field identifying
+// which client is logging.
+func WithExtraFields(fields ...zap.Field) Opt {
+	return opt{func(l *Logger) { l.zl = l.zl.With(fields...) }}
+}
+
 // Level is for the kgo.Logger interface.
 func (l *Logger) Level() kgo.LogLevel {
-	return l.levelFn()
+	if level := l.levelFn(); level < l.maxLevel {
+		return level
+	}
+	return l.maxLevel
 }
 
 // Log is for the kgo.Logger interface.